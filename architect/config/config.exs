@@ -19,6 +19,9 @@ config :architect,
     bin: "vcli",
     timeout: 7_000,
     log_errors: true
+  ],
+  artifacts: [
+    dir: "/var/lib/velocity/artifacts"
   ]
 
 # Configures the endpoint
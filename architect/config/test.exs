@@ -8,7 +8,8 @@ config :architect, ArchitectWeb.Endpoint,
 
 config :architect,
   ecto_repos: [Architect.Repo],
-  keyscan: [timeout: 7_000, log_errors: false]
+  keyscan: [timeout: 7_000, log_errors: false],
+  artifacts: [dir: Path.join(System.tmp_dir!(), "velocity-artifacts-test")]
 
 # Print only warnings and errors during test
 config :logger, level: :warn
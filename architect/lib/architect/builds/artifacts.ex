@@ -0,0 +1,124 @@
+defmodule Architect.Builds.Artifacts do
+  @moduledoc """
+  Stores the artifacts that builders upload in chunks on disk. Chunks are written into
+  <dir>/partial/<task id>/<path> and only moved to <dir>/tasks/<task id>/<path> once the completed
+  artifact matches its size and sha256 checksum.
+  """
+
+  # the most bytes of an artifact returned per fetch
+  @max_fetch_length 1_048_576
+
+  @doc """
+  Writes a base64 encoded chunk of the artifact at the offset. Chunks that are sent again are
+  written over themselves.
+  """
+  def put_chunk(task_id, path, offset, data)
+      when is_integer(offset) and offset >= 0 and is_binary(data) do
+    with {:ok, file_path} <- partial_path(task_id, path),
+         {:ok, bytes} <- decode(data),
+         :ok <- File.mkdir_p(Path.dirname(file_path)),
+         {:ok, file} <- :file.open(file_path, [:read, :write, :binary, :raw]) do
+      result = :file.pwrite(file, offset, bytes)
+      :file.close(file)
+      result
+    end
+  end
+
+  def put_chunk(_task_id, _path, _offset, _data), do: {:error, :invalid_chunk}
+
+  @doc """
+  Completes the artifact once its chunks match the size and sha256 checksum.
+  """
+  def complete(task_id, path, size, sha256)
+      when is_integer(size) and size >= 0 and is_binary(sha256) do
+    with {:ok, file_path} <- partial_path(task_id, path),
+         {:ok, dest} <- artifact_path(task_id, path),
+         # empty artifacts are completed without any chunks
+         :ok <- File.mkdir_p(Path.dirname(file_path)),
+         :ok <- File.touch(file_path),
+         {:ok, %File.Stat{size: actual}} when actual >= size <- File.stat(file_path),
+         :ok <- truncate(file_path, size),
+         ^sha256 <- file_sha256(file_path),
+         :ok <- File.mkdir_p(Path.dirname(dest)) do
+      File.rename(file_path, dest)
+    else
+      {:ok, %File.Stat{}} -> {:error, :size_mismatch}
+      checksum when is_binary(checksum) -> {:error, :checksum_mismatch}
+      {:error, reason} -> {:error, reason}
+    end
+  end
+
+  def complete(_task_id, _path, _size, _sha256), do: {:error, :invalid_artifact}
+
+  @doc """
+  Reads up to length bytes of the completed artifact from the offset.
+  """
+  def fetch(task_id, path, offset, length)
+      when is_integer(offset) and offset >= 0 and is_integer(length) and length > 0 do
+    with {:ok, file_path} <- artifact_path(task_id, path),
+         {:ok, file} <- :file.open(file_path, [:read, :binary, :raw]) do
+      result = :file.pread(file, offset, min(length, @max_fetch_length))
+      :file.close(file)
+
+      case result do
+        :eof -> {:ok, ""}
+        result -> result
+      end
+    end
+  end
+
+  def fetch(_task_id, _path, _offset, _length), do: {:error, :invalid_fetch}
+
+  defp dir(), do: Application.get_env(:architect, :artifacts)[:dir]
+
+  defp partial_path(task_id, path), do: path_in("partial", task_id, path)
+
+  defp artifact_path(task_id, path), do: path_in("tasks", task_id, path)
+
+  # artifact paths are relative to the project root and use forward slashes, so must stay within
+  # the task's directory
+  defp path_in(kind, task_id, path) when is_binary(task_id) and is_binary(path) do
+    segments = String.split(path, "/")
+
+    cond do
+      task_id in ["", ".", ".."] or String.contains?(task_id, ["/", "\\"]) ->
+        {:error, :invalid_task_id}
+
+      Enum.any?(segments, &(&1 in ["", ".", ".."] or String.contains?(&1, "\\"))) ->
+        {:error, :invalid_path}
+
+      true ->
+        {:ok, Path.join([dir(), kind, task_id | segments])}
+    end
+  end
+
+  defp path_in(_kind, _task_id, _path), do: {:error, :invalid_path}
+
+  defp decode(data) do
+    case Base.decode64(data) do
+      {:ok, bytes} -> {:ok, bytes}
+      :error -> {:error, :invalid_data}
+    end
+  end
+
+  # chunks of an earlier upload of the same artifact may have left a longer file behind
+  defp truncate(file_path, size) do
+    with {:ok, file} <- :file.open(file_path, [:read, :write, :binary, :raw]) do
+      result =
+        with {:ok, _} <- :file.position(file, size) do
+          :file.truncate(file)
+        end
+
+      :file.close(file)
+      result
+    end
+  end
+
+  defp file_sha256(file_path) do
+    file_path
+    |> File.stream!([], 65_536)
+    |> Enum.reduce(:crypto.hash_init(:sha256), &:crypto.hash_update(&2, &1))
+    |> :crypto.hash_final()
+    |> Base.encode16(case: :lower)
+  end
+end
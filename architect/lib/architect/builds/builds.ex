@@ -143,4 +143,45 @@ defmodule Architect.Builds do
         :ok
     end
   end
+
+  @doc """
+  Records an artifact that a builder uploaded for the task in the task's plan, replacing any
+  artifact at the same path.
+  """
+  def add_task_artifact(task_id, artifact) do
+    case Repo.get(Task, task_id) do
+      nil ->
+        {:error, :unknown_task}
+
+      task ->
+        artifacts =
+          (task.plan["artifacts"] || [])
+          |> Enum.reject(fn a -> a["path"] == artifact["path"] end)
+
+        plan = Map.put(task.plan, "artifacts", artifacts ++ [artifact])
+
+        task
+        |> Task.update_changeset(%{plan: plan})
+        |> Repo.update()
+    end
+  end
+
+  @doc """
+  Returns the task's plan with the artifacts that the tasks it takes artifacts from uploaded, so
+  a builder can restore them.
+  """
+  def get_task_plan_with_artifact_sources(%Task{plan: plan}) do
+    from =
+      Enum.map(plan["from"] || [], fn source ->
+        artifacts =
+          case Repo.get(Task, source["taskId"]) do
+            nil -> []
+            from_task -> from_task.plan["artifacts"] || []
+          end
+
+        Map.put(source, "artifacts", artifacts)
+      end)
+
+    Map.put(plan, "from", from)
+  end
 end
@@ -1,6 +1,7 @@
 defmodule ArchitectWeb.BuilderChannel do
   use Phoenix.Channel
   alias Architect.Builders
+  alias Architect.Builds.Artifacts
 
   require Logger
 
@@ -68,6 +69,62 @@ defmodule ArchitectWeb.BuilderChannel do
     {:reply, :ok, socket}
   end
 
+  @doc """
+  Handle artifact chunks uploaded by builders.
+  """
+  def handle_in("#{@event_prefix}task-artifact:chunk", payload, socket) do
+    payload["taskId"]
+    |> Artifacts.put_chunk(payload["path"], payload["offset"], payload["data"])
+    |> artifact_reply(socket)
+  end
+
+  @doc """
+  Handle completed artifact uploads. The builder only considers the artifact saved once this
+  replies ok.
+  """
+  def handle_in("#{@event_prefix}task-artifact:complete", payload, socket) do
+    artifact = Map.take(payload, ["path", "size", "sha256", "mode", "stepId"])
+
+    with :ok <-
+           Artifacts.complete(
+             payload["taskId"],
+             payload["path"],
+             payload["size"],
+             payload["sha256"]
+           ),
+         {:ok, _} <- Architect.Builds.add_task_artifact(payload["taskId"], artifact) do
+      {:reply, :ok, socket}
+    else
+      error -> artifact_reply(error, socket)
+    end
+  end
+
+  @doc """
+  Handle builders fetching chunks of the artifacts they restore.
+  """
+  def handle_in("#{@event_prefix}task-artifact:fetch", payload, socket) do
+    case Artifacts.fetch(
+           payload["taskId"],
+           payload["path"],
+           payload["offset"],
+           payload["length"]
+         ) do
+      {:ok, data} ->
+        {:reply, {:ok, %{data: Base.encode64(data)}}, socket}
+
+      error ->
+        artifact_reply(error, socket)
+    end
+  end
+
+  defp artifact_reply(:ok, socket), do: {:reply, :ok, socket}
+
+  defp artifact_reply({:error, reason}, socket) do
+    Logger.error("artifact error: #{inspect(reason)}")
+
+    {:reply, {:error, %{reason: inspect(reason)}}, socket}
+  end
+
   @doc """
   Starts a task on a builder.
   """
@@ -83,7 +140,7 @@ defmodule ArchitectWeb.BuilderChannel do
         %{
           # entry: ""
         },
-      task: t.plan,
+      task: Architect.Builds.get_task_plan_with_artifact_sources(t),
       branch: b.branch_name,
       commit: b.commit_sha,
       parameters: b.parameters
@@ -0,0 +1,48 @@
+defmodule Architect.Builds.ArtifactsTest do
+  use ExUnit.Case, async: true
+
+  alias Architect.Builds.Artifacts
+
+  @task_id "3b0a4f9c-6a7d-4f57-9c5b-0c7e6f1d2a10"
+
+  defp sha256(data), do: :crypto.hash(:sha256, data) |> Base.encode16(case: :lower)
+
+  test "stores an artifact uploaded in chunks" do
+    :ok = Artifacts.put_chunk(@task_id, "dist/vcli", 0, Base.encode64("hello "))
+    :ok = Artifacts.put_chunk(@task_id, "dist/vcli", 6, Base.encode64("world"))
+    # chunks that are sent again are written over themselves
+    :ok = Artifacts.put_chunk(@task_id, "dist/vcli", 6, Base.encode64("world"))
+
+    assert :ok == Artifacts.complete(@task_id, "dist/vcli", 11, sha256("hello world"))
+    assert {:ok, "hello world"} == Artifacts.fetch(@task_id, "dist/vcli", 0, 65_536)
+    assert {:ok, "world"} == Artifacts.fetch(@task_id, "dist/vcli", 6, 65_536)
+    assert {:ok, ""} == Artifacts.fetch(@task_id, "dist/vcli", 11, 65_536)
+  end
+
+  test "stores an empty artifact" do
+    assert :ok == Artifacts.complete(@task_id, "empty", 0, sha256(""))
+    assert {:ok, ""} == Artifacts.fetch(@task_id, "empty", 0, 65_536)
+  end
+
+  test "rejects an artifact that does not match its checksum" do
+    :ok = Artifacts.put_chunk(@task_id, "corrupt", 0, Base.encode64("hello"))
+
+    assert {:error, :checksum_mismatch} ==
+             Artifacts.complete(@task_id, "corrupt", 5, sha256("world"))
+
+    assert {:error, :size_mismatch} ==
+             Artifacts.complete(@task_id, "corrupt", 6, sha256("hello!"))
+
+    assert {:error, :enoent} == Artifacts.fetch(@task_id, "corrupt", 0, 65_536)
+  end
+
+  test "rejects paths outside of the task's directory" do
+    assert {:error, :invalid_path} ==
+             Artifacts.put_chunk(@task_id, "../other/dist", 0, Base.encode64("x"))
+
+    assert {:error, :invalid_path} ==
+             Artifacts.put_chunk(@task_id, "/etc/passwd", 0, Base.encode64("x"))
+
+    assert {:error, :invalid_task_id} == Artifacts.fetch("../tasks", "dist/vcli", 0, 65_536)
+  end
+end
@@ -0,0 +1,73 @@
+package cmds
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/docker/docker/api/types"
+	"github.com/logrusorgru/aurora"
+	"github.com/spf13/cobra"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/docker"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+)
+
+func init() {
+	cacheCmd.AddCommand(cacheLsCmd)
+}
+
+var cacheLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "lists cache volumes",
+	Long:    `lists cache volumes`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		labels, err := getCacheVolumeLabels()
+		if err != nil {
+			return err
+		}
+		volumes, err := docker.ListCacheVolumes(labels)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case machineReadable:
+			return listCacheVolumesMachine(volumes)
+		default:
+			return listCacheVolumesText(volumes)
+		}
+	},
+}
+
+func listCacheVolumesText(volumes []*types.Volume) error {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	printHeader("Caches")
+	if len(volumes) > 0 {
+		for _, v := range volumes {
+			fmt.Fprintf(tabWriter, " %s %s\t%s\t%s\t%s\t%s\n",
+				output.ColorFmt(aurora.CyanFg, "->", " "),
+				v.Labels[docker.CacheProjectLabel],
+				v.Labels[docker.CacheBlueprintLabel],
+				v.Labels[docker.CacheNameLabel],
+				v.Labels[docker.CacheKeyLabel],
+				aurora.Colorize(v.Name, aurora.ItalicFm|aurora.Gray(20, "").Color()),
+			)
+		}
+		tabWriter.Flush()
+	} else {
+		fmt.Fprintln(os.Stdout, "  none found")
+	}
+	return nil
+}
+
+func listCacheVolumesMachine(volumes []*types.Volume) error {
+	jsonBytes, err := json.MarshalIndent(volumes, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", jsonBytes)
+	return nil
+}
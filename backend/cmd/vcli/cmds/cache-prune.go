@@ -0,0 +1,44 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/docker"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+)
+
+func init() {
+	cacheCmd.AddCommand(cachePruneCmd)
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "removes cache volumes",
+	Long:  `removes cache volumes that are not in use by a container`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		labels, err := getCacheVolumeLabels()
+		if err != nil {
+			return err
+		}
+		volumes, err := docker.ListCacheVolumes(labels)
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, v := range volumes {
+			if err := docker.RemoveVolume(v.Name); err != nil {
+				failed++
+				fmt.Fprintf(os.Stdout, output.ColorFmt(output.ANSIError, "-> could not remove %s: %s", "\n"), v.Name, err)
+				continue
+			}
+			fmt.Fprintf(os.Stdout, output.ColorFmt(output.ANSISuccess, "-> removed %s", "\n"), v.Name)
+		}
+		if failed > 0 {
+			return fmt.Errorf("could not remove %d cache volumes", failed)
+		}
+		return nil
+	},
+}
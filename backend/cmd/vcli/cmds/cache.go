@@ -0,0 +1,45 @@
+package cmds
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/docker"
+)
+
+var (
+	cacheAll       bool
+	cacheBlueprint string
+)
+
+func init() {
+	cacheCmd.PersistentFlags().BoolVar(&cacheAll, "all", false, "Include the caches of every project")
+	cacheCmd.PersistentFlags().StringVar(&cacheBlueprint, "blueprint", "", "Only include the caches of the given blueprint")
+	rootCmd.AddCommand(cacheCmd)
+}
+
+var cacheCmd = &cobra.Command{
+	Use:       "cache",
+	Short:     "Inspects and cleans blueprint cache volumes",
+	Long:      `Inspects and cleans the Docker volumes used by blueprint caches`,
+	ValidArgs: []string{"ls", "prune"},
+	Args:      cobra.OnlyValidArgs,
+	Run:       func(cmd *cobra.Command, args []string) {},
+}
+
+// getCacheVolumeLabels returns the labels that select the cache volumes given by the cache flags
+func getCacheVolumeLabels() (map[string]string, error) {
+	labels := map[string]string{}
+	if !cacheAll {
+		root, err := config.GetRootConfig()
+		if err != nil {
+			return nil, err
+		}
+		labels[docker.CacheProjectLabel] = build.GetProjectName(nil, root.Path)
+	}
+	if cacheBlueprint != "" {
+		labels[docker.CacheBlueprintLabel] = cacheBlueprint
+	}
+
+	return labels, nil
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
@@ -46,11 +49,28 @@ func infoMachine(root *config.Root) error {
 }
 
 func infoText(root *config.Root) error {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(os.Stdout, "\n~~ %s ~~\n", output.Italic("Project"))
+	fmt.Fprintf(tabWriter, "  path\t%s\n", root.Path)
+	fmt.Fprintf(tabWriter, "  config path\t%s\n", root.Project.ConfigPath)
+	if root.Project.Logo != nil {
+		fmt.Fprintf(tabWriter, "  logo\t%s\n", *root.Project.Logo)
+	}
+	fmt.Fprintf(tabWriter, "  git submodules\t%t\n", root.Git.Submodule)
+	tabWriter.Flush()
+
 	fmt.Fprintf(os.Stdout, "\n~~ %s ~~\n", output.Italic("Parameters"))
 	if len(root.Parameters) > 0 {
-		// for _, parameter := range root.Parameters {
-		// 	fmt.Fprintf(os.Stdout, "  %s\t%s\n", parameter.Type)
-		// }
+		for _, parameter := range root.Parameters {
+			switch x := parameter.(type) {
+			case *config.ParameterBasic:
+				fmt.Fprintf(tabWriter, "  %s\t%s\n", x.Name, getParameterBasicSummary(x))
+			case *config.ParameterDerived:
+				fmt.Fprintf(tabWriter, "  %s\t%s\n", x.GetPluginReference(), getParameterDerivedSummary(x))
+			}
+		}
+		tabWriter.Flush()
 	} else {
 		fmt.Fprintln(os.Stdout, "  none found")
 	}
@@ -58,13 +78,59 @@ func infoText(root *config.Root) error {
 	fmt.Fprintf(os.Stdout, "\n~~ %s ~~\n", output.Italic("Plugins"))
 	if len(root.Plugins) > 0 {
 		for _, plugin := range root.Plugins {
-			fmt.Fprintf(os.Stdout, "  %s\t%s\n", plugin.Use, plugin.Events)
+			fmt.Fprintf(tabWriter, "  %s\t%s\n", plugin.GetPluginReference(), strings.Join(plugin.Events, ", "))
 		}
+		tabWriter.Flush()
 	} else {
 		fmt.Fprintln(os.Stdout, "  none found")
 	}
 
+	if len(root.ValidationErrors) > 0 {
+		fmt.Fprintf(os.Stdout, "\n~~ %s ~~\n", output.Italic("Errors"))
+		for _, e := range root.ValidationErrors {
+			fmt.Fprintf(os.Stdout, "  %s\n", output.ColorFmt(output.ANSIError, e, ""))
+		}
+	}
+
 	fmt.Fprintln(os.Stdout, "")
 
 	return nil
 }
+
+func getParameterBasicSummary(p *config.ParameterBasic) string {
+	summary := []string{p.GetKind()}
+	switch {
+	case p.GetKind() == config.ParameterKindEnum:
+		summary = append(summary, fmt.Sprintf("one of %s", strings.Join(p.GetOptions(), ", ")))
+	case p.GetKind() == config.ParameterKindRegex:
+		summary = append(summary, fmt.Sprintf("matching %s", p.Pattern))
+	}
+	switch {
+	case p.Default != "" && !p.Secret:
+		summary = append(summary, fmt.Sprintf("default %s", p.Default))
+	case p.IsRequired():
+		summary = append(summary, "required")
+	default:
+		summary = append(summary, "optional")
+	}
+	if p.Secret {
+		summary = append(summary, "secret")
+	}
+	if p.Description != "" {
+		summary = append(summary, p.Description)
+	}
+	return strings.Join(summary, "; ")
+}
+
+func getParameterDerivedSummary(p *config.ParameterDerived) string {
+	exports := []string{}
+	for k, v := range p.Exports {
+		exports = append(exports, fmt.Sprintf("%s as %s", k, v))
+	}
+	sort.Strings(exports)
+	summary := []string{fmt.Sprintf("exports %s", strings.Join(exports, ", "))}
+	if p.Secret {
+		summary = append(summary, "secret")
+	}
+	return strings.Join(summary, "; ")
+}
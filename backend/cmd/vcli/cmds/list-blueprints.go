@@ -52,6 +52,14 @@ func listBlueprintsText(blueprints []*config.Blueprint) error {
 				blueprint.Name,
 				aurora.Colorize(blueprint.Description, aurora.ItalicFm|aurora.Gray(20, "").Color()),
 			)
+			for _, errs := range [][]string{blueprint.ParseErrors, blueprint.ValidationErrors} {
+				for _, e := range errs {
+					fmt.Fprintf(tabWriter, "     %s\n", output.ColorFmt(output.ANSIError, e, ""))
+				}
+			}
+			for _, w := range blueprint.ValidationWarnings {
+				fmt.Fprintf(tabWriter, "     %s\n", output.ColorFmt(output.ANSIWarn, w, ""))
+			}
 		}
 		tabWriter.Flush()
 	} else {
@@ -0,0 +1,44 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
+)
+
+func init() {
+	pluginsCmd.AddCommand(pluginsFetchCmd)
+}
+
+var pluginsFetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "fetches plugins",
+	Long:  `downloads and verifies the plugins used by the current project into the plugin cache`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plugins, err := getProjectPlugins()
+		if err != nil {
+			return err
+		}
+		store, err := plugin.GetStore()
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, p := range plugins {
+			if _, err := store.Fetch(p.ref, os.Stdout); err != nil {
+				failed++
+				fmt.Fprintf(os.Stdout, output.ColorFmt(output.ANSIError, "-> could not fetch %s (%s): %s", "\n"), p.ref, p.source, err)
+				continue
+			}
+			fmt.Fprintf(os.Stdout, output.ColorFmt(output.ANSISuccess, "-> fetched %s", "\n"), p.ref)
+		}
+		if failed > 0 {
+			return fmt.Errorf("could not fetch %d plugins", failed)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,84 @@
+package cmds
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/spf13/cobra"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
+)
+
+func init() {
+	pluginsCmd.AddCommand(pluginsLsCmd)
+}
+
+var pluginsLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "lists cached plugins",
+	Long:    `lists the plugin binaries in the plugin cache, which is shared by every project`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := plugin.GetStore()
+		if err != nil {
+			return err
+		}
+		entries, err := store.List()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case machineReadable:
+			return listPluginsMachine(entries)
+		default:
+			return listPluginsText(store, entries)
+		}
+	},
+}
+
+func listPluginsText(store *plugin.Store, entries []*plugin.Entry) error {
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	printHeader("Plugins")
+	fmt.Fprintf(os.Stdout, "  %s\n", aurora.Colorize(store.Dir, aurora.ItalicFm|aurora.Gray(20, "").Color()))
+	if len(entries) > 0 {
+		for _, e := range entries {
+			name := e.URL
+			switch {
+			case e.Version != "":
+				name = fmt.Sprintf("%s@%s", e.Use, e.Version)
+			case name == "":
+				name = "unknown"
+			}
+			state := output.ColorFmt(output.ANSISuccess, "ok", "")
+			if err := store.VerifyEntry(e); err != nil {
+				state = output.ColorFmt(output.ANSIError, "invalid", "")
+			}
+			fmt.Fprintf(tabWriter, " %s %s\t%s\t%d bytes\t%s\t%s\n",
+				output.ColorFmt(aurora.CyanFg, "->", " "),
+				name,
+				e.SHA256[:12],
+				e.Size,
+				state,
+				aurora.Colorize(e.Fetched.Local().Format("2006-01-02 15:04"), aurora.ItalicFm|aurora.Gray(20, "").Color()),
+			)
+		}
+		tabWriter.Flush()
+	} else {
+		fmt.Fprintln(os.Stdout, "  none found")
+	}
+	return nil
+}
+
+func listPluginsMachine(entries []*plugin.Entry) error {
+	jsonBytes, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", jsonBytes)
+	return nil
+}
@@ -0,0 +1,44 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
+)
+
+func init() {
+	pluginsCmd.AddCommand(pluginsVerifyCmd)
+}
+
+var pluginsVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "verifies plugins",
+	Long:  `verifies that the plugins used by the current project are pinned and fetched, and match their sha256 checksums`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plugins, err := getProjectPlugins()
+		if err != nil {
+			return err
+		}
+		store, err := plugin.GetStore()
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, p := range plugins {
+			if _, err := store.Verify(p.ref); err != nil {
+				failed++
+				fmt.Fprintf(os.Stdout, output.ColorFmt(output.ANSIError, "-> %s (%s): %s", "\n"), p.ref, p.source, err)
+				continue
+			}
+			fmt.Fprintf(os.Stdout, output.ColorFmt(output.ANSISuccess, "-> verified %s", "\n"), p.ref)
+		}
+		if failed > 0 {
+			return fmt.Errorf("could not verify %d plugins", failed)
+		}
+		return nil
+	},
+}
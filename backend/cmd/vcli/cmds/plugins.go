@@ -0,0 +1,70 @@
+package cmds
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
+)
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+}
+
+var pluginsCmd = &cobra.Command{
+	Use:       "plugins",
+	Short:     "Fetches and verifies plugin binaries",
+	Long:      `Fetches and verifies the plugin binaries used by the current project in the per-user plugin cache`,
+	ValidArgs: []string{"fetch", "verify", "ls"},
+	Args:      cobra.OnlyValidArgs,
+	Run:       func(cmd *cobra.Command, args []string) {},
+}
+
+// projectPlugin is a plugin reference and where in the project it is made
+type projectPlugin struct {
+	ref    plugin.Reference
+	source string
+}
+
+// getProjectPlugins returns the plugins referred to by the current project's root configuration and blueprints
+func getProjectPlugins() ([]*projectPlugin, error) {
+	root, err := config.GetRootConfig()
+	if err != nil {
+		return nil, err
+	}
+	blueprints, err := config.GetBlueprintsFromRoot(root)
+	if err != nil {
+		return nil, err
+	}
+
+	plugins := []*projectPlugin{}
+	seen := map[plugin.Reference]bool{}
+	add := func(ref plugin.Reference, source string) {
+		if !seen[ref] {
+			seen[ref] = true
+			plugins = append(plugins, &projectPlugin{ref: ref, source: source})
+		}
+	}
+	addParameters := func(params []config.Parameter, source string) {
+		for _, param := range params {
+			if p, ok := param.(*config.ParameterDerived); ok {
+				add(p.GetPluginReference(), source)
+			}
+		}
+	}
+
+	addParameters(root.Parameters, ".velocity.yml")
+	for _, p := range root.Plugins {
+		add(p.GetPluginReference(), ".velocity.yml")
+	}
+	for _, blueprint := range blueprints {
+		source := fmt.Sprintf("blueprint %s", blueprint.Name)
+		addParameters(blueprint.Parameters, source)
+		for _, r := range blueprint.Docker.Registries {
+			add(r.GetPluginReference(), source)
+		}
+	}
+
+	return plugins, nil
+}
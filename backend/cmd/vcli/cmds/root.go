@@ -20,7 +20,7 @@ var (
 )
 
 var (
-	gracefulStop = make(chan os.Signal)
+	gracefulStop = make(chan os.Signal, 1)
 	action       build.Stoppable
 )
 
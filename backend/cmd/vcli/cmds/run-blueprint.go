@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/velocity-ci/velocity/backend/pkg/git"
@@ -12,7 +14,11 @@ import (
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
 )
 
+var runMatrix []string
+
 func init() {
+	runBlueprintCmd.Flags().IntVar(&runParallelism, "parallelism", 0, "Maximum number of matrix combinations to run at once (default: unlimited)")
+	runBlueprintCmd.Flags().StringArrayVar(&runMatrix, "matrix", []string{}, "Only run the matrix combinations with the given value e.g. go=1.12 (can be repeated)")
 	runCmd.AddCommand(runBlueprintCmd)
 }
 
@@ -43,32 +49,65 @@ var runBlueprintCmd = &cobra.Command{
 			}
 		}
 
+		matrixFilter, err := parseMatrixFilter(runMatrix)
+		if err != nil {
+			return err
+		}
+
+		paramResolver, err := newParameterResolver()
+		if err != nil {
+			return err
+		}
+
 		constructionPlan, err := build.NewConstructionPlanFromBlueprint(
 			args[0],
 			blueprints,
-			&vcli.ParameterResolver{},
+			paramResolver,
 			nil,
 			branch,
 			"",
 			root.Path,
+			matrixFilter,
 		)
 		if err != nil {
 			return err
 		}
+		constructionPlan.SetRoot(root)
+		constructionPlan.Parallelism = runParallelism
 
 		switch {
 		case runPlanOnly && machineReadable:
 			return runConstructionPlanPlanOnlyAndMachineReadable(constructionPlan)
 		case runPlanOnly:
-			return runConstructionPlanPlanOnly(constructionPlan)
+			return runConstructionPlanPlanOnly(constructionPlan, root.Path, branch)
 		default:
-			return runConstructionPlanText(constructionPlan)
+			if err := resolvePlanParameters(constructionPlan, paramResolver); err != nil {
+				return err
+			}
+			return runConstructionPlanText(constructionPlan, root.Path)
 		}
 	},
 }
 
-func runConstructionPlanText(plan *build.ConstructionPlan) error {
+func parseMatrixFilter(values []string) (map[string]string, error) {
+	filter := map[string]string{}
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid matrix %q, expected <key>=<value>", v)
+		}
+		filter[parts[0]] = parts[1]
+	}
+	return filter, nil
+}
+
+func runConstructionPlanText(plan *build.ConstructionPlan, projectRoot string) error {
 	emitter := vcli.NewEmitter()
+	artifactsDir := runArtifactsDir
+	if artifactsDir == "" {
+		artifactsDir = filepath.Join(projectRoot, ".velocityci", "artifacts")
+	}
+	plan.SetArtifactStore(build.NewLocalArtifactStore(artifactsDir))
 	action = plan
 	err := plan.Execute(emitter)
 	if err != nil {
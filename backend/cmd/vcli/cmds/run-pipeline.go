@@ -2,11 +2,11 @@ package cmds
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 
 	"github.com/logrusorgru/aurora"
-	"github.com/velocity-ci/velocity/backend/pkg/vcli"
 
 	"github.com/spf13/cobra"
 	"github.com/velocity-ci/velocity/backend/pkg/git"
@@ -15,7 +15,10 @@ import (
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
 )
 
+var runParallelism int
+
 func init() {
+	runPipelineCmd.Flags().IntVar(&runParallelism, "parallelism", 0, "Maximum number of tasks to run at once (default: the pipeline's parallelism, or unlimited)")
 	runCmd.AddCommand(runPipelineCmd)
 }
 
@@ -50,50 +53,120 @@ var runPipelineCmd = &cobra.Command{
 			}
 		}
 
+		paramResolver, err := newParameterResolver()
+		if err != nil {
+			return err
+		}
+
 		constructionPlan, err := build.NewConstructionPlanFromPipeline(
 			args[0],
 			pipelines,
 			blueprints,
-			&vcli.ParameterResolver{},
+			paramResolver,
 			nil,
 			branch,
 			"",
 			root.Path,
 		)
+		if err != nil {
+			return err
+		}
+		constructionPlan.SetRoot(root)
+		if runParallelism > 0 {
+			constructionPlan.Parallelism = runParallelism
+		}
 
 		switch {
 		case runPlanOnly && machineReadable:
 			return runConstructionPlanPlanOnlyAndMachineReadable(constructionPlan)
 		case runPlanOnly:
-			return runConstructionPlanPlanOnly(constructionPlan)
+			return runConstructionPlanPlanOnly(constructionPlan, root.Path, branch)
 		default:
-			return runConstructionPlanText(constructionPlan)
+			if err := resolvePlanParameters(constructionPlan, paramResolver); err != nil {
+				return err
+			}
+			return runConstructionPlanText(constructionPlan, root.Path)
 		}
 	},
 }
 
-func runConstructionPlanPlanOnly(plan *build.ConstructionPlan) error {
+func runConstructionPlanPlanOnly(plan *build.ConstructionPlan, projectRoot, branch string) error {
+	// user parameters are unknown before running, so conditions using them are shown as evaluated at runtime
+	params := map[string]*build.Parameter{}
+	globalParams, _ := build.GetGlobalParams(ioutil.Discard, projectRoot, branch)
+	for k, v := range globalParams {
+		param := v
+		params[k] = &param
+	}
+
 	printHeader(plan.Name)
+	blueprintNames := map[string]string{}
+	for _, stage := range plan.Stages {
+		for _, task := range stage.Tasks {
+			blueprintNames[task.ID] = task.GetName()
+		}
+	}
 	for _, stage := range plan.Stages {
 		fmt.Fprintf(os.Stdout,
 			output.ColorFmt(aurora.CyanFg, fmt.Sprintf(" Stage %d", stage.Index), "\n"))
 		for _, task := range stage.Tasks {
 			fmt.Fprintf(os.Stdout, "   -> %s: %s\n",
-				task.Blueprint.Name,
+				task.GetName(),
 				aurora.Colorize(task.Blueprint.Description, aurora.ItalicFm|aurora.Gray(20, "").Color()),
 			)
+			if len(task.Needs) > 0 {
+				needs := []string{}
+				for _, taskID := range task.Needs {
+					needs = append(needs, blueprintNames[taskID])
+				}
+				fmt.Fprintf(os.Stdout, "      needs: %s\n", strings.Join(needs, ", "))
+			}
+			if len(task.From) > 0 {
+				from := []string{}
+				for _, source := range task.From {
+					from = append(from, source.Blueprint)
+				}
+				fmt.Fprintf(os.Stdout, "      artifacts from: %s\n", strings.Join(from, ", "))
+			}
+			for _, c := range task.Blueprint.Cache {
+				fmt.Fprintf(os.Stdout, "      cache: %s at %s\n", c.Name, c.Path)
+			}
+			if task.When != "" {
+				fmt.Fprintf(os.Stdout, "      when: %s\n", formatCondition(task.When, params))
+			}
 			for i, step := range task.Steps {
 				fmt.Fprintf(os.Stdout, "        %d: %s \n           %s\n",
 					i+1,
 					step.GetType(),
 					aurora.Colorize(strings.ReplaceAll(step.GetDetails(), "\n", "\n           "), aurora.ItalicFm|aurora.Gray(20, "").Color()),
 				)
+				if step.GetWhen() != "" {
+					fmt.Fprintf(os.Stdout, "           when: %s\n", formatCondition(step.GetWhen(), params))
+				}
+				if len(step.GetArtifacts()) > 0 {
+					fmt.Fprintf(os.Stdout, "           artifacts: %s\n", strings.Join(step.GetArtifacts(), ", "))
+				}
+			}
+			if len(task.Blueprint.Artifacts) > 0 {
+				fmt.Fprintf(os.Stdout, "      artifacts: %s\n", strings.Join(task.Blueprint.Artifacts, ", "))
 			}
 		}
 	}
 	return nil
 }
 
+func formatCondition(when string, params map[string]*build.Parameter) string {
+	run, err := build.EvaluateCondition(when, params)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("%s %s", when, aurora.Colorize("(evaluated at runtime)", aurora.ItalicFm|aurora.Gray(20, "").Color()))
+	case run:
+		return fmt.Sprintf("%s %s", when, output.ColorFmt(output.ANSISuccess, "(runs)", ""))
+	default:
+		return fmt.Sprintf("%s %s", when, output.ColorFmt(output.ANSIWarn, fmt.Sprintf("(%s)", build.StateSkipped), ""))
+	}
+}
+
 func printHeader(header string) {
 	header = fmt.Sprintf("~ %s ~", header)
 	border := ""
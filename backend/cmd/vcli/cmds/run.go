@@ -1,17 +1,33 @@
 package cmds
 
 import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
+	"github.com/velocity-ci/velocity/backend/pkg/vcli"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
 )
 
 var (
-	runPlanOnly bool
-	runBranch   string
+	runPlanOnly       bool
+	runBranch         string
+	runArtifactsDir   string
+	runParams         []string
+	runParamFiles     []string
+	runNonInteractive bool
 )
 
 func init() {
 	runCmd.PersistentFlags().BoolVar(&runPlanOnly, "plan-only", false, "Only output the build plan")
 	runCmd.PersistentFlags().StringVar(&runBranch, "branch", "", "The branch to run with")
+	runCmd.PersistentFlags().StringVar(&runArtifactsDir, "artifacts-dir", "", "Directory to collect artifacts into (default: <project>/.velocityci/artifacts)")
+	runCmd.PersistentFlags().StringArrayVar(&runParams, "param", []string{}, "Set a parameter e.g. environment=staging (can be repeated, overrides --param-file)")
+	runCmd.PersistentFlags().StringArrayVar(&runParamFiles, "param-file", []string{}, "Set parameters from a YAML file of names to values (can be repeated, later files override earlier ones)")
+	runCmd.PersistentFlags().BoolVar(&runNonInteractive, "non-interactive", false, "Fail on parameters that are not given instead of prompting for them")
 	rootCmd.AddCommand(runCmd)
 }
 
@@ -24,3 +40,71 @@ var runCmd = &cobra.Command{
 	Args:      cobra.ExactValidArgs(1),
 	Run:       func(cmd *cobra.Command, args []string) {},
 }
+
+// newParameterResolver returns the resolver of the parameters given with --param-file and --param. Parameters are
+// resolved from --param, then --param-file, then VCI_ prefixed environment variables, then by prompting unless
+// --non-interactive, and finally from their default.
+func newParameterResolver() (*vcli.ParameterResolver, error) {
+	params := map[string]string{}
+	for _, f := range runParamFiles {
+		fileParams, err := parseParamFile(f)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range fileParams {
+			params[k] = v
+		}
+	}
+	for _, p := range runParams {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid param %q, expected <name>=<value>", p)
+		}
+		params[parts[0]] = parts[1]
+	}
+
+	return vcli.NewParameterResolver(params, runNonInteractive), nil
+}
+
+func parseParamFile(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("invalid param file %s: %s", path, err)
+	}
+
+	params := map[string]string{}
+	for k, v := range values {
+		switch x := v.(type) {
+		case string:
+			params[k] = x
+		case float64:
+			params[k] = strconv.FormatFloat(x, 'f', -1, 64)
+		case bool:
+			params[k] = strconv.FormatBool(x)
+		default:
+			return nil, fmt.Errorf("invalid param file %s: %s must be a string, number or bool", path, k)
+		}
+	}
+	return params, nil
+}
+
+// resolvePlanParameters fails with every parameter of the plan that is not given when running non-interactively,
+// else prompts for each of them once before the plan's Tasks run in parallel
+func resolvePlanParameters(plan *build.ConstructionPlan, resolver *vcli.ParameterResolver) error {
+	if resolver.NonInteractive {
+		if missing := plan.GetMissingParameters(resolver); len(missing) > 0 {
+			return fmt.Errorf("missing parameters: %s", strings.Join(missing, ", "))
+		}
+		return nil
+	}
+	for _, p := range plan.GetParameters() {
+		if _, err := resolver.Resolve(p); err != nil && p.IsRequired() {
+			return err
+		}
+	}
+	return nil
+}
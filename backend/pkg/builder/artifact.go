@@ -0,0 +1,187 @@
+package builder
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/velocity-ci/velocity/backend/pkg/phoenix"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+)
+
+// artifactChunkSize is the number of bytes of an artifact sent to the architect per message
+const artifactChunkSize = 64 << 10
+
+// artifactReplyTimeout is how long the architect has to reply to completing or fetching an artifact
+const artifactReplyTimeout = 2 * time.Minute
+
+// ArtifactStore streams artifacts to the architect in chunks over the builder's websocket connection
+type ArtifactStore struct {
+	ws     *phoenix.Client
+	Limits build.ArtifactLimits
+}
+
+func NewArtifactStore(ws *phoenix.Client) *ArtifactStore {
+	return &ArtifactStore{
+		ws:     ws,
+		Limits: build.DefaultArtifactLimits,
+	}
+}
+
+func (s *ArtifactStore) GetLimits() build.ArtifactLimits {
+	return s.Limits
+}
+
+// Save streams the artifact to the architect, which verifies it against its size and checksum once it is complete
+func (s *ArtifactStore) Save(t *build.Task, a *build.Artifact, r io.Reader) error {
+	buf := make([]byte, artifactChunkSize)
+	var offset int64
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			s.ws.Socket.Send(&phoenix.PhoenixMessage{
+				Event: fmt.Sprintf("%sartifact:chunk", eventBuildPrefix),
+				Topic: PoolTopic,
+				Payload: &ArtifactChunkPayload{
+					TaskID: t.ID,
+					Path:   a.Path,
+					Offset: offset,
+					Data:   base64.StdEncoding.EncodeToString(buf[:n]),
+				},
+			}, false)
+			offset += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := request(s.ws, &phoenix.PhoenixMessage{
+		Event: fmt.Sprintf("%sartifact:complete", eventBuildPrefix),
+		Topic: PoolTopic,
+		Payload: &ArtifactCompletePayload{
+			TaskID: t.ID,
+			StepID: a.StepID,
+			Path:   a.Path,
+			Size:   a.Size,
+			SHA256: a.SHA256,
+			Mode:   uint32(a.Mode),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("architect did not save artifact %s: %s", a.Path, err)
+	}
+
+	return nil
+}
+
+// request sends the message to the architect and returns its ok reply
+func request(ws *phoenix.Client, m *phoenix.PhoenixMessage) (*phoenix.PhoenixReplyPayload, error) {
+	replies := make(chan *phoenix.PhoenixReplyPayload, 1)
+	go func() {
+		replies <- ws.Socket.Send(m, true)
+	}()
+
+	select {
+	case reply := <-replies:
+		if reply == nil {
+			return nil, fmt.Errorf("no reply")
+		}
+		if reply.Status != phoenix.ResponseOK {
+			return nil, fmt.Errorf("%s: %v", reply.Status, reply.Response)
+		}
+		return reply, nil
+	case <-time.After(artifactReplyTimeout):
+		return nil, fmt.Errorf("no reply within %s", artifactReplyTimeout)
+	}
+}
+
+// Load fetches the artifact from the architect in chunks as it is read
+func (s *ArtifactStore) Load(taskID string, a *build.Artifact) (io.ReadCloser, error) {
+	return &artifactReader{ws: s.ws, taskID: taskID, artifact: a}, nil
+}
+
+type artifactReader struct {
+	ws       *phoenix.Client
+	taskID   string
+	artifact *build.Artifact
+
+	offset int64
+	buffer []byte
+}
+
+func (r *artifactReader) Read(p []byte) (int, error) {
+	if len(r.buffer) < 1 {
+		if r.offset >= r.artifact.Size {
+			return 0, io.EOF
+		}
+		chunk, err := r.fetch()
+		if err != nil {
+			return 0, err
+		}
+		if len(chunk) < 1 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		r.buffer = chunk
+		r.offset += int64(len(chunk))
+	}
+
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
+
+func (r *artifactReader) fetch() ([]byte, error) {
+	reply, err := request(r.ws, &phoenix.PhoenixMessage{
+		Event: fmt.Sprintf("%sartifact:fetch", eventBuildPrefix),
+		Topic: PoolTopic,
+		Payload: &ArtifactFetchPayload{
+			TaskID: r.taskID,
+			Path:   r.artifact.Path,
+			Offset: r.offset,
+			Length: artifactChunkSize,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("architect did not return artifact %s: %s", r.artifact.Path, err)
+	}
+	response, ok := reply.Response.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid response for artifact %s", r.artifact.Path)
+	}
+	data, _ := response["data"].(string)
+
+	return base64.StdEncoding.DecodeString(data)
+}
+
+func (r *artifactReader) Close() error {
+	return nil
+}
+
+type ArtifactChunkPayload struct {
+	TaskID string `json:"taskId"`
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	// Data is base64 encoded
+	Data string `json:"data"`
+}
+
+type ArtifactCompletePayload struct {
+	TaskID string `json:"taskId"`
+	StepID string `json:"stepId"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Mode   uint32 `json:"mode"`
+}
+
+type ArtifactFetchPayload struct {
+	TaskID string `json:"taskId"`
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Length int    `json:"length"`
+}
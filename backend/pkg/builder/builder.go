@@ -99,6 +99,12 @@ func (b *Builder) connect() {
 		}
 	}
 	eventHandlers[EventJobStop] = func(*phoenix.PhoenixMessage) error {
+		for _, j := range jobs {
+			if err := j.Stop(b.ws); err != nil {
+				logging.GetLogger().Error("could not stop job", zap.String("jobID", j.GetID()), zap.Error(err))
+				return err
+			}
+		}
 		return nil
 	}
 	ws, err := phoenix.NewClient(wsAddress, eventHandlers)
@@ -2,7 +2,9 @@ package builder
 
 import (
 	"fmt"
-	"os"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
 )
 
 type ParameterResolver struct {
@@ -15,16 +17,11 @@ func NewParameterResolver(params map[string]string) *ParameterResolver {
 	}
 }
 
-func (pR *ParameterResolver) Resolve(paramName string) (string, error) {
-
-	if val, ok := pR.Params[paramName]; ok {
+// Resolve resolves parameters from the job's parameters, then VCI_ prefixed environment variables
+func (pR *ParameterResolver) Resolve(p *config.ParameterBasic) (string, error) {
+	if val, ok := build.GetParameterValue(p.Name, pR.Params); ok {
 		return val, nil
 	}
 
-	fromEnv := os.Getenv(fmt.Sprintf("VCI_%s", paramName))
-	if len(fromEnv) > 0 {
-		return fromEnv, nil
-	}
-
-	return "", fmt.Errorf("parameter %s not defined", paramName)
+	return "", fmt.Errorf("parameter %s not defined", p.Name)
 }
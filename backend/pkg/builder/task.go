@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
@@ -67,23 +66,27 @@ func (j *Task) Do(ws *phoenix.Client) error {
 		Address:    j.Project.Address,
 		PrivateKey: j.Project.PrivateKey,
 	}, j.Branch, j.Commit)
+	j.Task.ArtifactStore = NewArtifactStore(ws)
 
 	// TODO: add knownhost file management
 
 	j.Task.Execute(emitter)
 
-	wd, _ := os.Getwd()
-	if strings.HasPrefix(wd, WorkspaceDir) {
-		os.RemoveAll(wd)
+	// Setup clones into a unique workspace per task rather than changing the working directory
+	if j.Task.ProjectRoot != "" {
+		os.RemoveAll(j.Task.ProjectRoot)
 	}
 	logging.GetLogger().Info("completed task", zap.String("taskID", j.ID))
-	os.Chdir(WorkspaceDir)
 	return nil
 }
 
+// Stop cancels the running task, interrupting its Docker API calls
 func (j *Task) Stop(ws *phoenix.Client) error {
-
-	return nil
+	if j.Task == nil {
+		return nil
+	}
+	logging.GetLogger().Info("stopping task", zap.String("taskID", j.ID))
+	return j.Task.Stop()
 }
 
 type BuildLogLine struct {
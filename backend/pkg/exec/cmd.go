@@ -1,9 +1,11 @@
 package exec
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-cmd/cmd"
@@ -13,43 +15,78 @@ import (
 )
 
 func Run(shCmd []string, directory string, env []string, writer io.Writer) cmd.Status {
+	return RunContext(context.Background(), shCmd, directory, env, writer)
+}
+
+// RunContext runs the command like Run, stopping it when the context is done
+func RunContext(ctx context.Context, shCmd []string, directory string, env []string, writer io.Writer) cmd.Status {
+	if ctx.Err() != nil {
+		return cmd.Status{Cmd: shCmd[0], Error: ctx.Err(), Exit: -1}
+	}
 	opts := cmd.Options{Buffered: false, Streaming: true}
 	c := cmd.NewCmdOptions(opts, shCmd[0], shCmd[1:]...)
 	c.Env = respectProxyEnv(env)
 	c.Dir = directory
 	stdout := []string{}
 	stderr := []string{}
+	var outputMutex sync.Mutex
+	var outputWait sync.WaitGroup
+	outputWait.Add(2)
 	go func() {
+		defer outputWait.Done()
 		for line := range c.Stdout {
 			if writer != nil {
 				writer.Write([]byte(line))
 			}
+			outputMutex.Lock()
 			stdout = append(stdout, line)
+			outputMutex.Unlock()
 		}
 	}()
 	go func() {
+		defer outputWait.Done()
 		for line := range c.Stderr {
 			if writer != nil {
 				writer.Write([]byte(line))
 			}
+			outputMutex.Lock()
 			stderr = append(stderr, line)
+			outputMutex.Unlock()
 		}
 	}()
 
 	logging.GetLogger().Debug("running command", zap.Strings("cmd", shCmd), zap.String("directory", directory))
+	// commands without a deadline are stopped if they have not output anything after 5s
+	if _, ok := ctx.Deadline(); !ok {
+		go func() {
+			<-time.After(5 * time.Second)
+			outputMutex.Lock()
+			silent := len(stdout) < 1 && len(stderr) < 1
+			outputMutex.Unlock()
+			if !c.Status().Complete && silent {
+				logging.GetLogger().Debug("5s", zap.Strings("cmd", shCmd), zap.Int("status", c.Status().Exit))
+				c.Stop()
+			}
+		}()
+	}
+	s := c.Start()
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		<-time.After(5 * time.Second)
-		if !c.Status().Complete && (len(stdout) < 1 && len(stderr) < 1) {
-			logging.GetLogger().Debug("5s", zap.Strings("cmd", shCmd), zap.Strings("stdout", stdout), zap.Strings("stderr", stderr), zap.Int("status", c.Status().Exit))
+		select {
+		case <-ctx.Done():
 			c.Stop()
+		case <-done:
 		}
 	}()
-	s := c.Start()
 
 	finalStatus := <-s
+	if ctx.Err() != nil && finalStatus.Error == nil {
+		finalStatus.Error = ctx.Err()
+	}
 	close(c.Stdout)
 	close(c.Stderr)
-	time.Sleep(10 * time.Millisecond)
+	outputWait.Wait()
 	finalStatus.Stdout = stdout
 	finalStatus.Stderr = stderr
 
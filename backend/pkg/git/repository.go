@@ -169,6 +169,28 @@ func Clone(
 	return &RawRepository{Directory: dir, Repository: r}, nil
 }
 
+// UpdateSubmodules checks out the submodules of the checked out commit
+func (r *RawRepository) UpdateSubmodules(writer io.Writer) error {
+	r.RLock()
+	defer r.RUnlock()
+
+	deferFunc, err := handleGitSSH(r.Repository)
+	if err != nil {
+		return err
+	}
+	defer deferFunc(r.Repository)
+
+	shCmd := []string{"git", "submodule", "update", "--init", "--recursive"}
+	s := exec.Run(shCmd, r.Directory, []string{}, writer)
+	if err := exec.GetStatusError(s); err != nil {
+		return err
+	}
+
+	logging.GetLogger().Debug("updated submodules", zap.String("repository", r.Directory))
+
+	return nil
+}
+
 func (r *RawRepository) Clean() error {
 	r.RLock()
 	defer r.RUnlock()
@@ -3,33 +3,83 @@ package vcli
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
 )
 
 // ParameterResolver implements the Resolver interface
-type ParameterResolver struct{}
+type ParameterResolver struct {
+	// Params are the parameters given with --param-file and --param
+	Params map[string]string
+	// NonInteractive fails parameters that are not given instead of prompting for them
+	NonInteractive bool
+
+	// mutex stops parallel Tasks from prompting at the same time
+	mutex   sync.Mutex
+	reader  *bufio.Reader
+	answers map[string]string
+}
+
+// NewParameterResolver returns a new parameter resolver
+func NewParameterResolver(params map[string]string, nonInteractive bool) *ParameterResolver {
+	return &ParameterResolver{
+		Params:         params,
+		NonInteractive: nonInteractive,
+		reader:         bufio.NewReader(os.Stdin),
+		answers:        map[string]string{},
+	}
+}
+
+// Resolve resolves parameters from the given parameters, then VCI_ prefixed environment variables, then Stdin.
+// Parameters are only prompted for once.
+func (pR *ParameterResolver) Resolve(p *config.ParameterBasic) (string, error) {
+	if val, ok := build.GetParameterValue(p.Name, pR.Params); ok {
+		return val, nil
+	}
+	if pR.NonInteractive {
+		return "", fmt.Errorf("parameter %s not defined", p.Name)
+	}
 
-// Resolve resolves parameters from Stdin
-func (pR ParameterResolver) Resolve(paramName string) (string, error) {
+	pR.mutex.Lock()
+	defer pR.mutex.Unlock()
+	if val, ok := pR.answers[p.Name]; ok {
+		return val, nil
+	}
 
 	var text string
-	reader := bufio.NewReader(os.Stdin)
 
-	fromEnv := os.Getenv(paramName)
+	if len(p.Description) > 0 {
+		fmt.Fprintf(os.Stdout, "\n%s", p.Description)
+	}
+	if p.GetKind() == config.ParameterKindEnum {
+		fmt.Fprintf(os.Stdout, "\none of: %s", strings.Join(p.GetOptions(), ", "))
+	}
 
-	for text == "" {
-		if len(fromEnv) > 0 {
-			fmt.Fprintf(os.Stdout, "\nEnter value for %s (%s): ", paramName, fromEnv)
+	for {
+		if len(p.Default) > 0 {
+			fmt.Fprintf(os.Stdout, "\nEnter value for %s (%s): ", p.Name, p.Default)
 		} else {
-			fmt.Fprintf(os.Stdout, "\nEnter value for %s: ", paramName)
+			fmt.Fprintf(os.Stdout, "\nEnter value for %s: ", p.Name)
+		}
+		line, err := pR.reader.ReadString('\n')
+		text = strings.TrimSpace(line)
+		if text != "" || !p.IsRequired() {
+			break
+		}
+		if err == io.EOF {
+			return "", fmt.Errorf("parameter %s not defined: stdin is closed", p.Name)
 		}
-		text, _ = reader.ReadString('\n')
-		if text == "\n" {
-			text = fromEnv
+		if err != nil {
+			return "", err
 		}
 	}
 
 	fmt.Fprintf(os.Stdout, "\n")
-	return strings.TrimSpace(text), nil
+	pR.answers[p.Name] = text
+	return text, nil
 }
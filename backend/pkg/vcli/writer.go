@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"math/rand"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/logrusorgru/aurora"
@@ -274,6 +275,9 @@ func (e *Emitter) GetTaskWriter(task *build.Task) build.TaskWriter {
 	return NewStdOutWriter("")
 }
 
+// stdOutMutex serialises writes to Stdout from writers of concurrently running tasks
+var stdOutMutex sync.Mutex
+
 // StdOutWriter represents a writer that prints to Stdout
 type StdOutWriter struct {
 	uuid        []byte
@@ -296,6 +300,8 @@ func NewStdOutWriter(prefix string) *StdOutWriter {
 // Write writes to Stdout
 func (w *StdOutWriter) Write(p []byte) (n int, err error) {
 	// logging.GetLogger().Debug("write", zap.String("line", string(p)))
+	stdOutMutex.Lock()
+	defer stdOutMutex.Unlock()
 	for _, char := range string(p) {
 		if char == '\r' {
 			w.buffer.WriteRune(char)
@@ -0,0 +1,344 @@
+package build
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+)
+
+// Artifact is a file collected from a Task's workspace
+type Artifact struct {
+	// Path is relative to the project root and always uses forward slashes
+	Path   string      `json:"path"`
+	Size   int64       `json:"size"`
+	SHA256 string      `json:"sha256"`
+	Mode   os.FileMode `json:"mode"`
+	// StepID is the ID of the step that produced the artifact. It is empty for artifacts collected after the whole Task.
+	StepID string `json:"stepId"`
+}
+
+// ArtifactLimits are the size limits, in bytes, enforced before artifacts are saved. 0 means unlimited.
+type ArtifactLimits struct {
+	MaxFileSize  int64
+	MaxTotalSize int64
+}
+
+// DefaultArtifactLimits are used by the provided ArtifactStores
+var DefaultArtifactLimits = ArtifactLimits{
+	MaxFileSize:  512 << 20,
+	MaxTotalSize: 2 << 30,
+}
+
+// ArtifactSource is a Task whose artifacts are restored into the workspace during Setup
+type ArtifactSource struct {
+	TaskID    string      `json:"taskId"`
+	Blueprint string      `json:"blueprint"`
+	Artifacts []*Artifact `json:"artifacts"`
+	// Dir is the slash separated directory, relative to the project root, that the artifacts are restored into.
+	// It is only set when the Blueprint runs as more than one Task, so that their artifacts do not overwrite each other.
+	Dir string `json:"dir"`
+}
+
+// ArtifactStore persists artifacts collected from a Task's workspace
+type ArtifactStore interface {
+	GetLimits() ArtifactLimits
+	Save(t *Task, a *Artifact, r io.Reader) error
+	Load(taskID string, a *Artifact) (io.ReadCloser, error)
+}
+
+// LocalArtifactStore saves artifacts into <Directory>/<Task ID>/<Artifact Path>
+type LocalArtifactStore struct {
+	Directory string
+	Limits    ArtifactLimits
+}
+
+func NewLocalArtifactStore(directory string) *LocalArtifactStore {
+	return &LocalArtifactStore{
+		Directory: directory,
+		Limits:    DefaultArtifactLimits,
+	}
+}
+
+func (s *LocalArtifactStore) GetLimits() ArtifactLimits {
+	return s.Limits
+}
+
+func (s *LocalArtifactStore) Save(t *Task, a *Artifact, r io.Reader) error {
+	dest := filepath.Join(s.Directory, t.ID, filepath.FromSlash(a.Path))
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (s *LocalArtifactStore) Load(taskID string, a *Artifact) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.Directory, taskID, filepath.FromSlash(a.Path)))
+}
+
+// collectArtifacts saves the files matching the given patterns into the Task's ArtifactStore
+func (t *Task) collectArtifacts(stepID string, patterns []string, writer io.Writer) error {
+	if len(patterns) < 1 {
+		return nil
+	}
+	if t.ArtifactStore == nil {
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIWarn, "-> no artifact store configured, not collecting %s", "\n"), strings.Join(patterns, ", "))
+		return nil
+	}
+
+	paths, err := matchArtifactPaths(t.ProjectRoot, patterns)
+	if err != nil {
+		return err
+	}
+	if len(paths) < 1 {
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIWarn, "-> no artifacts matched %s", "\n"), strings.Join(patterns, ", "))
+		return nil
+	}
+
+	for _, p := range paths {
+		artifact, err := newArtifact(t.ProjectRoot, p, stepID)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(filepath.Join(t.ProjectRoot, filepath.FromSlash(p)))
+		if err != nil {
+			return err
+		}
+		err = t.addArtifact(artifact, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> collected artifact %s (%d bytes, sha256:%s)", "\n"), artifact.Path, artifact.Size, artifact.SHA256)
+	}
+
+	return nil
+}
+
+// addArtifact saves the artifact into the Task's ArtifactStore if it is within the store's limits
+func (t *Task) addArtifact(a *Artifact, r io.Reader) error {
+	limits := t.ArtifactStore.GetLimits()
+	if limits.MaxFileSize > 0 && a.Size > limits.MaxFileSize {
+		return fmt.Errorf("artifact %s is %d bytes which exceeds the limit of %d bytes", a.Path, a.Size, limits.MaxFileSize)
+	}
+	totalSize := a.Size
+	for _, existing := range t.Artifacts {
+		totalSize += existing.Size
+	}
+	if limits.MaxTotalSize > 0 && totalSize > limits.MaxTotalSize {
+		return fmt.Errorf("artifacts exceed the total limit of %d bytes at %s", limits.MaxTotalSize, a.Path)
+	}
+
+	if err := t.ArtifactStore.Save(t, a, r); err != nil {
+		return fmt.Errorf("could not save artifact %s: %s", a.Path, err)
+	}
+	t.Artifacts = append(t.Artifacts, a)
+	return nil
+}
+
+// artifactSignatureStore saves the signatures and SBOMs of a step as artifacts of its Task in .velocityci/signatures
+type artifactSignatureStore struct {
+	task   *Task
+	stepID string
+}
+
+func (s *artifactSignatureStore) Save(name string, b []byte) (string, error) {
+	sum := sha256.Sum256(b)
+	artifact := &Artifact{
+		Path:   path.Join(".velocityci", "signatures", name),
+		Size:   int64(len(b)),
+		SHA256: hex.EncodeToString(sum[:]),
+		Mode:   0644,
+		StepID: s.stepID,
+	}
+	if err := s.task.addArtifact(artifact, bytes.NewReader(b)); err != nil {
+		return "", err
+	}
+	return artifact.Path, nil
+}
+
+func newArtifact(projectRoot, p, stepID string) (*Artifact, error) {
+	f, err := os.Open(filepath.Join(projectRoot, filepath.FromSlash(p)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Artifact{
+		Path:   p,
+		Size:   size,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+		Mode:   info.Mode().Perm(),
+		StepID: stepID,
+	}, nil
+}
+
+// restoreArtifacts writes the artifacts of the Tasks this Task takes artifacts from into its workspace
+func (t *Task) restoreArtifacts(writer io.Writer) error {
+	for _, source := range t.From {
+		if t.ArtifactStore == nil {
+			return fmt.Errorf("no artifact store configured to restore artifacts from %s", source.Blueprint)
+		}
+		if len(source.Artifacts) < 1 {
+			fmt.Fprintf(writer, output.ColorFmt(output.ANSIWarn, "-> %s has no artifacts to restore", "\n"), source.Blueprint)
+			continue
+		}
+		for _, a := range source.Artifacts {
+			if err := t.restoreArtifact(source, a); err != nil {
+				return fmt.Errorf("could not restore artifact %s from %s: %s", a.Path, source.Blueprint, err)
+			}
+			fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> restored artifact %s from %s", "\n"), path.Join(source.Dir, a.Path), source.Blueprint)
+		}
+	}
+
+	return nil
+}
+
+func (t *Task) restoreArtifact(source *ArtifactSource, a *Artifact) error {
+	for _, p := range []string{source.Dir, a.Path} {
+		if path.IsAbs(p) || isIn("..", strings.Split(p, "/")) {
+			return fmt.Errorf("artifact path must be within the project root")
+		}
+	}
+	dest := filepath.Join(t.ProjectRoot, filepath.FromSlash(source.Dir), filepath.FromSlash(a.Path))
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+
+	r, err := t.ArtifactStore.Load(source.TaskID, a)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// write to a temporary file first so a corrupt artifact never replaces a workspace file
+	tmp, err := ioutil.TempFile(filepath.Dir(dest), ".artifact-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+	if size != a.Size || hex.EncodeToString(h.Sum(nil)) != a.SHA256 {
+		return fmt.Errorf("checksum mismatch")
+	}
+	if a.Mode != 0 {
+		if err := os.Chmod(tmp.Name(), a.Mode); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp.Name(), dest)
+}
+
+// getArtifactSourceDir returns the directory that a Task's artifacts are restored into when its Blueprint runs as
+// more than one Task, e.g. "test-go-1.12" for "test (go=1.12)".
+func getArtifactSourceDir(name string) string {
+	dir := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' {
+			return r
+		}
+		return '-'
+	}, name)
+
+	return strings.Trim(artifactSourceDirSeparatorRegex.ReplaceAllString(dir, "-"), "-.")
+}
+
+var artifactSourceDirSeparatorRegex = regexp.MustCompile(`-+`)
+
+// matchArtifactPaths returns the sorted, slash separated paths of the regular files beneath projectRoot
+// that match one of the patterns, or are within a directory that does.
+func matchArtifactPaths(projectRoot string, patterns []string) ([]string, error) {
+	paths := []string{}
+	err := filepath.Walk(projectRoot, func(p string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(projectRoot, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if f.IsDir() && (rel == ".git" || rel == ".velocityci") {
+			return filepath.SkipDir
+		}
+		if !f.Mode().IsRegular() {
+			return nil
+		}
+		for _, pattern := range patterns {
+			if matchArtifactPath(pattern, rel) {
+				paths = append(paths, rel)
+				break
+			}
+		}
+		return nil
+	})
+	sort.Strings(paths)
+
+	return paths, err
+}
+
+func matchArtifactPath(pattern, p string) bool {
+	patternSegments := strings.Split(path.Clean(pattern), "/")
+	pathSegments := strings.Split(p, "/")
+	// a pattern matching a directory collects everything beneath it
+	for i := len(pathSegments); i > 0; i-- {
+		if matchGlobSegments(patternSegments, pathSegments[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlobSegments(pattern, p []string) bool {
+	if len(pattern) < 1 {
+		return len(p) < 1
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(p); i++ {
+			if matchGlobSegments(pattern[1:], p[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(p) < 1 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], p[0]); !ok {
+		return false
+	}
+	return matchGlobSegments(pattern[1:], p[1:])
+}
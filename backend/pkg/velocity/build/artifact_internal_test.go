@@ -0,0 +1,112 @@
+package build
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskRestoreArtifacts(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "velocity-artifacts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(storeDir)
+	store := NewLocalArtifactStore(storeDir)
+
+	producerRoot, err := ioutil.TempDir("", "velocity-workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(producerRoot)
+	assert.Nil(t, os.MkdirAll(filepath.Join(producerRoot, "dist"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(producerRoot, "dist", "vcli"), []byte("binary"), 0755))
+	producer := &Task{ID: "build-binaries", ProjectRoot: producerRoot, ArtifactStore: store}
+	assert.Nil(t, producer.collectArtifacts("", []string{"dist"}, ioutil.Discard))
+
+	consumerRoot, err := ioutil.TempDir("", "velocity-workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(consumerRoot)
+	consumer := &Task{
+		ProjectRoot:   consumerRoot,
+		ArtifactStore: store,
+		From: []*ArtifactSource{
+			{TaskID: producer.ID, Blueprint: "build-binaries", Artifacts: producer.Artifacts},
+		},
+	}
+	out := &bytes.Buffer{}
+	assert.Nil(t, consumer.restoreArtifacts(out))
+	assert.Contains(t, out.String(), "restored artifact dist/vcli from build-binaries")
+
+	restored := filepath.Join(consumerRoot, "dist", "vcli")
+	content, err := ioutil.ReadFile(restored)
+	assert.Nil(t, err)
+	assert.Equal(t, "binary", string(content))
+	info, err := os.Stat(restored)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// a modified artifact is not restored
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(storeDir, producer.ID, "dist", "vcli"), []byte("tampered"), 0755))
+	assert.Nil(t, os.Remove(restored))
+	assert.EqualError(t, consumer.restoreArtifacts(ioutil.Discard), "could not restore artifact dist/vcli from build-binaries: checksum mismatch")
+	_, err = os.Stat(restored)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTaskRestoreArtifactsIntoSourceDir(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "velocity-artifacts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(storeDir)
+	store := NewLocalArtifactStore(storeDir)
+
+	consumerRoot, err := ioutil.TempDir("", "velocity-workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(consumerRoot)
+	consumer := &Task{ProjectRoot: consumerRoot, ArtifactStore: store}
+	for _, goVersion := range []string{"1.12", "1.13"} {
+		producerRoot, err := ioutil.TempDir("", "velocity-workspace")
+		assert.Nil(t, err)
+		defer os.RemoveAll(producerRoot)
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(producerRoot, "coverage.out"), []byte(goVersion), 0644))
+		producer := &Task{ID: "test-" + goVersion, ProjectRoot: producerRoot, ArtifactStore: store}
+		assert.Nil(t, producer.collectArtifacts("", []string{"coverage.out"}, ioutil.Discard))
+		consumer.From = append(consumer.From, &ArtifactSource{
+			TaskID:    producer.ID,
+			Blueprint: "test (go=" + goVersion + ")",
+			Artifacts: producer.Artifacts,
+			Dir:       getArtifactSourceDir("test (go=" + goVersion + ")"),
+		})
+	}
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, consumer.restoreArtifacts(out))
+	assert.Contains(t, out.String(), "restored artifact test-go-1.12/coverage.out from test (go=1.12)")
+	for _, goVersion := range []string{"1.12", "1.13"} {
+		content, err := ioutil.ReadFile(filepath.Join(consumerRoot, "test-go-"+goVersion, "coverage.out"))
+		assert.Nil(t, err)
+		assert.Equal(t, goVersion, string(content))
+	}
+
+	consumer.From = []*ArtifactSource{{TaskID: "test-1.12", Blueprint: "test", Artifacts: consumer.From[0].Artifacts, Dir: "../test"}}
+	assert.EqualError(t, consumer.restoreArtifacts(ioutil.Discard), "could not restore artifact coverage.out from test: artifact path must be within the project root")
+}
+
+func TestArtifactSignatureStore(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "velocity-artifacts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(storeDir)
+	task := &Task{ID: "release", ArtifactStore: NewLocalArtifactStore(storeDir)}
+
+	store := &artifactSignatureStore{task: task, stepID: "push"}
+	p, err := store.Save("app@sha256_abc.sig", []byte("signature"))
+	assert.Nil(t, err)
+	assert.Equal(t, ".velocityci/signatures/app@sha256_abc.sig", p)
+	assert.Len(t, task.Artifacts, 1)
+	assert.Equal(t, "push", task.Artifacts[0].StepID)
+	assert.Equal(t, int64(len("signature")), task.Artifacts[0].Size)
+
+	content, err := ioutil.ReadFile(filepath.Join(storeDir, "release", ".velocityci", "signatures", "app@sha256_abc.sig"))
+	assert.Nil(t, err)
+	assert.Equal(t, "signature", string(content))
+}
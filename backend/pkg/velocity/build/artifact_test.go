@@ -0,0 +1,93 @@
+package build_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+)
+
+func writeWorkspaceFiles(t *testing.T, root string, files map[string]string) {
+	for p, content := range files {
+		p = filepath.Join(root, filepath.FromSlash(p))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+		assert.Nil(t, ioutil.WriteFile(p, []byte(content), 0644))
+	}
+}
+
+func TestTaskExecuteCollectsArtifacts(t *testing.T) {
+	projectRoot, err := ioutil.TempDir("", "velocity-workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(projectRoot)
+	writeWorkspaceFiles(t, projectRoot, map[string]string{
+		"dist/vcli":                     "binary",
+		"reports/unit/junit.xml":        "<testsuite/>",
+		"reports/unit/coverage.out":     "mode: set",
+		"src/main.go":                   "package main",
+		".velocityci/artifacts/old.txt": "old",
+	})
+	storeDir, err := ioutil.TempDir("", "velocity-artifacts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(storeDir)
+
+	step := newFakeStep(0)
+	step.Artifacts = []string{"dist", "**/*.xml"}
+	task := &build.Task{
+		ID:            "task",
+		Steps:         []build.Step{step},
+		ProjectRoot:   projectRoot,
+		ArtifactStore: build.NewLocalArtifactStore(storeDir),
+	}
+
+	err = task.Execute(build.NewBlankEmitter())
+	assert.Nil(t, err)
+	assert.Equal(t, []*build.Artifact{
+		{
+			Path:   "dist/vcli",
+			Size:   6,
+			SHA256: "9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd",
+			Mode:   0644,
+			StepID: "fake",
+		},
+		{
+			Path:   "reports/unit/junit.xml",
+			Size:   12,
+			SHA256: "55a2c4dabbdd641e56e0ce28262e1d43b8fff7534ced8580f39ba573eca56f2c",
+			Mode:   0644,
+			StepID: "fake",
+		},
+	}, task.Artifacts)
+
+	saved, err := ioutil.ReadFile(filepath.Join(storeDir, "task", "dist", "vcli"))
+	assert.Nil(t, err)
+	assert.Equal(t, "binary", string(saved))
+}
+
+func TestTaskExecuteFailsWhenArtifactsExceedLimits(t *testing.T) {
+	projectRoot, err := ioutil.TempDir("", "velocity-workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(projectRoot)
+	writeWorkspaceFiles(t, projectRoot, map[string]string{
+		"dist/vcli": "binary",
+	})
+	storeDir, err := ioutil.TempDir("", "velocity-artifacts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(storeDir)
+
+	store := build.NewLocalArtifactStore(storeDir)
+	store.Limits = build.ArtifactLimits{MaxFileSize: 4}
+	step := newFakeStep(0)
+	step.Artifacts = []string{"dist/*"}
+	task := &build.Task{
+		Steps:         []build.Step{step},
+		ProjectRoot:   projectRoot,
+		ArtifactStore: store,
+	}
+
+	err = task.Execute(build.NewBlankEmitter())
+	assert.EqualError(t, err, "artifact dist/vcli is 6 bytes which exceeds the limit of 4 bytes")
+	assert.Empty(t, task.Artifacts)
+}
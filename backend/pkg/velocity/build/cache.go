@@ -0,0 +1,106 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/velocity-ci/velocity/backend/pkg/git"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/docker"
+)
+
+// cacheMount is a cache volume and where it is mounted in a container
+type cacheMount struct {
+	volume *docker.Volume
+	path   string
+}
+
+// GetProjectName returns the name that cache volumes of a project are labelled with
+func GetProjectName(repository *git.Repository, projectRoot string) string {
+	if repository != nil && repository.Address != "" {
+		return slug.Make(repository.Address)
+	}
+	return slug.Make(filepath.Base(projectRoot))
+}
+
+// getCacheMounts returns the volumes for the Blueprint's caches, keyed by project, Blueprint and the cache's resolved key
+func (t *Task) getCacheMounts() ([]*cacheMount, error) {
+	mounts := []*cacheMount{}
+	for _, c := range t.Blueprint.Cache {
+		key, err := t.resolveCacheKey(c)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve key for cache %s: %s", c.Name, err)
+		}
+		project := t.project
+		if project == "" {
+			project = GetProjectName(nil, t.ProjectRoot)
+		}
+
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\n%s\n%s\n%s", project, t.Blueprint.Name, c.Name, key)
+		mounts = append(mounts, &cacheMount{
+			volume: &docker.Volume{
+				Name: fmt.Sprintf("vci-cache-%s-%s-%s", slug.Make(t.Blueprint.Name), c.Name, hex.EncodeToString(h.Sum(nil))[:12]),
+				Labels: map[string]string{
+					docker.CacheLabel:          "true",
+					docker.CacheProjectLabel:   project,
+					docker.CacheBlueprintLabel: t.Blueprint.Name,
+					docker.CacheNameLabel:      c.Name,
+					docker.CacheKeyLabel:       key,
+				},
+			},
+			path: c.Path,
+		})
+	}
+
+	return mounts, nil
+}
+
+var unresolvedParamRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// resolveCacheKey substitutes parameters into the cache's key template and appends the hash of its files
+func (t *Task) resolveCacheKey(c *config.BlueprintCache) (string, error) {
+	key := c.Key
+	for paramName, param := range t.parameters {
+		key = strings.Replace(key, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+	}
+	if match := unresolvedParamRegex.FindStringSubmatch(key); match != nil {
+		return "", fmt.Errorf("parameter %s missing", match[1])
+	}
+	if containsSecret(key, getSecrets(t.parameters)) {
+		return "", fmt.Errorf("key must not contain secrets as it is stored in a volume label")
+	}
+
+	if len(c.Files) > 0 {
+		paths, err := matchArtifactPaths(t.ProjectRoot, c.Files)
+		if err != nil {
+			return "", err
+		}
+		if len(paths) < 1 {
+			return "", fmt.Errorf("no files matched %s", strings.Join(c.Files, ", "))
+		}
+		h := sha256.New()
+		for _, p := range paths {
+			f, err := os.Open(filepath.Join(t.ProjectRoot, filepath.FromSlash(p)))
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s\n", p)
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+		key = strings.TrimPrefix(fmt.Sprintf("%s-%s", key, hex.EncodeToString(h.Sum(nil))[:12]), "-")
+	}
+
+	return key, nil
+}
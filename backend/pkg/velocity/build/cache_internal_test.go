@@ -0,0 +1,51 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/docker"
+)
+
+func TestTaskGetCacheMounts(t *testing.T) {
+	projectRoot, err := ioutil.TempDir("", "velocity-workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(projectRoot)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(projectRoot, "go.sum"), []byte("v1"), 0644))
+
+	task := &Task{
+		Blueprint: config.Blueprint{
+			Name: "shared/test",
+			Cache: []*config.BlueprintCache{
+				{Name: "go-modules", Path: "/go/pkg/mod", Key: "${git.branch}", Files: []string{"go.sum"}},
+			},
+		},
+		ProjectRoot: projectRoot,
+		project:     "velocity",
+		parameters: map[string]*Parameter{
+			"git.branch": {Name: "git.branch", Value: "master"},
+		},
+	}
+
+	mounts, err := task.getCacheMounts()
+	assert.Nil(t, err)
+	assert.Len(t, mounts, 1)
+	assert.Equal(t, "/go/pkg/mod", mounts[0].path)
+	assert.Regexp(t, "^vci-cache-shared-test-go-modules-[0-9a-f]{12}$", mounts[0].volume.Name)
+	assert.Regexp(t, "^master-[0-9a-f]{12}$", mounts[0].volume.Labels[docker.CacheKeyLabel])
+	assert.Equal(t, "velocity", mounts[0].volume.Labels[docker.CacheProjectLabel])
+
+	// the volume changes with the files' contents
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(projectRoot, "go.sum"), []byte("v2"), 0644))
+	changedMounts, err := task.getCacheMounts()
+	assert.Nil(t, err)
+	assert.NotEqual(t, mounts[0].volume.Name, changedMounts[0].volume.Name)
+
+	delete(task.parameters, "git.branch")
+	_, err = task.getCacheMounts()
+	assert.EqualError(t, err, "could not resolve key for cache go-modules: parameter git.branch missing")
+}
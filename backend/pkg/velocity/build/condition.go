@@ -0,0 +1,218 @@
+package build
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// EvaluateCondition evaluates a `when:` expression against the given parameters.
+//
+// Parameters are referenced with ${name} and compared with ==, !=, =~ (regex match) and !~.
+// Comparisons can be combined with &&, || and ! and grouped with parentheses.
+// Values can be quoted strings or bare words, e.g.
+//
+//	${git.branch} == master || ${git.describe} =~ "^v[0-9]+"
+//
+// An operand on its own is true unless it is empty, "false" or "0".
+func EvaluateCondition(expression string, params map[string]*Parameter) (bool, error) {
+	tokens, err := tokenizeCondition(expression, params)
+	if err != nil {
+		return false, err
+	}
+	p := &conditionParser{tokens: tokens}
+	result, err := p.parseOr()
+	if err != nil {
+		return false, fmt.Errorf("invalid condition %q: %s", expression, err)
+	}
+	if p.pos < len(p.tokens) {
+		return false, fmt.Errorf("invalid condition %q: unexpected %q", expression, p.tokens[p.pos].value)
+	}
+
+	return result, nil
+}
+
+type conditionTokenKind int
+
+const (
+	conditionOperand conditionTokenKind = iota
+	conditionOperator
+)
+
+type conditionToken struct {
+	kind  conditionTokenKind
+	value string
+}
+
+var conditionOperators = []string{"==", "!=", "=~", "!~", "&&", "||", "!", "(", ")"}
+
+func tokenizeCondition(expression string, params map[string]*Parameter) ([]conditionToken, error) {
+	tokens := []conditionToken{}
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			end := indexRune(runes, i+1, r)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in condition %q", expression)
+			}
+			tokens = append(tokens, conditionToken{kind: conditionOperand, value: string(runes[i+1 : end])})
+			i = end + 1
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '{':
+			end := indexRune(runes, i+2, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated parameter in condition %q", expression)
+			}
+			paramName := string(runes[i+2 : end])
+			param, ok := params[paramName]
+			if !ok {
+				return nil, fmt.Errorf("parameter %s missing", paramName)
+			}
+			tokens = append(tokens, conditionToken{kind: conditionOperand, value: param.Value})
+			i = end + 1
+		default:
+			operator := ""
+			for _, o := range conditionOperators {
+				if strings.HasPrefix(string(runes[i:]), o) {
+					operator = o
+					break
+				}
+			}
+			if operator != "" {
+				tokens = append(tokens, conditionToken{kind: conditionOperator, value: operator})
+				i += len(operator)
+				continue
+			}
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("=!&|()\"'", runes[i]) {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("unexpected %q in condition %q", runes[i], expression)
+			}
+			tokens = append(tokens, conditionToken{kind: conditionOperand, value: string(runes[start:i])})
+		}
+	}
+
+	return tokens, nil
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+}
+
+func (p *conditionParser) peekOperator(operators ...string) string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != conditionOperator {
+		return ""
+	}
+	for _, o := range operators {
+		if p.tokens[p.pos].value == o {
+			return o
+		}
+	}
+	return ""
+}
+
+func (p *conditionParser) parseOr() (bool, error) {
+	result, err := p.parseAnd()
+	if err != nil {
+		return false, err
+	}
+	for p.peekOperator("||") != "" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return false, err
+		}
+		result = result || right
+	}
+	return result, nil
+}
+
+func (p *conditionParser) parseAnd() (bool, error) {
+	result, err := p.parseUnary()
+	if err != nil {
+		return false, err
+	}
+	for p.peekOperator("&&") != "" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return false, err
+		}
+		result = result && right
+	}
+	return result, nil
+}
+
+func (p *conditionParser) parseUnary() (bool, error) {
+	if p.peekOperator("!") != "" {
+		p.pos++
+		result, err := p.parseUnary()
+		return !result, err
+	}
+	if p.peekOperator("(") != "" {
+		p.pos++
+		result, err := p.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if p.peekOperator(")") == "" {
+			return false, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return result, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return false, err
+	}
+	operator := p.peekOperator("==", "!=", "=~", "!~")
+	if operator == "" {
+		return left != "" && left != "false" && left != "0", nil
+	}
+	p.pos++
+	right, err := p.parseOperand()
+	if err != nil {
+		return false, err
+	}
+
+	switch operator {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	default:
+		re, err := regexp.Compile(right)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(left) == (operator == "=~"), nil
+	}
+}
+
+func (p *conditionParser) parseOperand() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", fmt.Errorf("unexpected end of condition")
+	}
+	t := p.tokens[p.pos]
+	if t.kind != conditionOperand {
+		return "", fmt.Errorf("unexpected %q", t.value)
+	}
+	p.pos++
+	return t.value, nil
+}
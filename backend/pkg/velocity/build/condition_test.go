@@ -0,0 +1,53 @@
+package build_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	params := map[string]*build.Parameter{
+		"git.branch":   {Name: "git.branch", Value: "master"},
+		"git.describe": {Name: "git.describe", Value: "v1.2.0-3-gabcdef0"},
+		"deploy":       {Name: "deploy", Value: "false"},
+	}
+
+	tests := map[string]bool{
+		`${git.branch} == master`:                                       true,
+		`${git.branch} == "master"`:                                     true,
+		`${git.branch} != 'master'`:                                     false,
+		`${git.describe} =~ "^v[0-9]+\.[0-9]+\.[0-9]+$"`:                false,
+		`${git.describe} !~ "^v[0-9]+\.[0-9]+\.[0-9]+$"`:                true,
+		`${git.branch} == develop || ${git.describe} =~ ^v1`:            true,
+		`${git.branch} == master && ${deploy}`:                          false,
+		`!${deploy}`:                                                    true,
+		`!(${git.branch} == develop || ${git.branch} == "release/2.0")`: true,
+		`${git.branch}`:                                                 true,
+	}
+
+	for expression, expected := range tests {
+		result, err := build.EvaluateCondition(expression, params)
+		assert.Nil(t, err, expression)
+		assert.Equal(t, expected, result, expression)
+	}
+}
+
+func TestEvaluateConditionErrors(t *testing.T) {
+	params := map[string]*build.Parameter{
+		"git.branch": {Name: "git.branch", Value: "master"},
+	}
+
+	for _, expression := range []string{
+		`${unknown} == master`,
+		`${git.branch} ==`,
+		`(${git.branch} == master`,
+		`${git.branch} == "master`,
+		`${git.branch} master`,
+		`${git.branch} =~ "("`,
+	} {
+		_, err := build.EvaluateCondition(expression, params)
+		assert.Error(t, err, expression)
+	}
+}
@@ -1,14 +1,20 @@
 package build
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/git"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"go.uber.org/zap"
 )
 
-// Stage represents a set of Tasks that can run in parallel, therefore Tasks is a map[TaskID]Task.
+// Stage represents a set of Tasks at the same depth of the dependency graph that can run in parallel, therefore Tasks is a map[TaskID]Task.
 type Stage struct {
 	ID     string `json:"id"`
 	Index  uint16 `json:"index"`
@@ -22,7 +28,109 @@ type ConstructionPlan struct {
 	ID     string   `json:"id"`
 	Name   string   `json:"name"`
 	Stages []*Stage `json:"stages"`
-	// Plugins []*Plugin `json:"plugins"`
+	// Parallelism limits how many Tasks run at once. 0 means unlimited.
+	Parallelism int `json:"parallelism"`
+
+	// root is the project configuration whose plugins are run on the plan's events
+	root *config.Root
+	// rootParameters are the root configuration's parameters, resolved once for every Task when the plan is executed
+	rootParameters []*Parameter
+	// plugins runs the root configuration's plugins for the plan's events
+	plugins pluginQueue
+
+	mutex   sync.Mutex
+	stopped bool
+	// ctx is the parent of the plugins' contexts, cancelled by Stop
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// SetRoot sets the project configuration of the plan and its Tasks. Its parameters are resolved once when the plan
+// is executed instead of by every Task.
+func (p *ConstructionPlan) SetRoot(root *config.Root) {
+	p.root = root
+	for _, stage := range p.Stages {
+		for _, task := range stage.Tasks {
+			task.root = root
+		}
+	}
+}
+
+// SetArtifactStore sets the store that every Task in the plan saves its artifacts into
+func (p *ConstructionPlan) SetArtifactStore(store ArtifactStore) {
+	for _, stage := range p.Stages {
+		for _, task := range stage.Tasks {
+			task.ArtifactStore = store
+		}
+	}
+}
+
+// GetMissingParameters returns the names of the required basic parameters of the plan that the resolver cannot
+// resolve. The resolver must not prompt for parameters.
+func (p *ConstructionPlan) GetMissingParameters(resolver BackupResolver) []string {
+	names := []string{}
+	for _, param := range p.GetParameters() {
+		if !param.IsRequired() {
+			continue
+		}
+		if val, err := resolver.Resolve(param); err != nil || val == "" {
+			names = append(names, param.Name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// GetParameters returns the basic parameters of the plan's root configuration, its Tasks and the Blueprints they
+// call, once by name, leaving out those given by the steps that call the Blueprints. Resolving them before executing
+// the plan means that parallel Tasks do not each prompt for them.
+func (p *ConstructionPlan) GetParameters() []*config.ParameterBasic {
+	params := []*config.ParameterBasic{}
+	seen := map[string]bool{}
+	if p.root != nil {
+		params = addBasicParameters(params, seen, p.root.Parameters, map[string]string{}, []Step{})
+	}
+	for _, stage := range p.Stages {
+		taskNames := make([]string, 0, len(stage.Tasks))
+		for name := range stage.Tasks {
+			taskNames = append(taskNames, name)
+		}
+		sort.Strings(taskNames)
+		for _, name := range taskNames {
+			task := stage.Tasks[name]
+			params = addBasicParameters(params, seen, task.Blueprint.Parameters, map[string]string{}, task.Steps)
+		}
+	}
+
+	return params
+}
+
+func addBasicParameters(
+	params []*config.ParameterBasic,
+	seen map[string]bool,
+	configParams []config.Parameter,
+	given map[string]string,
+	steps []Step,
+) []*config.ParameterBasic {
+	for _, param := range configParams {
+		basic, ok := param.(*config.ParameterBasic)
+		if !ok || seen[basic.Name] {
+			continue
+		}
+		if _, ok := given[basic.Name]; ok {
+			continue
+		}
+		seen[basic.Name] = true
+		params = append(params, basic)
+	}
+	for _, step := range steps {
+		if sB, ok := step.(*StepBlueprint); ok {
+			params = addBasicParameters(params, seen, sB.Blueprint.Parameters, sB.Parameters, sB.Steps)
+		}
+	}
+
+	return params
 }
 
 func NewConstructionPlanFromBlueprint(
@@ -33,32 +141,38 @@ func NewConstructionPlanFromBlueprint(
 	branch string,
 	commitSha string,
 	projectRoot string,
+	matrixFilter map[string]string,
 ) (*ConstructionPlan, error) {
 	targetBlueprint, err := getRequestedBlueprintByName(targetBlueprintName, blueprints)
 	if err != nil {
 		return nil, err
 	}
-	task := NewTask(
+	tasks, err := newTasksFromBlueprint(
 		targetBlueprint,
+		blueprints,
 		paramResolver,
 		repository,
 		branch,
 		commitSha,
 		projectRoot,
+		matrixFilter,
 	)
+	if err != nil {
+		return nil, err
+	}
+	stage := &Stage{
+		ID:     uuid.NewV4().String(),
+		Index:  1,
+		Status: StateWaiting,
+		Tasks:  map[string]*Task{},
+	}
+	for _, task := range tasks {
+		stage.Tasks[task.ID] = task
+	}
 	return &ConstructionPlan{
-		ID:   uuid.NewV4().String(),
-		Name: fmt.Sprintf("Blueprint: %s", targetBlueprintName),
-		Stages: []*Stage{
-			{
-				ID:     uuid.NewV4().String(),
-				Index:  1,
-				Status: StateWaiting,
-				Tasks: map[string]*Task{
-					task.ID: task,
-				},
-			},
-		},
+		ID:     uuid.NewV4().String(),
+		Name:   fmt.Sprintf("Blueprint: %s", targetBlueprintName),
+		Stages: []*Stage{stage},
 	}, nil
 }
 
@@ -76,66 +190,330 @@ func NewConstructionPlanFromPipeline(
 	if err != nil {
 		return nil, err
 	}
+	if len(targetPipeline.ValidationErrors) > 0 {
+		return nil, fmt.Errorf("invalid pipeline %s: %s", targetPipelineName, strings.Join(targetPipeline.ValidationErrors, ", "))
+	}
 
 	cP := &ConstructionPlan{
-		ID:     uuid.NewV4().String(),
-		Name:   fmt.Sprintf("Pipeline: %s", targetPipelineName),
-		Stages: []*Stage{},
+		ID:          uuid.NewV4().String(),
+		Name:        fmt.Sprintf("Pipeline: %s", targetPipelineName),
+		Stages:      []*Stage{},
+		Parallelism: targetPipeline.Parallelism,
 	}
 
-	for i, stage := range targetPipeline.Stages {
-		newStage := &Stage{
-			ID:     uuid.NewV4().String(),
-			Index:  uint16(i + 1),
-			Status: StateWaiting,
-			Tasks:  map[string]*Task{},
+	graph := targetPipeline.GetBlueprintGraph()
+	// Blueprints with a matrix have a Task for each combination
+	tasksByBlueprint := map[string][]*Task{}
+	nodesByID := map[string]*config.PipelineBlueprint{}
+	for _, pipelineBlueprint := range graph {
+		nodesByID[pipelineBlueprint.ID] = pipelineBlueprint
+		blueprint, err := getRequestedBlueprintByName(pipelineBlueprint.Name, blueprints)
+		if err != nil {
+			return nil, err
 		}
-		for _, blueprintName := range stage.Blueprints {
-			blueprint, err := getRequestedBlueprintByName(blueprintName, blueprints)
-			if err != nil {
-				return nil, err
+		tasks, err := newTasksFromBlueprint(
+			blueprint,
+			blueprints,
+			paramResolver,
+			repository,
+			branch,
+			commitSha,
+			projectRoot,
+			nil,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tasksByBlueprint[pipelineBlueprint.ID] = tasks
+	}
+
+	// Stages group Tasks by their depth in the dependency graph
+	depths := getBlueprintDepths(graph)
+	for _, pipelineBlueprint := range graph {
+		depth := depths[pipelineBlueprint.ID]
+		for len(cP.Stages) <= depth {
+			cP.Stages = append(cP.Stages, &Stage{
+				ID:     uuid.NewV4().String(),
+				Index:  uint16(len(cP.Stages) + 1),
+				Status: StateWaiting,
+				Tasks:  map[string]*Task{},
+			})
+		}
+
+		for _, task := range tasksByBlueprint[pipelineBlueprint.ID] {
+			task.When = pipelineBlueprint.When
+			for _, need := range pipelineBlueprint.Needs {
+				for _, neededTask := range tasksByBlueprint[need] {
+					task.Needs = append(task.Needs, neededTask.ID)
+				}
+			}
+			fromTaskCounts := map[string]int{}
+			for _, from := range pipelineBlueprint.From {
+				fromTaskCounts[nodesByID[from].Name] += len(tasksByBlueprint[from])
+			}
+			for _, from := range pipelineBlueprint.From {
+				fromNode := nodesByID[from]
+				for _, fromTask := range tasksByBlueprint[from] {
+					source := &ArtifactSource{
+						TaskID:    fromTask.ID,
+						Blueprint: fromTask.GetName(),
+						Artifacts: []*Artifact{},
+					}
+					// each Task of a Blueprint that runs more than once restores into its own directory
+					if fromTaskCounts[fromNode.Name] > 1 {
+						source.Dir = getArtifactSourceDir(fromNode.ID + strings.TrimPrefix(fromTask.GetName(), fromNode.Name))
+					}
+					task.From = append(task.From, source)
+				}
 			}
-			newTask := NewTask(
-				blueprint,
-				paramResolver,
-				repository,
-				branch,
-				commitSha,
-				projectRoot,
-			)
-			newStage.Tasks[newTask.ID] = newTask
+			cP.Stages[depth].Tasks[task.ID] = task
 		}
-		cP.Stages = append(cP.Stages, newStage)
 	}
 
 	return cP, nil
 }
 
+func getBlueprintDepths(graph []*config.PipelineBlueprint) map[string]int {
+	needs := map[string][]string{}
+	for _, b := range graph {
+		needs[b.ID] = b.Needs
+	}
+
+	depths := map[string]int{}
+	var getDepth func(name string) int
+	getDepth = func(name string) int {
+		if depth, ok := depths[name]; ok {
+			return depth
+		}
+		depth := 0
+		for _, need := range needs[name] {
+			if d := getDepth(need) + 1; d > depth {
+				depth = d
+			}
+		}
+		depths[name] = depth
+		return depth
+	}
+	for _, b := range graph {
+		getDepth(b.ID)
+	}
+
+	return depths
+}
+
 func (p *ConstructionPlan) Execute(emitter Emitter) error {
+	// derived parameters are only resolved again by the plan's Tasks once they have expired
+	cache := newDerivedParameterCache()
+	for _, task := range p.getSortedTasks() {
+		task.derivedCache = cache
+	}
+	if err := p.resolveRootParameters(); err != nil {
+		return err
+	}
+
+	// the plan only completes once the plugins of its events have run
+	defer p.plugins.wait()
 	eventBuildStart(p)
 	defer eventBuildComplete(p)
-	for _, stage := range p.Stages {
-		for _, task := range stage.Tasks {
-			eventTaskStart(p, task)
-			err := task.Execute(emitter)
-			eventTaskComplete(p, task)
-			if err != nil {
-				eventTaskFail(p, task, err)
-				if !task.IgnoreErrors {
-					eventBuildFail(p, task, err)
-					return err
+
+	task, err := p.executeGraph(emitter)
+	if err != nil {
+		eventBuildFail(p, task, err)
+		return err
+	}
+
+	eventBuildSuccess(p)
+
+	return nil
+}
+
+type taskResult struct {
+	task *Task
+	err  error
+}
+
+// resolveRootParameters resolves the root configuration's parameters with the Tasks' resolver and gives them to
+// every Task
+func (p *ConstructionPlan) resolveRootParameters() error {
+	tasks := p.getSortedTasks()
+	if p.root == nil || len(tasks) < 1 {
+		return nil
+	}
+	if len(p.root.ValidationErrors) > 0 {
+		return fmt.Errorf("invalid project configuration: %s", strings.Join(p.root.ValidationErrors, ", "))
+	}
+
+	resolver := tasks[0].getBackupResolver()
+	params := []*Parameter{}
+	errs := []string{}
+	for _, configParam := range p.root.Parameters {
+		resolvedParams, err := resolveConfigParameter(p.getContext(), configParam, resolver, BlankWriter{}, tasks[0].getDerivedParameterCache())
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		params = append(params, resolvedParams...)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("could not resolve %s", strings.Join(errs, ", "))
+	}
+
+	p.rootParameters = params
+	for _, task := range tasks {
+		task.rootParameters = params
+	}
+	return nil
+}
+
+// executeGraph runs each Task as soon as the Tasks it needs have succeeded, bounded by the plan's Parallelism.
+// When a Task fails without ignoreErrors, the other Tasks are stopped and no further Tasks are started.
+// The failing Task and its error are returned.
+func (p *ConstructionPlan) executeGraph(emitter Emitter) (*Task, error) {
+	tasks := p.getSortedTasks()
+	parallelism := p.Parallelism
+	if parallelism < 1 || parallelism > len(tasks) {
+		parallelism = len(tasks)
+	}
+
+	pending := map[string]*Task{}
+	tasksByID := map[string]*Task{}
+	for _, task := range tasks {
+		pending[task.ID] = task
+		tasksByID[task.ID] = task
+	}
+	started := map[string]bool{}
+	completed := map[string]bool{}
+	failed := map[string]bool{}
+	results := make(chan taskResult)
+	running := 0
+
+	var (
+		failedTask *Task
+		failedErr  error
+	)
+	for {
+		for _, task := range tasks {
+			if failedErr != nil || running >= parallelism {
+				break
+			}
+			if _, ok := pending[task.ID]; !ok || !isTaskReady(task, completed) {
+				continue
+			}
+			delete(pending, task.ID)
+			// the Tasks this Task takes artifacts from have completed as it needs them
+			for _, source := range task.From {
+				source.Artifacts = tasksByID[source.TaskID].Artifacts
+			}
+			started[task.ID] = true
+			running++
+			p.updateStageStatuses(started, completed, failed)
+			go func(task *Task) {
+				eventTaskStart(p, task)
+				err := task.Execute(emitter)
+				eventTaskComplete(p, task)
+				results <- taskResult{task: task, err: err}
+			}(task)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+		if result.err != nil {
+			eventTaskFail(p, result.task, result.err)
+			if !result.task.IgnoreErrors {
+				failed[result.task.ID] = true
+				p.updateStageStatuses(started, completed, failed)
+				if failedErr == nil {
+					failedTask, failedErr = result.task, result.err
+					stopOtherTasks(tasks, result.task)
 				}
+				continue
 			}
-			eventTaskSuccess(p, task)
+		} else {
+			eventTaskSuccess(p, result.task)
 		}
+		completed[result.task.ID] = true
+		p.updateStageStatuses(started, completed, failed)
 	}
 
-	eventBuildSuccess(p)
+	if failedErr == nil && len(pending) > 0 {
+		failedErr = fmt.Errorf("%d tasks could not be scheduled", len(pending))
+	}
 
-	return nil
+	return failedTask, failedErr
+}
+
+func isTaskReady(task *Task, completed map[string]bool) bool {
+	for _, need := range task.Needs {
+		if !completed[need] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *ConstructionPlan) updateStageStatuses(started, completed, failed map[string]bool) {
+	for _, stage := range p.Stages {
+		stage.Status = StateWaiting
+		completedTasks := 0
+		for taskID := range stage.Tasks {
+			switch {
+			case failed[taskID]:
+				stage.Status = StateFailed
+			case completed[taskID]:
+				completedTasks++
+			}
+			if started[taskID] && stage.Status == StateWaiting {
+				stage.Status = StateBuilding
+			}
+		}
+		if stage.Status != StateFailed && completedTasks == len(stage.Tasks) {
+			stage.Status = StateSuccess
+		}
+	}
+}
+
+func stopOtherTasks(tasks []*Task, failedTask *Task) {
+	for _, task := range tasks {
+		if task == failedTask {
+			continue
+		}
+		if err := task.Stop(); err != nil {
+			logging.GetLogger().Error("could not stop task", zap.String("taskID", task.ID), zap.Error(err))
+		}
+	}
+}
+
+// getSortedTasks returns the plan's Tasks ordered by Stage and name so they are started deterministically.
+func (p *ConstructionPlan) getSortedTasks() []*Task {
+	tasks := []*Task{}
+	for _, stage := range p.Stages {
+		stageTasks := make([]*Task, 0, len(stage.Tasks))
+		for _, task := range stage.Tasks {
+			stageTasks = append(stageTasks, task)
+		}
+		sort.Slice(stageTasks, func(i, j int) bool {
+			if stageTasks[i].GetName() == stageTasks[j].GetName() {
+				return stageTasks[i].ID < stageTasks[j].ID
+			}
+			return stageTasks[i].GetName() < stageTasks[j].GetName()
+		})
+		tasks = append(tasks, stageTasks...)
+	}
+
+	return tasks
 }
 
 func (p *ConstructionPlan) Stop() error {
+	p.mutex.Lock()
+	p.stopped = true
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.mutex.Unlock()
 	for _, stage := range p.Stages {
 		for _, task := range stage.Tasks {
 			err := task.Stop()
@@ -148,6 +526,19 @@ func (p *ConstructionPlan) Stop() error {
 	return nil
 }
 
+// getContext returns the plan's context, which is cancelled when the plan is stopped
+func (p *ConstructionPlan) getContext() context.Context {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.ctx == nil {
+		p.ctx, p.cancel = context.WithCancel(context.Background())
+		if p.stopped {
+			p.cancel()
+		}
+	}
+	return p.ctx
+}
+
 func getRequestedBlueprintByName(blueprintName string, blueprints []*config.Blueprint) (*config.Blueprint, error) {
 	for _, b := range blueprints {
 		if b.Name == blueprintName {
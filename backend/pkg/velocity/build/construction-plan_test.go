@@ -0,0 +1,169 @@
+package build_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+)
+
+func getTaskNames(tasks map[string]*build.Task) []string {
+	names := []string{}
+	for _, task := range tasks {
+		names = append(names, task.GetName())
+	}
+	return names
+}
+
+func TestNewConstructionPlanFromBlueprintMatrix(t *testing.T) {
+	blueprints := []*config.Blueprint{
+		{
+			Name: "test",
+			Matrix: config.BlueprintMatrix{
+				"go":       {"1.12", "1.13"},
+				"postgres": {"10", "11"},
+			},
+		},
+	}
+
+	plan, err := build.NewConstructionPlanFromBlueprint("test", blueprints, nil, nil, "master", "", "", nil)
+	assert.Nil(t, err)
+	assert.Len(t, plan.Stages, 1)
+	assert.ElementsMatch(t, []string{
+		"test (go=1.12, postgres=10)",
+		"test (go=1.12, postgres=11)",
+		"test (go=1.13, postgres=10)",
+		"test (go=1.13, postgres=11)",
+	}, getTaskNames(plan.Stages[0].Tasks))
+
+	plan, err = build.NewConstructionPlanFromBlueprint("test", blueprints, nil, nil, "master", "", "", map[string]string{"go": "1.12"})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{
+		"test (go=1.12, postgres=10)",
+		"test (go=1.12, postgres=11)",
+	}, getTaskNames(plan.Stages[0].Tasks))
+
+	_, err = build.NewConstructionPlanFromBlueprint("test", blueprints, nil, nil, "master", "", "", map[string]string{"go": "1.9"})
+	assert.EqualError(t, err, "blueprint test matrix go has no value 1.9")
+	_, err = build.NewConstructionPlanFromBlueprint("test", blueprints, nil, nil, "master", "", "", map[string]string{"redis": "5"})
+	assert.EqualError(t, err, "blueprint test has no matrix redis")
+}
+
+func TestNewConstructionPlanFromPipelineMatrix(t *testing.T) {
+	blueprints := []*config.Blueprint{
+		{Name: "test", Matrix: config.BlueprintMatrix{"go": {"1.12", "1.13"}}},
+		{Name: "publish"},
+	}
+	pipelines := []*config.Pipeline{
+		{
+			Name: "release",
+			Blueprints: []*config.PipelineBlueprint{
+				{Name: "test", Needs: []string{}},
+				{Name: "publish", Needs: []string{}, From: []string{"test"}},
+			},
+		},
+	}
+
+	plan, err := build.NewConstructionPlanFromPipeline("release", pipelines, blueprints, nil, nil, "master", "", "")
+	assert.Nil(t, err)
+	assert.Len(t, plan.Stages, 2)
+	assert.ElementsMatch(t, []string{"test (go=1.12)", "test (go=1.13)"}, getTaskNames(plan.Stages[0].Tasks))
+	for _, publish := range plan.Stages[1].Tasks {
+		assert.Len(t, publish.Needs, 2)
+		assert.Len(t, publish.From, 2)
+		for _, need := range publish.Needs {
+			assert.Contains(t, plan.Stages[0].Tasks, need)
+		}
+		// each combination's artifacts are restored into their own directory
+		dirs := map[string]string{}
+		for _, source := range publish.From {
+			dirs[source.Blueprint] = source.Dir
+		}
+		assert.Equal(t, map[string]string{
+			"test (go=1.12)": "test-go-1.12",
+			"test (go=1.13)": "test-go-1.13",
+		}, dirs)
+	}
+}
+
+func TestNewConstructionPlanFromPipelineRepeatedStageBlueprints(t *testing.T) {
+	blueprints := []*config.Blueprint{{Name: "migrate"}, {Name: "deploy"}}
+	pipelines := []*config.Pipeline{
+		{
+			Name: "release",
+			Stages: []*config.Stage{
+				{Blueprints: []*config.PipelineBlueprint{{Name: "migrate"}}},
+				{Blueprints: []*config.PipelineBlueprint{{Name: "deploy"}}},
+				{Blueprints: []*config.PipelineBlueprint{{Name: "migrate"}}},
+			},
+		},
+	}
+
+	plan, err := build.NewConstructionPlanFromPipeline("release", pipelines, blueprints, nil, nil, "master", "", "")
+	assert.Nil(t, err)
+	assert.Len(t, plan.Stages, 3)
+	assert.Equal(t, []string{"migrate"}, getTaskNames(plan.Stages[0].Tasks))
+	assert.Equal(t, []string{"deploy"}, getTaskNames(plan.Stages[1].Tasks))
+	assert.Equal(t, []string{"migrate"}, getTaskNames(plan.Stages[2].Tasks))
+}
+
+type paramsResolver map[string]string
+
+func (r paramsResolver) Resolve(p *config.ParameterBasic) (string, error) {
+	if val, ok := build.GetParameterValue(p.Name, r); ok {
+		return val, nil
+	}
+	return "", fmt.Errorf("parameter %s not defined", p.Name)
+}
+
+func TestConstructionPlanGetMissingParameters(t *testing.T) {
+	blueprints := []*config.Blueprint{
+		{
+			Name: "deploy",
+			Parameters: []config.Parameter{
+				&config.ParameterBasic{Name: "environment"},
+				&config.ParameterBasic{Name: "replicas", Default: "2"},
+				&config.ParameterBasic{Name: "comment", Optional: true},
+				&config.ParameterBasic{Name: "version"},
+			},
+			Steps: []config.Step{
+				&config.StepBlueprint{
+					BaseStep:   config.BaseStep{Type: "blueprint"},
+					Name:       "notify",
+					Parameters: map[string]string{"channel": "#deploys"},
+				},
+			},
+		},
+		{
+			Name: "notify",
+			Parameters: []config.Parameter{
+				&config.ParameterBasic{Name: "channel"},
+				&config.ParameterBasic{Name: "slack_token", Secret: true},
+			},
+		},
+	}
+
+	plan, err := build.NewConstructionPlanFromBlueprint("deploy", blueprints, nil, nil, "master", "", "", nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"environment", "slack_token", "version"}, plan.GetMissingParameters(paramsResolver{}))
+	names := []string{}
+	for _, p := range plan.GetParameters() {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{"environment", "replicas", "comment", "version", "slack_token"}, names)
+
+	os.Setenv("VCI_slack_token", "xoxb")
+	defer os.Unsetenv("VCI_slack_token")
+	assert.Equal(t, []string{"version"}, plan.GetMissingParameters(paramsResolver{"environment": "staging"}))
+
+	plan.SetRoot(&config.Root{
+		Parameters: []config.Parameter{
+			&config.ParameterBasic{Name: "team"},
+		},
+	})
+	assert.Equal(t, []string{"team", "version"}, plan.GetMissingParameters(paramsResolver{"environment": "staging"}))
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/velocity-ci/velocity/backend/pkg/exec"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
 	"go.uber.org/zap"
 )
 
@@ -23,10 +24,17 @@ type TaskDocker struct {
 type DockerRegistry struct {
 	Address            string            `json:"address"`
 	Use                string            `json:"use"`
+	Version            string            `json:"version"`
+	SHA256             string            `json:"sha256"`
 	Arguments          map[string]string `json:"arguments"`
 	AuthorizationToken string            `json:"authToken"`
 }
 
+// GetPluginReference returns the reference to the binary that authenticates with the registry
+func (r DockerRegistry) GetPluginReference() plugin.Reference {
+	return plugin.Reference{Use: r.Use, Version: r.Version, SHA256: r.SHA256}
+}
+
 func dockerLogin(registry DockerRegistry, writer io.Writer, task *Task) (r DockerRegistry, _ error) {
 
 	type registryAuthConfig struct {
@@ -37,7 +45,7 @@ func dockerLogin(registry DockerRegistry, writer io.Writer, task *Task) (r Docke
 		State         string `json:"state"`
 	}
 
-	bin, err := getBinary(task.ProjectRoot, registry.Use, writer)
+	bin, err := getBinary(registry.GetPluginReference(), writer)
 	if err != nil {
 		return r, err
 	}
@@ -1,37 +1,171 @@
 package build
 
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/velocity-ci/velocity/backend/pkg/exec"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"go.uber.org/zap"
+)
+
 type Stoppable interface {
 	Stop() error
 }
 
 func eventBuildStart(plan *ConstructionPlan) {
-
+	plan.runPlugins(EventBuildStart, nil, nil)
 }
 
 func eventBuildComplete(plan *ConstructionPlan) {
-
+	plan.runPlugins(EventBuildComplete, nil, nil)
 }
 
 func eventBuildFail(plan *ConstructionPlan, task *Task, err error) {
-
+	plan.runPlugins(EventBuildFail, task, err)
 }
 
 func eventBuildSuccess(plan *ConstructionPlan) {
-
+	plan.runPlugins(EventBuildSuccess, nil, nil)
 }
 
 func eventTaskStart(plan *ConstructionPlan, task *Task) {
-
+	plan.runPlugins(EventTaskStart, task, nil)
 }
 
 func eventTaskComplete(plan *ConstructionPlan, task *Task) {
-
+	plan.runPlugins(EventTaskComplete, task, nil)
 }
 
 func eventTaskFail(plan *ConstructionPlan, task *Task, err error) {
-
+	plan.runPlugins(EventTaskFail, task, err)
 }
 
 func eventTaskSuccess(plan *ConstructionPlan, task *Task) {
+	plan.runPlugins(EventTaskSuccess, task, nil)
+}
+
+// PluginTimeout is how long a plugin may run for an event before it is killed
+var PluginTimeout = time.Minute
+
+// runPlugins queues the root configuration's plugins for the event with their arguments' parameters replaced by the
+// root parameters and, for Task events, the Task's parameters. Plugins never fail the build so their errors are
+// only logged.
+func (p *ConstructionPlan) runPlugins(event string, task *Task, eventErr error) {
+	if p.root == nil {
+		return
+	}
+
+	params := map[string]*Parameter{}
+	for _, param := range p.rootParameters {
+		params[param.Name] = param
+	}
+	env := append(os.Environ(),
+		fmt.Sprintf("VELOCITY_EVENT=%s", event),
+		fmt.Sprintf("VELOCITY_BUILD=%s", p.Name),
+	)
+	if task != nil {
+		for name, param := range task.parameters {
+			params[name] = param
+		}
+		env = append(env,
+			fmt.Sprintf("VELOCITY_TASK=%s", task.GetName()),
+			fmt.Sprintf("VELOCITY_TASK_STATUS=%s", task.Status),
+		)
+	}
+	if eventErr != nil {
+		env = append(env, fmt.Sprintf("VELOCITY_ERROR=%s", maskSecrets(eventErr.Error(), getSecrets(params))))
+	}
+
+	for _, plugin := range p.root.Plugins {
+		if !isIn(event, plugin.Events) {
+			continue
+		}
+		plugin := plugin
+		p.plugins.enqueue(func() {
+			ctx, cancel := context.WithTimeout(p.getContext(), PluginTimeout)
+			defer cancel()
+			err := runPlugin(ctx, plugin, params, env)
+			if ctx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("timed out after %s", PluginTimeout)
+			}
+			if err != nil {
+				logging.GetLogger().Warn("plugin failed",
+					zap.String("plugin", plugin.Use),
+					zap.String("event", event),
+					zap.Error(err),
+				)
+			}
+		})
+	}
+}
+
+func runPlugin(ctx context.Context, plugin *config.RootPlugin, params map[string]*Parameter, env []string) error {
+	bin, err := getBinary(plugin.GetPluginReference(), BlankWriter{})
+	if err != nil {
+		return err
+	}
+
+	cmd := []string{bin}
+	argNames := make([]string, 0, len(plugin.Arguments))
+	for k := range plugin.Arguments {
+		argNames = append(argNames, k)
+	}
+	sort.Strings(argNames)
+	for _, k := range argNames {
+		v := plugin.Arguments[k]
+		for name, param := range params {
+			v = strings.Replace(v, fmt.Sprintf("${%s}", name), param.Value, -1)
+		}
+		cmd = append(cmd, fmt.Sprintf("-%s=%s", k, v))
+	}
+
+	return exec.GetStatusError(exec.RunContext(ctx, cmd, "", env, BlankWriter{}))
+}
+
+// pluginQueue runs plugins one at a time, in the order of their events, away from the goroutine scheduling Tasks
+type pluginQueue struct {
+	mutex   sync.Mutex
+	queue   []func()
+	running bool
+	pending sync.WaitGroup
+}
+
+func (q *pluginQueue) enqueue(run func()) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.pending.Add(1)
+	q.queue = append(q.queue, run)
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+}
+
+func (q *pluginQueue) run() {
+	for {
+		q.mutex.Lock()
+		if len(q.queue) < 1 {
+			q.running = false
+			q.mutex.Unlock()
+			return
+		}
+		run := q.queue[0]
+		q.queue = q.queue[1:]
+		q.mutex.Unlock()
+
+		run()
+		q.pending.Done()
+	}
+}
 
+// wait blocks until every queued plugin has run
+func (q *pluginQueue) wait() {
+	q.pending.Wait()
 }
@@ -0,0 +1,85 @@
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+)
+
+func TestConstructionPlanRunsPluginsOnEvents(t *testing.T) {
+	projectRoot, err := ioutil.TempDir("", "vci-plugins-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(projectRoot)
+
+	logFile := filepath.Join(projectRoot, "plugin.log")
+	ref, reset := newPlugin(t, projectRoot, "notify",
+		fmt.Sprintf("#!/bin/sh\necho \"$VELOCITY_EVENT $VELOCITY_TASK $@\" >> %s\n", logFile),
+	)
+	defer reset()
+
+	task := &Task{
+		Blueprint:  config.Blueprint{Name: "deploy"},
+		parameters: map[string]*Parameter{"environment": {Name: "environment", Value: "staging"}},
+	}
+	plan := &ConstructionPlan{Stages: []*Stage{{Tasks: map[string]*Task{"deploy": task}}}}
+	plan.SetRoot(&config.Root{
+		Path: projectRoot,
+		Plugins: []*config.RootPlugin{
+			{
+				Use:       ref.Use,
+				SHA256:    ref.SHA256,
+				Arguments: map[string]string{"channel": "${team}-${environment}"},
+				Events:    []string{EventTaskSuccess},
+			},
+		},
+	})
+	plan.rootParameters = []*Parameter{{Name: "team", Value: "core"}}
+
+	eventTaskStart(plan, task)
+	eventTaskSuccess(plan, task)
+	plan.plugins.wait()
+
+	b, err := ioutil.ReadFile(logFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "TASK_SUCCESS deploy -channel=core-staging\n", string(b))
+}
+
+func TestConstructionPlanKillsPluginsAfterTimeout(t *testing.T) {
+	projectRoot, err := ioutil.TempDir("", "vci-plugins-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(projectRoot)
+
+	logFile := filepath.Join(projectRoot, "plugin.log")
+	ref, reset := newPlugin(t, projectRoot, "notify",
+		fmt.Sprintf("#!/bin/sh\necho \"$VELOCITY_EVENT\" >> %s\nsleep 30\n", logFile),
+	)
+	defer reset()
+	defer func(timeout time.Duration) { PluginTimeout = timeout }(PluginTimeout)
+	PluginTimeout = 100 * time.Millisecond
+
+	plan := &ConstructionPlan{}
+	plan.SetRoot(&config.Root{
+		Path: projectRoot,
+		Plugins: []*config.RootPlugin{
+			{Use: ref.Use, SHA256: ref.SHA256, Events: []string{EventBuildStart, EventBuildComplete}},
+		},
+	})
+
+	start := time.Now()
+	eventBuildStart(plan)
+	eventBuildComplete(plan)
+	// events are queued without waiting for their plugins
+	assert.True(t, time.Since(start) < PluginTimeout)
+	plan.plugins.wait()
+	assert.True(t, time.Since(start) < 10*time.Second)
+
+	b, err := ioutil.ReadFile(logFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "BUILD_START\nBUILD_COMPLETE\n", string(b))
+}
@@ -0,0 +1,87 @@
+package build
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/velocity-ci/velocity/backend/pkg/git"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+)
+
+// GetName returns the Blueprint's name with the Task's matrix combination e.g. test (go=1.12, postgres=10)
+func (t *Task) GetName() string {
+	if len(t.Matrix) < 1 {
+		return t.Blueprint.Name
+	}
+	keys := make([]string, 0, len(t.Matrix))
+	for k := range t.Matrix {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	combination := []string{}
+	for _, k := range keys {
+		combination = append(combination, fmt.Sprintf("%s=%s", k, t.Matrix[k]))
+	}
+
+	return fmt.Sprintf("%s (%s)", t.Blueprint.Name, strings.Join(combination, ", "))
+}
+
+// getMatrixParams returns the Task's matrix combination as parameters
+func (t *Task) getMatrixParams() map[string]*Parameter {
+	params := map[string]*Parameter{}
+	for k, v := range t.Matrix {
+		name := fmt.Sprintf("matrix.%s", k)
+		params[name] = &Parameter{Name: name, Value: v}
+	}
+	return params
+}
+
+// newTasksFromBlueprint returns a Task for every combination of the Blueprint's matrix that matches the filter
+func newTasksFromBlueprint(
+	c *config.Blueprint,
+	blueprints []*config.Blueprint,
+	paramResolver BackupResolver,
+	repository *git.Repository,
+	branch string,
+	commitSha string,
+	projectRoot string,
+	matrixFilter map[string]string,
+) ([]*Task, error) {
+	if len(c.ValidationErrors) > 0 {
+		return nil, fmt.Errorf("invalid blueprint %s: %s", c.Name, strings.Join(c.ValidationErrors, ", "))
+	}
+	for k, v := range matrixFilter {
+		values, ok := c.Matrix[k]
+		if !ok {
+			return nil, fmt.Errorf("blueprint %s has no matrix %s", c.Name, k)
+		}
+		if !isIn(v, values) {
+			return nil, fmt.Errorf("blueprint %s matrix %s has no value %s", c.Name, k, v)
+		}
+	}
+
+	tasks := []*Task{}
+	for _, combination := range c.Matrix.GetCombinations() {
+		if !isMatrixMatch(combination, matrixFilter) {
+			continue
+		}
+		task, err := NewTask(c, blueprints, paramResolver, repository, branch, commitSha, projectRoot)
+		if err != nil {
+			return nil, err
+		}
+		task.Matrix = combination
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+func isMatrixMatch(combination map[string]string, filter map[string]string) bool {
+	for k, v := range filter {
+		if combination[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -1,19 +1,26 @@
 package build
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/velocity-ci/velocity/backend/pkg/exec"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
 )
 
+// getSecrets returns the values of the secret parameters. Empty values, e.g. of optional secrets, are left out as
+// they cannot be masked.
 func getSecrets(params map[string]*Parameter) (r []string) {
 	for _, p := range params {
-		if p.IsSecret {
+		if p.IsSecret && p.Value != "" {
 			r = append(r, p.Value)
 		}
 	}
@@ -21,11 +28,22 @@ func getSecrets(params map[string]*Parameter) (r []string) {
 	return r
 }
 
+// maskSecrets replaces the secrets in a value with ***
+func maskSecrets(value string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			value = strings.Replace(value, secret, "***", -1)
+		}
+	}
+	return value
+}
+
 func resolveConfigParameter(
+	ctx context.Context,
 	p config.Parameter,
 	bR BackupResolver,
-	projectRoot string,
 	writer io.Writer,
+	cache *derivedParameterCache,
 ) (parameters []*Parameter, err error) {
 	// resolve parameter value at build time
 	switch x := p.(type) {
@@ -34,54 +52,57 @@ func resolveConfigParameter(
 		return resolveConfigParameterBasic(x, bR)
 	case *config.ParameterDerived:
 		writer.Write([]byte(fmt.Sprintf("-> resolving parameter %s\n", x.Use)))
-		return resolveConfigParameterDerived(x, bR, projectRoot, writer)
+		return resolveConfigParameterDerived(ctx, x, bR, writer, cache)
 	default:
 		return parameters, fmt.Errorf("type: %T: %v", x, p)
 	}
 }
 
 func resolveConfigParameterBasic(p *config.ParameterBasic, backupResolver BackupResolver) (parameters []*Parameter, err error) {
-	val, err := backupResolver.Resolve(p.Name)
-	if err != nil {
+	val, err := backupResolver.Resolve(p)
+	if err != nil && p.IsRequired() {
+		return nil, err
+	}
+	if val == "" {
+		val = p.Default
+	}
+	if err := validateParameterValue(p, val); err != nil {
 		return nil, err
 	}
-	v := val
 	return []*Parameter{{
 		Name:     p.Name,
-		Value:    v,
+		Value:    val,
 		IsSecret: p.Secret,
-	}}, err
+	}}, nil
+}
+
+// validateParameterValue validates the value of a basic parameter without revealing secret values in the error
+func validateParameterValue(p *config.ParameterBasic, value string) error {
+	err := p.ValidateValue(value)
+	if err != nil && p.Secret {
+		return fmt.Errorf("%s", maskSecrets(err.Error(), []string{value}))
+	}
+	return err
 }
 
 func resolveConfigParameterDerived(
+	ctx context.Context,
 	p *config.ParameterDerived,
 	backupResolver BackupResolver,
-	projectRoot string,
 	writer io.Writer,
+	cache *derivedParameterCache,
 ) (parameters []*Parameter, err error) {
-	// Download binary from use:
-	bin, err := getBinary(projectRoot, p.Use, writer)
+	dOutput, err := cache.get(ctx, p, writer)
 	if err != nil {
 		return parameters, err
 	}
-	cmd := []string{bin}
-
-	// Process arguments
-	for k, v := range p.Arguments {
-		cmd = append(cmd, fmt.Sprintf("-%s=%s", k, v))
-	}
-
-	// Run binary
-	s := exec.Run(cmd, "", os.Environ(), BlankWriter{})
-	if s.Error != nil {
-		return parameters, s.Error
-	}
-	var dOutput derivedOutput
-	json.Unmarshal([]byte(s.Stdout[0]), &dOutput)
 
 	if dOutput.State == "warning" {
 		for paramName := range dOutput.Exports {
-			val, err := backupResolver.Resolve(paramName)
+			if backupResolver == nil {
+				return parameters, fmt.Errorf("parameter %s not defined", paramName)
+			}
+			val, err := backupResolver.Resolve(&config.ParameterBasic{Name: paramName, Secret: dOutput.Secret})
 			if err != nil {
 				return parameters, err
 			}
@@ -97,6 +118,8 @@ func resolveConfigParameterDerived(
 				Name:     getExportedParameterName(p.Exports, paramName),
 				Value:    val,
 				IsSecret: dOutput.Secret,
+				source:   p,
+				expires:  dOutput.Expires,
 			})
 		}
 	} else {
@@ -106,14 +129,166 @@ func resolveConfigParameterDerived(
 	return parameters, nil
 }
 
+// runDerivedParameter runs the parameter's binary, stopping it after the parameter's timeout in seconds or when the
+// context is done
+func runDerivedParameter(ctx context.Context, p *config.ParameterDerived, writer io.Writer) (*derivedOutput, error) {
+	// Download binary from use:
+	bin, err := getBinary(p.GetPluginReference(), writer)
+	if err != nil {
+		return nil, err
+	}
+	cmd := []string{bin}
+
+	// Process arguments
+	argNames := make([]string, 0, len(p.Arguments))
+	for k := range p.Arguments {
+		argNames = append(argNames, k)
+	}
+	sort.Strings(argNames)
+	for _, k := range argNames {
+		cmd = append(cmd, fmt.Sprintf("-%s=%s", k, p.Arguments[k]))
+	}
+
+	// Run binary
+	runCtx := ctx
+	timeout := time.Duration(p.Timeout) * time.Second
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	s := exec.RunContext(runCtx, cmd, "", os.Environ(), BlankWriter{})
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("parameter %s stopped", p.Use)
+	}
+	if runCtx.Err() != nil {
+		return nil, fmt.Errorf("parameter %s timed out after %s", p.Use, timeout)
+	}
+	if s.Error != nil {
+		return nil, s.Error
+	}
+	if len(s.Stdout) < 1 {
+		return nil, fmt.Errorf("parameter %s did not output anything", p.Use)
+	}
+	var dOutput derivedOutput
+	if err := json.Unmarshal([]byte(s.Stdout[0]), &dOutput); err != nil {
+		return nil, fmt.Errorf("parameter %s output is invalid: %s", p.Use, err)
+	}
+
+	return &dOutput, nil
+}
+
+// derivedParameterExpiryMargin is how long before they expire derived parameters are refreshed so that they do not
+// expire while a step is using them
+const derivedParameterExpiryMargin = time.Minute
+
+// derivedParameterCache holds the successful outputs of derived parameter binaries, keyed by binary and arguments,
+// until they expire so that the Tasks of a plan only run a binary once
+type derivedParameterCache struct {
+	mutex   sync.Mutex
+	outputs map[string]*derivedOutput
+	// locks are held, per key, while a binary runs so that Tasks wait for each other rather than running the same
+	// binary at once. Different binaries run at the same time.
+	locks map[string]chan struct{}
+}
+
+func newDerivedParameterCache() *derivedParameterCache {
+	return &derivedParameterCache{
+		outputs: map[string]*derivedOutput{},
+		locks:   map[string]chan struct{}{},
+	}
+}
+
+// get returns the cached output of the parameter's binary, running the binary if there is none or it has expired.
+// A nil cache always runs the binary.
+func (c *derivedParameterCache) get(ctx context.Context, p *config.ParameterDerived, writer io.Writer) (*derivedOutput, error) {
+	if c == nil {
+		return runDerivedParameter(ctx, p, writer)
+	}
+
+	key := getDerivedParameterKey(p)
+	c.mutex.Lock()
+	lock, ok := c.locks[key]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		c.locks[key] = lock
+	}
+	c.mutex.Unlock()
+	select {
+	case lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("parameter %s stopped", p.Use)
+	}
+	defer func() { <-lock }()
+
+	c.mutex.Lock()
+	dOutput, ok := c.outputs[key]
+	c.mutex.Unlock()
+	if ok && !dOutput.expiresBefore(time.Now().Add(derivedParameterExpiryMargin)) {
+		fmt.Fprintf(writer, "-> using cached parameter %s\n", p.Use)
+		return dOutput, nil
+	}
+
+	dOutput, err := runDerivedParameter(ctx, p, writer)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	if dOutput.State == "success" {
+		c.outputs[key] = dOutput
+	} else {
+		delete(c.outputs, key)
+	}
+	c.mutex.Unlock()
+	return dOutput, nil
+}
+
+func getDerivedParameterKey(p *config.ParameterDerived) string {
+	// maps are marshalled with sorted keys
+	b, _ := json.Marshal(struct {
+		Plugin    plugin.Reference  `json:"plugin"`
+		Arguments map[string]string `json:"arguments"`
+	}{p.GetPluginReference(), p.Arguments})
+	return string(b)
+}
+
 type Parameter struct {
 	Name     string `json:"name"`
 	Value    string `json:"value"`
 	IsSecret bool   `json:"isSecret"`
+
+	// source is the derived parameter that exported the parameter, which is resolved again once it expires
+	source  *config.ParameterDerived
+	expires time.Time
 }
 
+// expiresBefore returns whether the derived parameter expires before the given time. Parameters without an expiry
+// never expire.
+func (p *Parameter) expiresBefore(t time.Time) bool {
+	return p.source != nil && !p.expires.IsZero() && p.expires.Before(t)
+}
+
+// ParameterEnvPrefix prefixes the names of the environment variables that parameters are resolved from
+const ParameterEnvPrefix = "VCI_"
+
+// GetParameterValue returns the value of the parameter from the given values, else from its VCI_ prefixed
+// environment variable. This is the precedence every BackupResolver shares before falling back to its own source,
+// e.g. prompting; the parameter's default is applied last.
+func GetParameterValue(name string, values map[string]string) (string, bool) {
+	if val, ok := values[name]; ok {
+		return val, true
+	}
+	if val := os.Getenv(fmt.Sprintf("%s%s", ParameterEnvPrefix, name)); len(val) > 0 {
+		return val, true
+	}
+
+	return "", false
+}
+
+// BackupResolver resolves the values of basic parameters, e.g. from the user or the environment. The parameter's
+// default is applied after resolving so resolvers may return an error or an empty value when none is given.
 type BackupResolver interface {
-	Resolve(paramName string) (string, error)
+	Resolve(p *config.ParameterBasic) (string, error)
 }
 
 func getExportedParameterName(pMapping map[string]string, exportedParam string) string {
@@ -131,3 +306,8 @@ type derivedOutput struct {
 	Error   string            `json:"error"`
 	State   string            `json:"state"`
 }
+
+// expiresBefore returns whether the output expires before the given time. Outputs without an expiry never expire.
+func (o *derivedOutput) expiresBefore(t time.Time) bool {
+	return !o.Expires.IsZero() && o.Expires.Before(t)
+}
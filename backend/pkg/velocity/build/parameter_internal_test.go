@@ -0,0 +1,162 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+)
+
+type mapResolver map[string]string
+
+func (r mapResolver) Resolve(p *config.ParameterBasic) (string, error) {
+	if val, ok := r[p.Name]; ok {
+		return val, nil
+	}
+	return "", fmt.Errorf("parameter %s not defined", p.Name)
+}
+
+func TestResolveConfigParameterBasic(t *testing.T) {
+	resolver := mapResolver{"replicas": "5", "token": "s3cr3t"}
+
+	params, err := resolveConfigParameterBasic(&config.ParameterBasic{Name: "replicas", Kind: config.ParameterKindInt}, resolver)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Parameter{{Name: "replicas", Value: "5"}}, params)
+
+	params, err = resolveConfigParameterBasic(&config.ParameterBasic{Name: "environment", Default: "staging", OtherOptions: []string{"production"}}, resolver)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Parameter{{Name: "environment", Value: "staging"}}, params)
+
+	params, err = resolveConfigParameterBasic(&config.ParameterBasic{Name: "comment", Optional: true}, resolver)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Parameter{{Name: "comment", Value: ""}}, params)
+
+	_, err = resolveConfigParameterBasic(&config.ParameterBasic{Name: "version"}, resolver)
+	assert.EqualError(t, err, "parameter version not defined")
+
+	_, err = resolveConfigParameterBasic(&config.ParameterBasic{Name: "token", Kind: config.ParameterKindInt, Secret: true}, resolver)
+	assert.EqualError(t, err, `parameter token value "***" is not an int`)
+}
+
+// newDerivedParameter returns a derived parameter whose binary exports the number of times it has run and expires at
+// the given time, and a function that removes it
+func newDerivedParameter(t *testing.T, expires time.Time, sleep int) (*config.ParameterDerived, func()) {
+	dir, err := ioutil.TempDir("", "vci-parameters-")
+	assert.Nil(t, err)
+	script := fmt.Sprintf(`#!/bin/sh
+sleep %d
+echo run >> %s
+n=$(wc -l < %s | tr -d ' ')
+echo "{\"state\":\"success\",\"secret\":true,\"exports\":{\"value\":\"v$n\"},\"expires\":\"%s\"}"
+`, sleep, filepath.Join(dir, "runs"), filepath.Join(dir, "runs"), expires.Format(time.RFC3339))
+	ref, reset := newPlugin(t, dir, "parameter-test", script)
+	return &config.ParameterDerived{Use: ref.Use, SHA256: ref.SHA256}, func() {
+		reset()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestResolveConfigParameterDerivedCached(t *testing.T) {
+	p, remove := newDerivedParameter(t, time.Now().Add(time.Hour), 0)
+	defer remove()
+
+	cache := newDerivedParameterCache()
+	p.Arguments = map[string]string{"name": "/velocityci/token"}
+	p.Exports = map[string]string{"value": "token"}
+
+	for i := 0; i < 2; i++ {
+		params, err := resolveConfigParameterDerived(context.Background(), p, nil, BlankWriter{}, cache)
+		assert.Nil(t, err)
+		assert.Len(t, params, 1)
+		assert.Equal(t, "token", params[0].Name)
+		assert.Equal(t, "v1", params[0].Value)
+		assert.True(t, params[0].IsSecret)
+	}
+
+	// the same binary with other arguments is run again
+	params, err := resolveConfigParameterDerived(context.Background(), &config.ParameterDerived{
+		Use:       p.Use,
+		SHA256:    p.SHA256,
+		Arguments: map[string]string{"name": "/velocityci/other-token"},
+	}, nil, BlankWriter{}, cache)
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", params[0].Value)
+}
+
+func TestTaskRefreshesExpiredParameters(t *testing.T) {
+	p, remove := newDerivedParameter(t, time.Now().Add(30*time.Second), 0)
+	defer remove()
+
+	p.Exports = map[string]string{"value": "token"}
+	task := &Task{parameters: map[string]*Parameter{}}
+	params, err := resolveConfigParameterDerived(context.Background(), p, nil, BlankWriter{}, task.getDerivedParameterCache())
+	assert.Nil(t, err)
+	task.parameters["token"] = params[0]
+	assert.Equal(t, "v1", task.parameters["token"].Value)
+
+	// the parameter expires within the refresh margin so it is resolved again before each step
+	assert.Nil(t, task.refreshExpiredParameters(BlankWriter{}))
+	assert.Equal(t, "v2", task.parameters["token"].Value)
+}
+
+func TestResolveConfigParameterDerivedTimeout(t *testing.T) {
+	p, remove := newDerivedParameter(t, time.Time{}, 3)
+	defer remove()
+
+	p.Timeout = 1
+	_, err := resolveConfigParameterDerived(context.Background(), p, nil, BlankWriter{}, nil)
+	assert.EqualError(t, err, fmt.Sprintf("parameter %s timed out after 1s", p.Use))
+}
+
+func TestDerivedParameterCacheRunsDifferentBinariesAtOnce(t *testing.T) {
+	p, remove := newDerivedParameter(t, time.Time{}, 2)
+	defer remove()
+
+	cache := newDerivedParameterCache()
+	start := time.Now()
+	errs := make(chan error, 2)
+	for _, name := range []string{"/velocityci/token", "/velocityci/other-token"} {
+		go func(name string) {
+			_, err := resolveConfigParameterDerived(context.Background(), &config.ParameterDerived{
+				Use:       p.Use,
+				SHA256:    p.SHA256,
+				Arguments: map[string]string{"name": name},
+			}, nil, BlankWriter{}, cache)
+			errs <- err
+		}(name)
+	}
+	assert.Nil(t, <-errs)
+	assert.Nil(t, <-errs)
+	// each binary sleeps for 2 seconds so they did not wait for each other
+	assert.True(t, time.Since(start) < 4*time.Second)
+}
+
+func TestResolveConfigParameterDerivedStopped(t *testing.T) {
+	p, remove := newDerivedParameter(t, time.Time{}, 30)
+	defer remove()
+
+	task := &Task{}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		task.Stop()
+	}()
+	start := time.Now()
+	_, err := resolveConfigParameterDerived(task.getContext(), p, nil, BlankWriter{}, task.getDerivedParameterCache())
+	assert.EqualError(t, err, fmt.Sprintf("parameter %s stopped", p.Use))
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
+
+func TestGetSecretsSkipsEmptyValues(t *testing.T) {
+	secrets := getSecrets(map[string]*Parameter{
+		"token":   {Name: "token", Value: "s3cr3t", IsSecret: true},
+		"comment": {Name: "comment", Value: "", IsSecret: true},
+		"user":    {Name: "user", Value: "velocity"},
+	})
+	assert.Equal(t, []string{"s3cr3t"}, secrets)
+}
@@ -0,0 +1,51 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
+)
+
+// newPlugin writes the script as a plugin in the directory and returns a reference to it, using a plugin store in
+// the directory until the returned function is called
+func newPlugin(t *testing.T, dir, name, script string) (plugin.Reference, func()) {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(script), 0644))
+	sum := sha256.Sum256([]byte(script))
+
+	os.Setenv(plugin.CacheDirEnv, filepath.Join(dir, "plugins"))
+	return plugin.Reference{
+		Use:    fmt.Sprintf("file://%s", path),
+		SHA256: hex.EncodeToString(sum[:]),
+	}, func() { os.Unsetenv(plugin.CacheDirEnv) }
+}
+
+func TestGetBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vci-plugins-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	ref, reset := newPlugin(t, dir, "plugin", "#!/bin/sh\necho hello\n")
+	defer reset()
+
+	bin, err := getBinary(ref, BlankWriter{})
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "plugins", "sha256", ref.SHA256), bin)
+	info, err := os.Stat(bin)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// a changed plugin no longer matches the pinned checksum
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "plugin"), []byte("#!/bin/sh\necho goodbye\n"), 0644))
+	os.Remove(bin)
+	_, err = getBinary(ref, BlankWriter{})
+	assert.Error(t, err)
+	_, err = os.Stat(bin)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,263 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+)
+
+// StepBlueprint runs the steps of another Blueprint with its own parameters
+type StepBlueprint struct {
+	BaseStep
+	Name       string            `json:"name"`
+	Parameters map[string]string `json:"parameters"`
+
+	Blueprint config.Blueprint `json:"blueprint"`
+	Steps     []Step           `json:"steps"`
+
+	task *Task
+}
+
+func NewStepBlueprint(
+	c *config.StepBlueprint,
+	blueprints []*config.Blueprint,
+	projectRoot string,
+	callStack []string,
+) (*StepBlueprint, error) {
+	if isIn(c.Name, callStack) {
+		return nil, fmt.Errorf("blueprint recursion: %s -> %s", strings.Join(callStack, " -> "), c.Name)
+	}
+	blueprint, err := getRequestedBlueprintByName(c.Name, blueprints)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := newStepsFromConfig(blueprint.Steps, blueprints, projectRoot, append(callStack, c.Name))
+	if err != nil {
+		return nil, err
+	}
+	parameters := map[string]string{}
+	for k, v := range c.Parameters {
+		parameters[k] = v
+	}
+
+	return &StepBlueprint{
+		BaseStep:   newBaseStepFromConfig("blueprint", []string{"blueprint"}, c.BaseStep),
+		Name:       c.Name,
+		Parameters: parameters,
+		Blueprint:  *blueprint,
+		Steps:      steps,
+	}, nil
+}
+
+// UnmarshalJSON deserializes the called Blueprint's steps by type
+func (sB *StepBlueprint) UnmarshalJSON(b []byte) error {
+	type stepBlueprint StepBlueprint
+	aux := struct {
+		*stepBlueprint
+		Steps []json.RawMessage `json:"steps"`
+	}{stepBlueprint: (*stepBlueprint)(sB)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	sB.Steps = []Step{}
+	for _, rawMessage := range aux.Steps {
+		s, err := unmarshalStep(rawMessage)
+		if err != nil {
+			return err
+		}
+		sB.Steps = append(sB.Steps, s)
+	}
+
+	return nil
+}
+
+func (sB StepBlueprint) GetDetails() string {
+	type details struct {
+		Name         string            `json:"name"`
+		Parameters   map[string]string `json:"parameters"`
+		IgnoreErrors bool              `json:"ignoreErrors"`
+		Steps        []string          `json:"steps"`
+	}
+	steps := []string{}
+	for _, s := range sB.Steps {
+		steps = append(steps, strings.TrimSpace(fmt.Sprintf("%s %s", s.GetType(), s.GetDescription())))
+	}
+	y, _ := yaml.Marshal(&details{
+		Name:         sB.Name,
+		Parameters:   sB.Parameters,
+		IgnoreErrors: sB.IgnoreErrors,
+		Steps:        steps,
+	})
+	return string(y)
+}
+
+func (sB *StepBlueprint) Execute(emitter Emitter, t *Task) error {
+	writer, err := sB.GetStreamWriter(emitter, "blueprint")
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	writer.SetStatus(StateBuilding)
+	fmt.Fprintf(writer, "\r")
+
+	err = sB.execute(emitter, t, writer)
+	if err != nil {
+		writer.SetStatus(StateFailed)
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> error in blueprint %s: %s", "\n"), sB.Name, err)
+		return err
+	}
+
+	writer.SetStatus(StateSuccess)
+	fmt.Fprintf(writer, output.ColorFmt(output.ANSISuccess, "-> success", "\n"))
+
+	return nil
+}
+
+func (sB *StepBlueprint) execute(emitter Emitter, t *Task, writer StreamWriter) error {
+	// The called Blueprint runs as a Task of its own in the same workspace with its own parameter scope.
+	sB.task = &Task{
+		ID:             t.ID,
+		Blueprint:      sB.Blueprint,
+		Docker:         t.Docker,
+		Steps:          sB.Steps,
+		Artifacts:      t.Artifacts,
+		ProjectRoot:    t.ProjectRoot,
+		ArtifactStore:  t.ArtifactStore,
+		project:        t.project,
+		privateKey:     t.privateKey,
+		platformImages: t.platformImages,
+		parameters:     map[string]*Parameter{},
+		derivedCache:   t.getDerivedParameterCache(),
+		// the called Blueprint's steps are cancelled with the calling Task
+		ctx: t.getContext(),
+	}
+	// artifacts collected, images built and digests pushed by the called Blueprint belong to the calling Task
+	defer func() {
+		t.Artifacts = sB.task.Artifacts
+		t.platformImages = sB.task.platformImages
+		for name, p := range sB.task.parameters {
+			if strings.HasPrefix(name, "push.") && strings.HasSuffix(name, ".digest") {
+				t.parameters[name] = p
+			}
+		}
+	}()
+	for k, v := range t.parameters {
+		sB.task.parameters[k] = v
+	}
+	secrets := getSecrets(t.parameters)
+	for k, v := range sB.Parameters {
+		sB.task.parameters[k] = &Parameter{
+			Name:     k,
+			Value:    v,
+			IsSecret: containsSecret(v, secrets),
+		}
+	}
+
+	resolver := &stepBlueprintResolver{parameters: sB.Parameters, backupResolver: t.getBackupResolver()}
+	for _, configParam := range sB.Blueprint.Parameters {
+		if basic, ok := configParam.(*config.ParameterBasic); ok {
+			if _, ok := sB.Parameters[basic.Name]; ok {
+				param := sB.task.parameters[basic.Name]
+				param.IsSecret = param.IsSecret || basic.Secret
+				if param.Value == "" {
+					param.Value = basic.Default
+				}
+				if err := validateParameterValue(basic, param.Value); err != nil {
+					return fmt.Errorf("could not resolve %v", err)
+				}
+				continue
+			}
+		}
+		resolvedParams, err := resolveConfigParameter(t.getContext(), configParam, resolver, writer, t.getDerivedParameterCache())
+		if err != nil {
+			return fmt.Errorf("could not resolve %v", err)
+		}
+		for _, param := range resolvedParams {
+			sB.task.parameters[param.Name] = param
+		}
+	}
+
+	for _, registry := range taskDockerFromBlueprintDocker(sB.Blueprint.Docker).Registries {
+		r, err := dockerLogin(registry, writer, sB.task)
+		if err != nil || r.Address == "" {
+			return fmt.Errorf("could not login to Docker registry: %v", err)
+		}
+		sB.task.Docker.Registries = append(sB.task.Docker.Registries, r)
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "Authenticated with Docker registry: %s", "\n"), r.Address)
+	}
+
+	totalSteps := len(sB.Steps)
+	for i, step := range sB.Steps {
+		if t.isStopped() {
+			return fmt.Errorf("blueprint %s stopped", sB.Name)
+		}
+		if err := sB.task.executeStep(i+1, totalSteps, emitter, step); err != nil {
+			return err
+		}
+	}
+
+	return sB.task.collectArtifacts(sB.ID, sB.Blueprint.Artifacts, writer)
+}
+
+func (sB *StepBlueprint) Stop() error {
+	for _, step := range sB.Steps {
+		if err := step.Stop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sB StepBlueprint) Validate(params map[string]Parameter) error {
+	return nil
+}
+
+func (sB *StepBlueprint) SetParams(params map[string]*Parameter) error {
+	for paramName, param := range params {
+		parameters := map[string]string{}
+		for k, v := range sB.Parameters {
+			parameters[k] = strings.Replace(v, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+		}
+		sB.Parameters = parameters
+	}
+	return nil
+}
+
+// stepBlueprintResolver resolves parameters given to a blueprint step before falling back to the Task's resolver
+type stepBlueprintResolver struct {
+	parameters     map[string]string
+	backupResolver BackupResolver
+}
+
+func (r *stepBlueprintResolver) Resolve(p *config.ParameterBasic) (string, error) {
+	if val, ok := r.parameters[p.Name]; ok {
+		return val, nil
+	}
+	if r.backupResolver == nil {
+		return "", fmt.Errorf("parameter %s not defined", p.Name)
+	}
+	return r.backupResolver.Resolve(p)
+}
+
+func containsSecret(value string, secrets []string) bool {
+	for _, secret := range secrets {
+		if secret != "" && strings.Contains(value, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIn(needle string, haystack []string) bool {
+	for _, v := range haystack {
+		if needle == v {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,13 @@
 package build
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
+	"runtime"
 	"strings"
 
+	"github.com/docker/docker/api/types"
 	"github.com/ghodss/yaml"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
 
@@ -14,36 +17,127 @@ import (
 
 type StepDockerBuild struct {
 	BaseStep
-	Dockerfile string   `json:"dockerfile"`
-	Context    string   `json:"context"`
-	Tags       []string `json:"tags"`
+	Dockerfile string            `json:"dockerfile"`
+	Context    string            `json:"context"`
+	Tags       []string          `json:"tags"`
+	BuildArgs  map[string]string `json:"buildArgs"`
+	Target     string            `json:"target"`
+	CacheFrom  []string          `json:"cacheFrom"`
+	Labels     map[string]string `json:"labels"`
+	Network    string            `json:"network"`
+	NoCache    bool              `json:"noCache"`
+	Pull       bool              `json:"pull"`
+	Builder    string            `json:"builder"`
+	// Secrets are the names of the parameters mounted with RUN --mount=type=secret,id=<parameter name>
+	Secrets []string `json:"secrets"`
+	SSH     bool     `json:"ssh"`
+	// Platforms are built one at a time as PlatformTag images of the tags
+	Platforms []string `json:"platforms"`
 
-	builder *docker.ImageBuilder
+	builder docker.Builder
+	secrets []string
 }
 
 func NewStepDockerBuild(c *config.StepDockerBuild) *StepDockerBuild {
+	pull := true
+	if c.Pull != nil {
+		pull = *c.Pull
+	}
+	buildArgs := map[string]string{}
+	for k, v := range c.BuildArgs {
+		buildArgs[k] = v
+	}
+	labels := map[string]string{}
+	for k, v := range c.Labels {
+		labels[k] = v
+	}
 	return &StepDockerBuild{
-		BaseStep:   newBaseStep("build", []string{"build"}),
+		BaseStep:   newBaseStepFromConfig("build", []string{"build"}, c.BaseStep),
 		Dockerfile: c.Dockerfile,
 		Context:    c.Context,
 		Tags:       c.Tags,
+		BuildArgs:  buildArgs,
+		Target:     c.Target,
+		CacheFrom:  c.CacheFrom,
+		Labels:     labels,
+		Network:    c.Network,
+		NoCache:    c.NoCache,
+		Pull:       pull,
+		Builder:    c.Builder,
+		Secrets:    c.Secrets,
+		SSH:        c.SSH,
+		Platforms:  c.Platforms,
 	}
 }
 
 func (dB StepDockerBuild) GetDetails() string {
 	type details struct {
-		Dockerfile string   `json:"dockerfile"`
-		Context    string   `json:"context"`
-		Tags       []string `json:"tags"`
+		Dockerfile string            `json:"dockerfile"`
+		Context    string            `json:"context"`
+		Tags       []string          `json:"tags"`
+		BuildArgs  map[string]string `json:"buildArgs,omitempty"`
+		Target     string            `json:"target,omitempty"`
+		CacheFrom  []string          `json:"cacheFrom,omitempty"`
+		Labels     map[string]string `json:"labels,omitempty"`
+		Network    string            `json:"network,omitempty"`
+		NoCache    bool              `json:"noCache,omitempty"`
+		Pull       bool              `json:"pull"`
+		Builder    string            `json:"builder,omitempty"`
+		Secrets    []string          `json:"secrets,omitempty"`
+		SSH        bool              `json:"ssh,omitempty"`
+		Platforms  []string          `json:"platforms,omitempty"`
+	}
+	buildArgs := map[string]string{}
+	for k, v := range dB.BuildArgs {
+		buildArgs[k] = maskSecrets(v, dB.secrets)
 	}
 	y, _ := yaml.Marshal(&details{
 		Dockerfile: dB.Dockerfile,
 		Context:    dB.Context,
 		Tags:       dB.Tags,
+		BuildArgs:  buildArgs,
+		Target:     dB.Target,
+		CacheFrom:  dB.CacheFrom,
+		Labels:     dB.Labels,
+		Network:    dB.Network,
+		NoCache:    dB.NoCache,
+		Pull:       dB.Pull,
+		Builder:    dB.Builder,
+		Secrets:    dB.Secrets,
+		SSH:        dB.SSH,
+		Platforms:  dB.Platforms,
 	})
 	return string(y)
 }
 
+func (dB *StepDockerBuild) getBuildOptions(t *Task) (docker.BuildOptions, error) {
+	buildArgs := map[string]*string{}
+	for k, v := range dB.BuildArgs {
+		value := v
+		buildArgs[k] = &value
+	}
+	secrets := map[string]string{}
+	for _, name := range dB.Secrets {
+		param, ok := t.parameters[name]
+		if !ok {
+			return docker.BuildOptions{}, fmt.Errorf("secret parameter %s missing", name)
+		}
+		secrets[name] = param.Value
+	}
+	return docker.BuildOptions{
+		BuildArgs:   buildArgs,
+		Target:      dB.Target,
+		CacheFrom:   dB.CacheFrom,
+		Labels:      dB.Labels,
+		NetworkMode: dB.Network,
+		NoCache:     dB.NoCache,
+		Pull:        dB.Pull,
+		Secrets:     secrets,
+		SSH:         dB.SSH,
+		SSHKey:      t.privateKey,
+	}, nil
+}
+
 func (dB *StepDockerBuild) Execute(emitter Emitter, t *Task) error {
 	writer, err := dB.GetStreamWriter(emitter, "build")
 	if err != nil {
@@ -57,19 +151,30 @@ func (dB *StepDockerBuild) Execute(emitter Emitter, t *Task) error {
 
 	buildContext := filepath.Join(t.ProjectRoot, dB.Context)
 
-	dB.builder = docker.NewImageBuilder()
+	ctx := dB.newContext(t.getContext())
+	docker.PullCacheImages(ctx, writer, getSecrets(t.parameters), dB.CacheFrom, GetAddressAuthTokensMap(t.Docker.Registries))
 
-	err = dB.builder.Build(
-		writer,
-		getSecrets(t.parameters),
-		buildContext,
-		dB.Dockerfile,
-		dB.Tags,
-		authConfigs,
-	)
+	options, err := dB.getBuildOptions(t)
+	if err == nil {
+		dB.builder = docker.NewBuilder(dB.Builder)
+		if len(dB.Platforms) > 0 {
+			err = dB.buildPlatforms(ctx, writer, t, buildContext, authConfigs, options)
+		} else {
+			err = dB.builder.Build(
+				ctx,
+				writer,
+				getSecrets(t.parameters),
+				buildContext,
+				dB.Dockerfile,
+				dB.Tags,
+				authConfigs,
+				options,
+			)
+		}
+	}
 
 	if err != nil {
-		writer.SetStatus(StateFailed)
+		writer.SetStatus(dB.getFailedState())
 		fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> failed: %s", "\n"), err)
 
 		return err
@@ -81,10 +186,73 @@ func (dB *StepDockerBuild) Execute(emitter Emitter, t *Task) error {
 	return nil
 }
 
-func (dB *StepDockerBuild) Stop() error {
-	if dB.builder != nil {
-		dB.builder.Stop()
+// buildPlatforms builds the image for each platform. The native platform's image, if any, is also tagged with the
+// tags so that later steps can run it.
+func (dB *StepDockerBuild) buildPlatforms(
+	ctx context.Context,
+	writer StreamWriter,
+	t *Task,
+	buildContext string,
+	authConfigs map[string]types.AuthConfig,
+	options docker.BuildOptions,
+) error {
+	nativePlatform := ""
+	for _, platform := range dB.Platforms {
+		if err := docker.CheckEmulation(platform); err != nil {
+			return err
+		}
+		if nativePlatform == "" && platform == fmt.Sprintf("linux/%s", runtime.GOARCH) {
+			nativePlatform = platform
+		}
+	}
+
+	if t.platformImages == nil {
+		t.platformImages = map[string][]string{}
+	}
+	for _, platform := range dB.Platforms {
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> building %s", "\n"), platform)
+		tags := []string{}
+		for _, tag := range dB.Tags {
+			tags = append(tags, docker.PlatformTag(tag, platform))
+		}
+		options.Platform = platform
+		err := dB.builder.Build(
+			ctx,
+			writer,
+			getSecrets(t.parameters),
+			buildContext,
+			dB.Dockerfile,
+			tags,
+			authConfigs,
+			options,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %s", platform, err)
+		}
+		for i, tag := range dB.Tags {
+			t.platformImages[tag] = appendUnique(t.platformImages[tag], tags[i])
+			if platform == nativePlatform {
+				if err := docker.TagImage(ctx, tags[i], tag); err != nil {
+					return err
+				}
+			}
+		}
 	}
+
+	return nil
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, x := range s {
+		if x == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
+
+func (dB *StepDockerBuild) Stop() error {
+	dB.cancelContext()
 	return nil
 }
 
@@ -103,6 +271,20 @@ func (dB *StepDockerBuild) SetParams(params map[string]*Parameter) error {
 			tags = append(tags, strings.Replace(t, fmt.Sprintf("${%s}", paramName), param.Value, -1))
 		}
 		dB.Tags = tags
+
+		dB.Target = strings.Replace(dB.Target, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+		for k, v := range dB.BuildArgs {
+			dB.BuildArgs[k] = strings.Replace(v, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+		}
+		for k, v := range dB.Labels {
+			dB.Labels[k] = strings.Replace(v, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+		}
+		cacheFrom := []string{}
+		for _, c := range dB.CacheFrom {
+			cacheFrom = append(cacheFrom, strings.Replace(c, fmt.Sprintf("${%s}", paramName), param.Value, -1))
+		}
+		dB.CacheFrom = cacheFrom
 	}
+	dB.secrets = getSecrets(params)
 	return nil
 }
@@ -0,0 +1,35 @@
+package build_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+)
+
+func TestStepDockerBuildSetParams(t *testing.T) {
+	step := build.NewStepDockerBuild(&config.StepDockerBuild{
+		Tags: []string{"app:${git.commit.sha.short}"},
+		BuildArgs: map[string]string{
+			"NPM_TOKEN": "${npm.token}",
+		},
+		CacheFrom: []string{"app:${git.branch}"},
+	})
+	assert.True(t, step.Pull)
+
+	err := step.SetParams(map[string]*build.Parameter{
+		"git.commit.sha.short": {Name: "git.commit.sha.short", Value: "abc1234"},
+		"git.branch":           {Name: "git.branch", Value: "master"},
+		"npm.token":            {Name: "npm.token", Value: "s3cr3t", IsSecret: true},
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"app:abc1234"}, step.Tags)
+	assert.Equal(t, []string{"app:master"}, step.CacheFrom)
+	assert.Equal(t, "s3cr3t", step.BuildArgs["NPM_TOKEN"])
+
+	details := step.GetDetails()
+	assert.Contains(t, details, "NPM_TOKEN: '***'")
+	assert.NotContains(t, details, "s3cr3t")
+}
@@ -4,52 +4,161 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/docker"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+	"go.uber.org/zap"
 
 	"github.com/ghodss/yaml"
 	v3 "github.com/velocity-ci/velocity/backend/pkg/velocity/docker/compose/v3"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
 )
 
 type StepDockerCompose struct {
 	BaseStep
-	ComposeFilePath string `json:"composeFile"`
-	// Contents    v3.DockerComposeYaml `json:"contents"`
+	ComposeFilePath string          `json:"composeFile"`
+	StartTimeout    config.Duration `json:"startTimeout"`
+	// ExitCodeFrom is the service whose exit code determines the result. By default it is the first service to exit.
+	ExitCodeFrom         string `json:"exitCodeFrom"`
+	AbortOnContainerExit bool   `json:"abortOnContainerExit"`
+	AllMustSucceed       bool   `json:"allMustSucceed"`
+	// UnsupportedKeys are the keys in the compose file that are ignored
+	UnsupportedKeys []string `json:"unsupportedKeys"`
+	// Contents is the compose file as parsed when the step was constructed, before parameters are interpolated. It is
+	// only used to describe the step as Execute reads the compose file from the checked out project.
+	Contents *v3.DockerComposeYaml `json:"contents"`
 
 	containerManager *docker.ContainerManager
+	// interpolated is the Contents with the parameters given to SetParams, for GetDetails
+	interpolated *v3.DockerComposeYaml
+	secrets      []string
 }
 
 func NewStepDockerCompose(c *config.StepDockerCompose, projectRoot string) *StepDockerCompose {
-	streams, _ := getComposeFileStreams(filepath.Join(projectRoot, c.ComposeFile))
+	path := filepath.Join(projectRoot, c.ComposeFile)
+	streams, _ := getComposeFileStreams(path)
+	unsupportedKeys, _ := getComposeFileUnsupportedKeys(path)
+	contents, err := parseComposeFile(path)
+	if err != nil {
+		logging.GetLogger().Warn("could not parse compose file", zap.String("path", path), zap.Error(err))
+	}
+	abortOnContainerExit := true
+	if c.AbortOnContainerExit != nil {
+		abortOnContainerExit = *c.AbortOnContainerExit
+	}
 
 	return &StepDockerCompose{
-		BaseStep:        newBaseStep("compose", streams),
+		BaseStep:        newBaseStepFromConfig("compose", streams, c.BaseStep),
 		ComposeFilePath: c.ComposeFile,
+		StartTimeout:    c.StartTimeout,
+
+		ExitCodeFrom:         c.ExitCodeFrom,
+		AbortOnContainerExit: abortOnContainerExit,
+		AllMustSucceed:       c.AllMustSucceed,
+		UnsupportedKeys:      unsupportedKeys,
+		Contents:             contents,
 	}
 }
 
 func (dC StepDockerCompose) GetDetails() string {
 	type details struct {
-		ComposeFilePath string `json:"composeFile"`
+		ComposeFilePath string          `json:"composeFile"`
+		StartTimeout    config.Duration `json:"startTimeout,omitempty"`
+
+		ExitCodeFrom         string   `json:"exitCodeFrom,omitempty"`
+		AbortOnContainerExit bool     `json:"abortOnContainerExit"`
+		AllMustSucceed       bool     `json:"allMustSucceed,omitempty"`
+		UnsupportedKeys      []string `json:"unsupportedKeys,omitempty"`
+
+		Services map[string]serviceDetails `json:"services,omitempty"`
 	}
 	y, _ := yaml.Marshal(&details{
+		Services: dC.getServiceDetails(),
+
 		ComposeFilePath: dC.ComposeFilePath,
+		StartTimeout:    dC.StartTimeout,
+
+		ExitCodeFrom:         dC.ExitCodeFrom,
+		AbortOnContainerExit: dC.AbortOnContainerExit,
+		AllMustSucceed:       dC.AllMustSucceed,
+		UnsupportedKeys:      dC.UnsupportedKeys,
 	})
 	return string(y)
 }
 
+type serviceDetails struct {
+	Image       string            `json:"image,omitempty"`
+	Command     string            `json:"command,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+}
+
+// getServiceDetails returns the services with the interpolated parameters, if any, and secrets masked
+func (dC StepDockerCompose) getServiceDetails() map[string]serviceDetails {
+	contents := dC.Contents
+	if dC.interpolated != nil {
+		contents = dC.interpolated
+	}
+	if contents == nil {
+		return nil
+	}
+
+	services := map[string]serviceDetails{}
+	for serviceName, s := range contents.Services {
+		environment := map[string]string{}
+		for k, v := range s.Environment {
+			environment[k] = maskSecrets(v, dC.secrets)
+		}
+		services[serviceName] = serviceDetails{
+			Image:       maskSecrets(s.Image, dC.secrets),
+			Command:     maskSecrets(strings.Join(s.Command, " "), dC.secrets),
+			Environment: environment,
+		}
+	}
+
+	return services
+}
+
 func (dC *StepDockerCompose) Validate(params map[string]Parameter) error {
-	return nil
+	if dC.Contents == nil {
+		return nil
+	}
+	_, err := dC.Contents.Interpolate(func(name string) (string, bool) {
+		p, ok := params[name]
+		return p.Value, ok
+	})
+	return err
 }
 
 func (dC *StepDockerCompose) SetParams(params map[string]*Parameter) error {
+	if dC.Contents == nil {
+		return nil
+	}
+	interpolated, err := dC.Contents.Interpolate(getParameterLookup(params))
+	if err != nil {
+		return err
+	}
+	dC.interpolated = interpolated
+	dC.secrets = getSecrets(params)
 	return nil
 }
 
+func getParameterLookup(params map[string]*Parameter) v3.LookupFunc {
+	return func(name string) (string, bool) {
+		p, ok := params[name]
+		if !ok {
+			return "", false
+		}
+		return p.Value, true
+	}
+}
+
 func parseComposeFile(path string) (*v3.DockerComposeYaml, error) {
 	dockerComposeYml, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -79,12 +188,35 @@ func getComposeFileStreams(path string) ([]string, error) {
 	return services, nil
 }
 
+func getComposeFileUnsupportedKeys(path string) ([]string, error) {
+	dockerComposeYml, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return v3.GetUnsupportedKeys(dockerComposeYml)
+}
+
 func (dC *StepDockerCompose) Execute(emitter Emitter, t *Task) error {
+	// the compose file of the checked out commit is run rather than the one the plan was constructed from
 	contents, err := parseComposeFile(filepath.Join(t.ProjectRoot, dC.ComposeFilePath))
 	if err != nil {
+		return fmt.Errorf("could not parse %s: %s", dC.ComposeFilePath, err)
+	}
+	// parameters are interpolated before any container is created
+	contents, err = contents.Interpolate(getParameterLookup(t.parameters))
+	if err != nil {
+		return fmt.Errorf("could not interpolate %s: %s", dC.ComposeFilePath, err)
+	}
+
+	if err := v3.ValidateServiceDependencies(contents.Services); err != nil {
 		return err
 	}
 
+	if _, ok := contents.Services[dC.ExitCodeFrom]; dC.ExitCodeFrom != "" && !ok {
+		return fmt.Errorf("exitCodeFrom service %s is not defined in %s", dC.ExitCodeFrom, dC.ComposeFilePath)
+	}
+
 	serviceOrder := v3.GetServiceOrder(contents.Services, []string{})
 
 	writers := map[string]StreamWriter{}
@@ -98,23 +230,63 @@ func (dC *StepDockerCompose) Execute(emitter Emitter, t *Task) error {
 		defer writers[serviceName].Close()
 	}
 
+	for _, key := range dC.UnsupportedKeys {
+		for serviceName, writer := range writers {
+			if !strings.HasPrefix(key, "services.") || strings.HasPrefix(key, fmt.Sprintf("services.%s.", serviceName)) {
+				fmt.Fprintf(writer, output.ColorFmt(output.ANSIWarn, "-> ignoring unsupported compose key: %s", "\n"), key)
+			}
+		}
+	}
+
 	dC.containerManager = docker.NewContainerManager(
 		dC.ID,
 		GetAuthConfigsMap(t.Docker.Registries),
 		GetAddressAuthTokensMap(t.Docker.Registries),
 	)
+	dC.containerManager.SetAbortOnContainerExit(dC.AbortOnContainerExit)
+	if dC.StartTimeout > 0 {
+		dC.containerManager.SetStartTimeout(time.Duration(dC.StartTimeout))
+	}
+
+	namedVolumes := map[string]string{}
+	for volumeName, v := range contents.Volumes {
+		switch {
+		case v != nil && v.External:
+			namedVolumes[volumeName] = volumeName
+			if v.Name != "" {
+				namedVolumes[volumeName] = v.Name
+			}
+		case v != nil && v.Name != "":
+			namedVolumes[volumeName] = v.Name
+			dC.containerManager.AddVolume(&docker.Volume{Name: v.Name})
+		default:
+			namedVolumes[volumeName] = fmt.Sprintf("vci-%s-%s", dC.ID, volumeName)
+			dC.containerManager.AddVolume(&docker.Volume{Name: namedVolumes[volumeName], Ephemeral: true})
+		}
+	}
 
+	containers := map[string]*docker.Container{}
 	for _, serviceName := range serviceOrder {
 		writer := writers[serviceName]
 		writer.SetStatus(StateBuilding)
 		s := contents.Services[serviceName]
 
 		// generate containerConfig + hostConfig
-		containerConfig, hostConfig := dC.generateContainerAndHostConfig(
+		containerConfig, hostConfig, err := dC.generateContainerAndHostConfig(
 			s,
-			serviceName, t.ProjectRoot)
+			serviceName, t.ProjectRoot, namedVolumes)
+		if err != nil {
+			return fmt.Errorf("service %s: %s", serviceName, err)
+		}
+		containerConfig.Healthcheck, err = docker.NewHealthConfig(s.Healthcheck)
+		if err != nil {
+			return fmt.Errorf("service %s: %s", serviceName, err)
+		}
+		if s.Build.Context != "" {
+			s.Build.Context = filepath.Join(t.ProjectRoot, filepath.Dir(dC.ComposeFilePath), s.Build.Context)
+		}
 
-		dC.containerManager.AddContainer(docker.NewContainer(
+		containers[serviceName] = docker.NewContainer(
 			writer,
 			fmt.Sprintf("%s-%s", dC.ID, serviceName),
 			s.Image,
@@ -122,14 +294,41 @@ func (dC *StepDockerCompose) Execute(emitter Emitter, t *Task) error {
 			containerConfig,
 			hostConfig,
 			getServiceAliases(s.Networks["default"].Aliases, serviceName),
-		))
+		)
+		dependencies := []string{}
+		for dependency := range s.DependsOn {
+			dependencies = append(dependencies, dependency)
+		}
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			containers[serviceName].DependsOn(containers[dependency], s.DependsOn[dependency].Condition)
+		}
+		dC.containerManager.AddContainer(containers[serviceName])
 	}
 
-	if err := dC.containerManager.Execute(getSecrets(t.parameters)); err != nil {
+	if err := dC.containerManager.Execute(dC.newContext(t.getContext()), getSecrets(t.parameters)); err != nil {
 		return err
 	}
 
-	if !dC.containerManager.IsSuccessful() {
+	return dC.checkExitCodes()
+}
+
+// checkExitCodes returns an error if the services did not exit as the step requires
+func (dC *StepDockerCompose) checkExitCodes() error {
+	if dC.AllMustSucceed && !dC.containerManager.IsAllSuccessful() {
+		return fmt.Errorf("non-zero exit code: not all services succeeded")
+	}
+	if dC.ExitCodeFrom != "" {
+		exitCode, err := dC.containerManager.GetExitCode(fmt.Sprintf("%s-%s", dC.ID, dC.ExitCodeFrom))
+		if err != nil {
+			return err
+		}
+		if exitCode != 0 {
+			return fmt.Errorf("non-zero exit code from %s: %d", dC.ExitCodeFrom, exitCode)
+		}
+		return nil
+	}
+	if !dC.AllMustSucceed && !dC.containerManager.IsSuccessful() {
 		return fmt.Errorf("non-zero exit code")
 	}
 
@@ -137,6 +336,7 @@ func (dC *StepDockerCompose) Execute(emitter Emitter, t *Task) error {
 }
 
 func (dC *StepDockerCompose) Stop() error {
+	dC.cancelContext()
 	if dC.containerManager != nil {
 		dC.containerManager.Stop()
 	}
@@ -147,11 +347,20 @@ func (dC *StepDockerCompose) generateContainerAndHostConfig(
 	s v3.DockerComposeService,
 	serviceName,
 	projectRoot string,
-) (*container.Config, *container.HostConfig) {
-	env := []string{}
+	namedVolumes map[string]string,
+) (*container.Config, *container.HostConfig, error) {
+	environment, err := dC.readEnvFiles(s.EnvFile, projectRoot)
+	if err != nil {
+		return nil, nil, err
+	}
 	for k, v := range s.Environment {
+		environment[k] = v
+	}
+	env := []string{}
+	for k, v := range environment {
 		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
+	sort.Strings(env)
 	volumes := map[string]struct{}{}
 	binds := []string{}
 	for _, v := range s.Volumes {
@@ -162,21 +371,51 @@ func (dC *StepDockerCompose) generateContainerAndHostConfig(
 			hostMount := parts[0]
 			guestMount := parts[1:]
 			volumes[parts[1]] = struct{}{}
-			if !filepath.IsAbs(hostMount) { // no absolute paths allowed.
+			if volumeName, ok := namedVolumes[hostMount]; ok {
+				binds = append(binds, strings.Join(append([]string{volumeName}, guestMount...), ":"))
+			} else if !strings.HasPrefix(hostMount, ".") && !strings.HasPrefix(hostMount, "/") && !strings.HasPrefix(hostMount, "~") {
+				return nil, nil, fmt.Errorf("volume %s is not declared in the top-level volumes", hostMount)
+			} else if !filepath.IsAbs(hostMount) { // no absolute paths allowed.
 				hostMount = filepath.Join(projectRoot, filepath.Dir(dC.ComposeFilePath), hostMount)
-				if strings.Contains(hostMount, projectRoot) { // no further up from project root
+				if isWithinDir(projectRoot, hostMount) { // no further up from project root
 					binds = append(binds, strings.Join(append([]string{hostMount}, guestMount...), ":"))
 				}
 			}
 		}
 	}
 
+	exposedPorts, portBindings, err := nat.ParsePortSpecs(s.Ports)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, e := range s.Expose {
+		proto, port := nat.SplitProtoPort(e)
+		exposedPort, err := nat.NewPort(proto, port)
+		if err != nil {
+			return nil, nil, err
+		}
+		exposedPorts[exposedPort] = struct{}{}
+	}
+
+	tmpfs := map[string]string{}
+	for _, t := range s.Tmpfs {
+		parts := strings.SplitN(t, ":", 2)
+		if len(parts) > 1 {
+			tmpfs[parts[0]] = parts[1]
+		} else {
+			tmpfs[parts[0]] = ""
+		}
+	}
+
 	containerConfig := &container.Config{
-		Image:      s.Image,
-		Cmd:        []string(s.Command),
-		Env:        env,
-		Volumes:    volumes,
-		WorkingDir: s.WorkingDir,
+		Image:        s.Image,
+		Cmd:          []string(s.Command),
+		Entrypoint:   []string(s.Entrypoint),
+		Env:          env,
+		Volumes:      volumes,
+		WorkingDir:   s.WorkingDir,
+		User:         s.User,
+		ExposedPorts: exposedPorts,
 	}
 
 	links := []string{}
@@ -196,11 +435,50 @@ func (dC *StepDockerCompose) generateContainerAndHostConfig(
 	}
 
 	hostConfig := &container.HostConfig{
-		Binds: binds,
-		Links: links,
+		Binds:        binds,
+		Links:        links,
+		PortBindings: portBindings,
+		ExtraHosts:   s.ExtraHosts,
+		Privileged:   s.Privileged,
+		Tmpfs:        tmpfs,
 	}
 
-	return containerConfig, hostConfig
+	return containerConfig, hostConfig, nil
+}
+
+// readEnvFiles returns the variables set in the env files, which are relative to the compose file. Later files
+// take precedence.
+func (dC *StepDockerCompose) readEnvFiles(envFiles []string, projectRoot string) (map[string]string, error) {
+	environment := map[string]string{}
+	for _, envFile := range envFiles {
+		path := filepath.Join(projectRoot, filepath.Dir(dC.ComposeFilePath), envFile)
+		if !isWithinDir(projectRoot, path) { // no further up from project root
+			return nil, fmt.Errorf("env_file %s must be within the project root", envFile)
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) < 2 {
+				continue
+			}
+			environment[strings.TrimSpace(parts[0])] = parts[1]
+		}
+	}
+
+	return environment, nil
+}
+
+// isWithinDir returns whether the path is the directory or inside it
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, fmt.Sprintf("..%c", filepath.Separator))
 }
 
 func getServiceAliases(aliases []string, serviceName string) []string {
@@ -0,0 +1,30 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadEnvFilesWithinProjectRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vci-env-files-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	projectRoot := filepath.Join(dir, "workspace")
+	assert.Nil(t, os.MkdirAll(filepath.Join(projectRoot, "compose"), os.ModePerm))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "workspace-x"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(projectRoot, "compose", ".env"), []byte("A=1\n# comment\nB=x=y\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "workspace-x", "secret"), []byte("SECRET=1\n"), 0644))
+
+	dC := &StepDockerCompose{ComposeFilePath: "compose/docker-compose.yml"}
+	environment, err := dC.readEnvFiles([]string{".env"}, projectRoot)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"A": "1", "B": "x=y"}, environment)
+
+	// a sibling directory that starts with the project root's name is outside of it
+	_, err = dC.readEnvFiles([]string{"../../workspace-x/secret"}, projectRoot)
+	assert.EqualError(t, err, "env_file ../../workspace-x/secret must be within the project root")
+}
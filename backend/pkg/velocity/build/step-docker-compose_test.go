@@ -0,0 +1,60 @@
+package build_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+)
+
+func TestStepDockerComposeSetParams(t *testing.T) {
+	projectRoot, err := ioutil.TempDir("", "compose")
+	assert.Nil(t, err)
+	defer os.RemoveAll(projectRoot)
+	err = ioutil.WriteFile(filepath.Join(projectRoot, "docker-compose.yml"), []byte(`
+version: '3'
+services:
+  app:
+    image: app:${git.commit.sha.short}
+    environment:
+      TOKEN: ${token}
+`), 0644)
+	assert.Nil(t, err)
+
+	step := build.NewStepDockerCompose(&config.StepDockerCompose{ComposeFile: "docker-compose.yml"}, projectRoot)
+
+	assert.EqualError(t, step.Validate(map[string]build.Parameter{}), "parameter git.commit.sha.short missing")
+
+	params := map[string]*build.Parameter{
+		"git.commit.sha.short": {Name: "git.commit.sha.short", Value: "abc1234"},
+		"token":                {Name: "token", Value: "s3cr3t", IsSecret: true},
+	}
+	assert.Nil(t, step.SetParams(params))
+
+	details := step.GetDetails()
+	assert.Contains(t, details, "image: app:abc1234")
+	assert.Contains(t, details, "TOKEN: '***'")
+	assert.NotContains(t, details, "s3cr3t")
+}
+
+func TestStepDockerComposeExecuteReadsCheckedOutComposeFile(t *testing.T) {
+	planRoot, err := ioutil.TempDir("", "compose")
+	assert.Nil(t, err)
+	defer os.RemoveAll(planRoot)
+	err = ioutil.WriteFile(filepath.Join(planRoot, "docker-compose.yml"), []byte("version: '3'\nservices:\n  app:\n    image: app\n"), 0644)
+	assert.Nil(t, err)
+	workspace, err := ioutil.TempDir("", "compose")
+	assert.Nil(t, err)
+	defer os.RemoveAll(workspace)
+	err = ioutil.WriteFile(filepath.Join(workspace, "docker-compose.yml"), []byte("services: ["), 0644)
+	assert.Nil(t, err)
+
+	step := build.NewStepDockerCompose(&config.StepDockerCompose{ComposeFile: "docker-compose.yml"}, planRoot)
+	err = step.Execute(build.NewBlankEmitter(), &build.Task{ProjectRoot: workspace})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not parse docker-compose.yml")
+}
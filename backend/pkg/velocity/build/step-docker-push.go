@@ -1,7 +1,9 @@
 package build
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/ghodss/yaml"
@@ -15,28 +17,53 @@ import (
 
 type StepDockerPush struct {
 	BaseStep
-	Tags []string `json:"tags"`
+	Tags []string                   `json:"tags"`
+	Sign *config.StepDockerPushSign `json:"sign"`
+	SBOM bool                       `json:"sbom"`
 
-	pusher *docker.ImagePusher
+	// signingKey is the sign key with secret parameters too, which are kept out of Sign.Key as it is shown in the
+	// step's details
+	signingKey string
+
+	pusher         *docker.ImagePusher
+	manifestPusher *docker.ManifestPusher
+	signer         docker.Signer
+	sbomGenerator  *docker.SBOMGenerator
 }
 
 func NewStepDockerPush(c *config.StepDockerPush) *StepDockerPush {
+	var sign *config.StepDockerPushSign
+	if c.Sign != nil {
+		s := *c.Sign
+		sign = &s
+	}
 	return &StepDockerPush{
-		BaseStep: newBaseStep("push", []string{"push"}),
+		BaseStep: newBaseStepFromConfig("push", []string{"push"}, c.BaseStep),
 		Tags:     c.Tags,
+		Sign:     sign,
+		SBOM:     c.SBOM,
 	}
 }
 
 func (dP StepDockerPush) GetDetails() string {
 	type details struct {
-		Tags []string `json:"tags"`
+		Tags []string                   `json:"tags"`
+		Sign *config.StepDockerPushSign `json:"sign,omitempty"`
+		SBOM bool                       `json:"sbom,omitempty"`
 	}
 	y, _ := yaml.Marshal(&details{
 		Tags: dP.Tags,
+		Sign: dP.Sign,
+		SBOM: dP.SBOM,
 	})
 	return string(y)
 }
 
+// GetDigestParameterName returns the name of the parameter that holds the digest pushed for a tag
+func GetDigestParameterName(tag string) string {
+	return fmt.Sprintf("push.%s.digest", tag)
+}
+
 func (dP *StepDockerPush) Execute(emitter Emitter, tsk *Task) error {
 	writer, err := dP.GetStreamWriter(emitter, "push")
 	if err != nil {
@@ -46,18 +73,31 @@ func (dP *StepDockerPush) Execute(emitter Emitter, tsk *Task) error {
 	writer.SetStatus(StateBuilding)
 	fmt.Fprintf(writer, "\r")
 
+	ctx := dP.newContext(tsk.getContext())
 	dP.pusher = docker.NewImagePusher()
+	dP.manifestPusher = docker.NewManifestPusher()
+	dP.sbomGenerator = docker.NewSBOMGenerator()
+	if dP.Sign != nil {
+		dP.signer, err = dP.newSigner(tsk)
+		if err != nil {
+			writer.SetStatus(StateFailed)
+			fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> could not create signer: %s", "\n"), err)
+			return err
+		}
+	}
 
 	for _, t := range dP.Tags {
-		err := dP.pusher.Push(
-			writer,
-			getSecrets(tsk.parameters),
-			t,
-			GetAddressAuthTokensMap(tsk.Docker.Registries),
-		)
+		digest, err := dP.push(ctx, writer, tsk, t)
+		if err == nil {
+			tsk.parameters[GetDigestParameterName(t)] = &Parameter{
+				Name:  GetDigestParameterName(t),
+				Value: digest,
+			}
+			err = dP.sign(ctx, writer, tsk, t, digest)
+		}
 		if err != nil {
 			logging.GetLogger().Error("could not push docker image", zap.String("image", t), zap.Error(err))
-			writer.SetStatus(StateFailed)
+			writer.SetStatus(dP.getFailedState())
 			fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> push failed: %s", "\n"), err)
 			return err
 		}
@@ -69,10 +109,99 @@ func (dP *StepDockerPush) Execute(emitter Emitter, tsk *Task) error {
 
 }
 
-func (dP *StepDockerPush) Stop() error {
-	if dP.pusher != nil {
-		dP.pusher.Stop()
+// newSigner returns the signer of the sign configuration. The key signer saves signatures and SBOMs as artifacts
+// of the Task.
+func (dP *StepDockerPush) newSigner(tsk *Task) (docker.Signer, error) {
+	var store docker.SignatureStore
+	if dP.Sign.Signer == "" || dP.Sign.Signer == docker.SignerKey {
+		if tsk.ArtifactStore == nil {
+			return nil, fmt.Errorf("no artifact store configured to save signatures into")
+		}
+		store = &artifactSignatureStore{task: tsk, stepID: dP.ID}
+	}
+	return docker.NewSigner(
+		dP.Sign.Signer,
+		getSigningKey(dP.signingKey, tsk.ProjectRoot),
+		store,
+		getSecrets(tsk.parameters),
+		GetAuthConfigsMap(tsk.Docker.Registries),
+	)
+}
+
+// getSigningKey returns the signing key with a path relative to the project root made absolute. Absolute paths, PEM
+// encoded keys from secret parameters and cosign key references e.g. awskms:// are returned unchanged.
+func getSigningKey(key, projectRoot string) string {
+	if filepath.IsAbs(key) || docker.IsKeyReference(key) || strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN ") {
+		return key
+	}
+	return filepath.Join(projectRoot, key)
+}
+
+// push pushes the tag, or when it was built for multiple platforms, its platforms' images and a manifest list of them
+func (dP *StepDockerPush) push(ctx context.Context, writer StreamWriter, tsk *Task, tag string) (string, error) {
+	images, ok := tsk.platformImages[tag]
+	if !ok {
+		return dP.pusher.Push(
+			ctx,
+			writer,
+			getSecrets(tsk.parameters),
+			tag,
+			GetAddressAuthTokensMap(tsk.Docker.Registries),
+		)
+	}
+
+	for _, image := range images {
+		_, err := dP.pusher.Push(
+			ctx,
+			writer,
+			getSecrets(tsk.parameters),
+			image,
+			GetAddressAuthTokensMap(tsk.Docker.Registries),
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+	return dP.manifestPusher.Push(
+		ctx,
+		writer,
+		getSecrets(tsk.parameters),
+		tag,
+		images,
+		GetAuthConfigsMap(tsk.Docker.Registries),
+	)
+}
+
+// sign signs the pushed digest and attaches an SBOM generated from it
+func (dP *StepDockerPush) sign(ctx context.Context, writer StreamWriter, tsk *Task, tag, digest string) error {
+	if dP.signer == nil {
+		return nil
 	}
+	if digest == "" {
+		return fmt.Errorf("could not determine the pushed digest of %s", tag)
+	}
+	if err := dP.signer.Sign(ctx, writer, tag, digest); err != nil {
+		return err
+	}
+	if !dP.SBOM {
+		return nil
+	}
+	sbom, err := dP.sbomGenerator.Generate(
+		ctx,
+		writer,
+		getSecrets(tsk.parameters),
+		tag,
+		digest,
+		GetAuthConfigsMap(tsk.Docker.Registries),
+	)
+	if err != nil {
+		return err
+	}
+	return dP.signer.AttachSBOM(ctx, writer, tag, digest, sbom)
+}
+
+func (dP *StepDockerPush) Stop() error {
+	dP.cancelContext()
 	return nil
 }
 
@@ -81,6 +210,9 @@ func (dP StepDockerPush) Validate(params map[string]Parameter) error {
 }
 
 func (dP *StepDockerPush) SetParams(params map[string]*Parameter) error {
+	if dP.Sign != nil && dP.signingKey == "" {
+		dP.signingKey = dP.Sign.Key
+	}
 	for paramName, param := range params {
 		tags := []string{}
 		for _, c := range dP.Tags {
@@ -88,6 +220,12 @@ func (dP *StepDockerPush) SetParams(params map[string]*Parameter) error {
 			tags = append(tags, correctedTag)
 		}
 		dP.Tags = tags
+		if dP.Sign != nil {
+			dP.signingKey = strings.Replace(dP.signingKey, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+			if !param.IsSecret {
+				dP.Sign.Key = strings.Replace(dP.Sign.Key, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+			}
+		}
 	}
 	return nil
 }
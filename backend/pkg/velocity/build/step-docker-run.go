@@ -20,6 +20,8 @@ type StepDockerRun struct {
 	WorkingDir     string            `json:"workingDir"`
 	MountPoint     string            `json:"mountPoint"`
 	IgnoreExitCode bool              `json:"ignoreExitCode"`
+	// Services are started before the main container, each with its own output stream
+	Services []*config.StepDockerRunService `json:"services"`
 
 	containerManager *docker.ContainerManager
 }
@@ -31,14 +33,28 @@ func NewStepDockerRun(c *config.StepDockerRun) *StepDockerRun {
 	if c.Environment == nil {
 		c.Environment = map[string]string{}
 	}
+	streams := []string{"run"}
+	// services are copied as parameters are substituted into them per step
+	services := []*config.StepDockerRunService{}
+	for _, s := range c.Services {
+		service := *s
+		service.Command = append([]string{}, s.Command...)
+		service.Environment = map[string]string{}
+		for k, v := range s.Environment {
+			service.Environment[k] = v
+		}
+		services = append(services, &service)
+		streams = append(streams, s.Name)
+	}
 	return &StepDockerRun{
-		BaseStep:       newBaseStep("run", []string{"run"}),
+		BaseStep:       newBaseStepFromConfig("run", streams, c.BaseStep),
 		Image:          c.Image,
 		Command:        c.Command,
 		Environment:    c.Environment,
 		WorkingDir:     c.WorkingDir,
 		MountPoint:     c.MountPoint,
 		IgnoreExitCode: c.IgnoreExitCode,
+		Services:       services,
 	}
 }
 
@@ -50,6 +66,11 @@ func (dR StepDockerRun) GetDetails() string {
 		WorkingDir     string            `json:"workingDir"`
 		MountPoint     string            `json:"mountPoint"`
 		IgnoreExitCode bool              `json:"ignoreExitCode"`
+		Services       []string          `json:"services,omitempty"`
+	}
+	services := []string{}
+	for _, s := range dR.Services {
+		services = append(services, fmt.Sprintf("%s (%s)", s.Name, s.Image))
 	}
 	y, _ := yaml.Marshal(&details{
 		Image:          dR.Image,
@@ -58,6 +79,7 @@ func (dR StepDockerRun) GetDetails() string {
 		WorkingDir:     dR.WorkingDir,
 		MountPoint:     dR.MountPoint,
 		IgnoreExitCode: dR.IgnoreExitCode,
+		Services:       services,
 	})
 	return string(y)
 }
@@ -71,6 +93,17 @@ func (dR *StepDockerRun) Execute(emitter Emitter, t *Task) error {
 	writer.SetStatus(StateBuilding)
 	fmt.Fprintf(writer, "\r")
 
+	serviceWriters := map[string]StreamWriter{}
+	for _, s := range dR.Services {
+		serviceWriter, err := dR.GetStreamWriter(emitter, s.Name)
+		if err != nil {
+			return err
+		}
+		defer serviceWriter.Close()
+		serviceWriter.SetStatus(StateBuilding)
+		serviceWriters[s.Name] = serviceWriter
+	}
+
 	env := []string{}
 	for k, v := range dR.Environment {
 		env = append(env, fmt.Sprintf("%s=%s", k, v))
@@ -103,7 +136,19 @@ func (dR *StepDockerRun) Execute(emitter Emitter, t *Task) error {
 		GetAddressAuthTokensMap(t.Docker.Registries),
 	)
 
-	dR.containerManager.AddContainer(docker.NewContainer(
+	cacheMounts, err := t.getCacheMounts()
+	if err != nil {
+		writer.SetStatus(StateFailed)
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> error: %s", "\n"), err)
+		return err
+	}
+	for _, m := range cacheMounts {
+		dR.containerManager.AddVolume(m.volume)
+		hostConfig.Binds = append(hostConfig.Binds, fmt.Sprintf("%s:%s", m.volume.Name, m.path))
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> using cache %s at %s (%s)", "\n"), m.volume.Labels[docker.CacheNameLabel], m.path, m.volume.Name)
+	}
+
+	runContainer := docker.NewContainer(
 		writer,
 		fmt.Sprintf("%s-%s", dR.ID, "run"),
 		dR.Image,
@@ -111,9 +156,34 @@ func (dR *StepDockerRun) Execute(emitter Emitter, t *Task) error {
 		config,
 		hostConfig,
 		nil,
-	))
+	)
+	for _, s := range dR.Services {
+		serviceContainer, err := dR.newServiceContainer(s, serviceWriters[s.Name])
+		if err != nil {
+			writer.SetStatus(StateFailed)
+			fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> error: service %s: %s", "\n"), s.Name, err)
+			return err
+		}
+		dR.containerManager.AddServiceContainer(serviceContainer)
+		if s.Healthcheck != nil && !s.Healthcheck.Disable {
+			runContainer.DependsOn(serviceContainer, docker.ConditionHealthy)
+		} else {
+			runContainer.DependsOn(serviceContainer, docker.ConditionStarted)
+		}
+	}
+	dR.containerManager.AddContainer(runContainer)
 
-	if err := dR.containerManager.Execute(getSecrets(t.parameters)); err != nil {
+	err = dR.containerManager.Execute(dR.newContext(t.getContext()), getSecrets(t.parameters))
+	for _, serviceWriter := range serviceWriters {
+		if err != nil {
+			serviceWriter.SetStatus(dR.getFailedState())
+		} else {
+			serviceWriter.SetStatus(StateSuccess)
+		}
+	}
+	if err != nil {
+		writer.SetStatus(dR.getFailedState())
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> error: %s", "\n"), err)
 		return err
 	}
 
@@ -130,7 +200,34 @@ func (dR *StepDockerRun) Execute(emitter Emitter, t *Task) error {
 	return nil
 }
 
+func (dR *StepDockerRun) newServiceContainer(s *config.StepDockerRunService, writer StreamWriter) (*docker.Container, error) {
+	healthConfig, err := docker.NewHealthConfig(s.Healthcheck)
+	if err != nil {
+		return nil, err
+	}
+	env := []string{}
+	for k, v := range s.Environment {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return docker.NewContainer(
+		writer,
+		fmt.Sprintf("%s-%s", dR.ID, s.Name),
+		s.Image,
+		nil,
+		&container.Config{
+			Image:       s.Image,
+			Cmd:         []string(s.Command),
+			Env:         env,
+			Healthcheck: healthConfig,
+		},
+		&container.HostConfig{},
+		append([]string{s.Name}, s.Aliases...),
+	), nil
+}
+
 func (dR *StepDockerRun) Stop() error {
+	dR.cancelContext()
 	if dR.containerManager != nil {
 		dR.containerManager.Stop()
 	}
@@ -165,6 +262,25 @@ func (dR StepDockerRun) Validate(params map[string]Parameter) error {
 			return fmt.Errorf("Parameter %v missing", requiredParams)
 		}
 	}
+
+	for _, s := range dR.Services {
+		requiredParams = re.FindAllStringSubmatch(s.Image, -1)
+		if !isAllInParams(requiredParams, params) {
+			return fmt.Errorf("Parameter %v missing", requiredParams)
+		}
+		for _, c := range s.Command {
+			requiredParams = re.FindAllStringSubmatch(c, -1)
+			if !isAllInParams(requiredParams, params) {
+				return fmt.Errorf("Parameter %v missing", requiredParams)
+			}
+		}
+		for _, val := range s.Environment {
+			requiredParams = re.FindAllStringSubmatch(val, -1)
+			if !isAllInParams(requiredParams, params) {
+				return fmt.Errorf("Parameter %v missing", requiredParams)
+			}
+		}
+	}
 	return nil
 }
 
@@ -188,6 +304,16 @@ func (dR *StepDockerRun) SetParams(params map[string]*Parameter) error {
 		}
 
 		dR.Environment = env
+
+		for _, s := range dR.Services {
+			s.Image = strings.Replace(s.Image, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+			for i, c := range s.Command {
+				s.Command[i] = strings.Replace(c, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+			}
+			for key, val := range s.Environment {
+				s.Environment[key] = strings.Replace(val, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+			}
+		}
 	}
 	return nil
 }
@@ -0,0 +1,197 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/ghodss/yaml"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/docker"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"go.uber.org/zap"
+)
+
+// StepDockerTag pulls the source image, tags it with the tags and pushes them. Multi-platform images are promoted by
+// copying every platform of the source to each tag's repository and pushing a manifest list of the copies.
+type StepDockerTag struct {
+	BaseStep
+	Source string   `json:"source"`
+	Tags   []string `json:"tags"`
+}
+
+func NewStepDockerTag(c *config.StepDockerTag) *StepDockerTag {
+	return &StepDockerTag{
+		BaseStep: newBaseStepFromConfig("tag", []string{"tag"}, c.BaseStep),
+		Source:   c.Source,
+		Tags:     c.Tags,
+	}
+}
+
+func (dT StepDockerTag) GetDetails() string {
+	type details struct {
+		Source string   `json:"source"`
+		Tags   []string `json:"tags"`
+	}
+	y, _ := yaml.Marshal(&details{
+		Source: dT.Source,
+		Tags:   dT.Tags,
+	})
+	return string(y)
+}
+
+func (dT *StepDockerTag) Execute(emitter Emitter, tsk *Task) error {
+	writer, err := dT.GetStreamWriter(emitter, "tag")
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	writer.SetStatus(StateBuilding)
+	fmt.Fprintf(writer, "\r")
+
+	if err := dT.execute(dT.newContext(tsk.getContext()), writer, tsk); err != nil {
+		writer.SetStatus(dT.getFailedState())
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> tag failed: %s", "\n"), err)
+		return err
+	}
+
+	writer.SetStatus(StateSuccess)
+	fmt.Fprintf(writer, output.ColorFmt(output.ANSISuccess, "-> success", "\n"))
+	return nil
+}
+
+func (dT *StepDockerTag) execute(ctx context.Context, writer StreamWriter, tsk *Task) error {
+	manifest, err := docker.InspectManifest(ctx, dT.Source, GetAuthConfigsMap(tsk.Docker.Registries))
+	if err != nil {
+		return err
+	}
+	images, err := docker.ManifestListImages(dT.Source, manifest)
+	if err != nil {
+		return err
+	}
+	if len(images) > 0 {
+		return dT.tagManifestList(ctx, writer, tsk, images)
+	}
+
+	source := docker.NewContainer(writer, dT.Source, dT.Source, nil, &container.Config{Image: dT.Source}, nil, nil)
+	err = source.Pull(
+		ctx,
+		getSecrets(tsk.parameters),
+		GetAuthConfigsMap(tsk.Docker.Registries),
+		GetAddressAuthTokensMap(tsk.Docker.Registries),
+	)
+	if err != nil {
+		return err
+	}
+
+	pusher := docker.NewImagePusher()
+	for _, t := range dT.Tags {
+		if err := docker.TagImage(ctx, dT.Source, t); err != nil {
+			return err
+		}
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> tagged %s as %s", "\n"), dT.Source, t)
+
+		digest, err := pusher.Push(
+			ctx,
+			writer,
+			getSecrets(tsk.parameters),
+			t,
+			GetAddressAuthTokensMap(tsk.Docker.Registries),
+		)
+		if err != nil {
+			logging.GetLogger().Error("could not push docker image", zap.String("image", t), zap.Error(err))
+			return err
+		}
+		tsk.parameters[GetDigestParameterName(t)] = &Parameter{
+			Name:  GetDigestParameterName(t),
+			Value: digest,
+		}
+	}
+
+	return nil
+}
+
+// tagManifestList copies the source's platform images to each tag's repository, as PlatformTag images, and pushes a
+// manifest list of the copies as the tag. Manifest lists can only reference manifests within their own repository.
+func (dT *StepDockerTag) tagManifestList(ctx context.Context, writer StreamWriter, tsk *Task, images []docker.PlatformImage) error {
+	for _, image := range images {
+		source := docker.NewContainer(writer, image.Image, image.Image, nil, &container.Config{Image: image.Image}, nil, nil)
+		err := source.Pull(
+			ctx,
+			getSecrets(tsk.parameters),
+			GetAuthConfigsMap(tsk.Docker.Registries),
+			GetAddressAuthTokensMap(tsk.Docker.Registries),
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %s", image.Platform, err)
+		}
+	}
+
+	pusher := docker.NewImagePusher()
+	manifestPusher := docker.NewManifestPusher()
+	for _, t := range dT.Tags {
+		platformImages := []string{}
+		for _, image := range images {
+			platformTag := docker.PlatformTag(t, image.Platform)
+			if err := docker.TagImage(ctx, image.Image, platformTag); err != nil {
+				return err
+			}
+			platformDigest, err := pusher.Push(
+				ctx,
+				writer,
+				getSecrets(tsk.parameters),
+				platformTag,
+				GetAddressAuthTokensMap(tsk.Docker.Registries),
+			)
+			if err != nil {
+				logging.GetLogger().Error("could not push docker image", zap.String("image", platformTag), zap.Error(err))
+				return err
+			}
+			platformImages = append(platformImages, docker.DigestImage(t, platformDigest))
+		}
+
+		digest, err := manifestPusher.Push(
+			ctx,
+			writer,
+			getSecrets(tsk.parameters),
+			t,
+			platformImages,
+			GetAuthConfigsMap(tsk.Docker.Registries),
+		)
+		if err != nil {
+			logging.GetLogger().Error("could not push manifest list", zap.String("image", t), zap.Error(err))
+			return err
+		}
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> tagged %s as %s", "\n"), dT.Source, t)
+		tsk.parameters[GetDigestParameterName(t)] = &Parameter{
+			Name:  GetDigestParameterName(t),
+			Value: digest,
+		}
+	}
+
+	return nil
+}
+
+func (dT *StepDockerTag) Stop() error {
+	dT.cancelContext()
+	return nil
+}
+
+func (dT StepDockerTag) Validate(params map[string]Parameter) error {
+	return nil
+}
+
+func (dT *StepDockerTag) SetParams(params map[string]*Parameter) error {
+	for paramName, param := range params {
+		dT.Source = strings.Replace(dT.Source, fmt.Sprintf("${%s}", paramName), param.Value, -1)
+		tags := []string{}
+		for _, t := range dT.Tags {
+			tags = append(tags, strings.Replace(t, fmt.Sprintf("${%s}", paramName), param.Value, -1))
+		}
+		dT.Tags = tags
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package build_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+)
+
+func TestStepDockerTagSetParams(t *testing.T) {
+	step := build.NewStepDockerTag(&config.StepDockerTag{
+		Source: "staging.example.com/app:${git.commit.sha.short}",
+		Tags:   []string{"registry.example.com/app:${git.commit.sha.short}", "registry.example.com/app:latest"},
+	})
+
+	err := step.SetParams(map[string]*build.Parameter{
+		"git.commit.sha.short": {Name: "git.commit.sha.short", Value: "abc1234"},
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, "staging.example.com/app:abc1234", step.Source)
+	assert.Equal(t, []string{"registry.example.com/app:abc1234", "registry.example.com/app:latest"}, step.Tags)
+	assert.Equal(t, "tag", step.GetType())
+}
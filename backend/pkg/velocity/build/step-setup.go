@@ -4,15 +4,16 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"regexp"
 	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/gosimple/slug"
 	"github.com/velocity-ci/velocity/backend/pkg/auth"
 	"github.com/velocity-ci/velocity/backend/pkg/git"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
 	"go.uber.org/zap"
@@ -66,12 +67,6 @@ func (s Setup) GetDetails() string {
 	return "n/a"
 }
 
-func makeVelocityDirs(projectRoot string) error {
-	os.MkdirAll(filepath.Join(projectRoot, ".velocityci/plugins"), os.ModePerm)
-
-	return nil
-}
-
 func sortedParameterKeys(params map[string]Parameter) []string {
 	keys := make([]string, 0, len(params))
 	for key := range params {
@@ -95,11 +90,12 @@ func (s *Setup) Execute(emitter Emitter, t *Task) error {
 	fmt.Fprintf(writer, "\r")
 
 	// Clone repository if necessary
+	var repo *git.RawRepository
 	if s.repository != nil {
 		dir, _ := getUniqueWorkspace(s.repository)
-		repo, err := git.Clone(
+		repo, err = git.Clone(
 			s.repository,
-			&git.CloneOptions{Bare: false, Submodule: true, Commit: s.commitHash},
+			&git.CloneOptions{Bare: false, Submodule: false, Commit: s.commitHash},
 			dir,
 			writer,
 		)
@@ -116,11 +112,42 @@ func (s *Setup) Execute(emitter Emitter, t *Task) error {
 			fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "Could not checkout ref: %s", "\n"), err)
 			return err
 		}
-		os.Chdir(repo.Directory)
 		t.ProjectRoot = repo.Directory
 	}
 
-	if err := makeVelocityDirs(t.ProjectRoot); err != nil {
+	// The project configuration is read from the checked out commit unless the plan has given it
+	if t.root == nil {
+		t.root, err = config.GetRootConfigFromPath(t.ProjectRoot)
+		if err != nil {
+			writer.SetStatus(StateFailed)
+			fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> Could not read project configuration: %s", "\n"), err)
+			return err
+		}
+	}
+	if len(t.root.ValidationErrors) > 0 {
+		writer.SetStatus(StateFailed)
+		err := fmt.Errorf("invalid project configuration: %s", strings.Join(t.root.ValidationErrors, ", "))
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> %s", "\n"), err)
+		return err
+	}
+	if repo != nil && t.root.Git.Submodule {
+		if err := repo.UpdateSubmodules(writer); err != nil {
+			logging.GetLogger().Error("could not update submodules", zap.Error(err))
+			writer.SetStatus(StateFailed)
+			fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> Could not update submodules: %s", "\n"), err)
+			return err
+		}
+	}
+
+	t.project = GetProjectName(s.repository, t.ProjectRoot)
+	if s.repository != nil {
+		t.privateKey = s.repository.PrivateKey
+	}
+
+	// Restore artifacts from the Tasks this Task takes them from
+	if err := t.restoreArtifacts(writer); err != nil {
+		writer.SetStatus(StateFailed)
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "-> %s", "\n"), err)
 		return err
 	}
 
@@ -130,6 +157,9 @@ func (s *Setup) Execute(emitter Emitter, t *Task) error {
 	if err != nil {
 		return err
 	}
+	for k, v := range t.getMatrixParams() {
+		basicParams[k] = *v
+	}
 	tabWriter := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
 	for _, k := range sortedParameterKeys(basicParams) {
 		v := basicParams[k]
@@ -137,22 +167,33 @@ func (s *Setup) Execute(emitter Emitter, t *Task) error {
 		fmt.Fprintf(tabWriter, "Set %s\t%s\n", k, v.Value)
 	}
 	tabWriter.Flush()
-	for _, configParam := range t.Blueprint.Parameters {
-		resolvedParams, err := resolveConfigParameter(configParam, s.backupResolver, t.ProjectRoot, writer)
+	// Root parameters are resolved before the Blueprint's, unless the plan has resolved them once for every Task
+	configParams := []config.Parameter{}
+	if t.rootParameters != nil {
+		for _, param := range t.rootParameters {
+			setResolvedParameter(writer, t, param)
+		}
+	} else {
+		configParams = append(configParams, t.root.Parameters...)
+	}
+	configParams = append(configParams, t.Blueprint.Parameters...)
+	// Every parameter is resolved and validated before failing so that all of the invalid ones are reported
+	paramErrs := []string{}
+	for _, configParam := range configParams {
+		resolvedParams, err := resolveConfigParameter(t.getContext(), configParam, s.backupResolver, writer, t.getDerivedParameterCache())
 		if err != nil {
-			writer.SetStatus(StateFailed)
 			fmt.Fprintf(writer, output.ColorFmt(output.ANSIError, "Could not resolve parameter: %s", "\n"), err)
-			return fmt.Errorf("could not resolve %v", err)
+			paramErrs = append(paramErrs, err.Error())
+			continue
 		}
 		for _, param := range resolvedParams {
-			t.parameters[param.Name] = param
-			if param.IsSecret {
-				fmt.Fprintf(writer, "Set %s: ***\n", param.Name)
-			} else {
-				fmt.Fprintf(writer, "Set %s: %v\n", param.Name, param.Value)
-			}
+			setResolvedParameter(writer, t, param)
 		}
 	}
+	if len(paramErrs) > 0 {
+		writer.SetStatus(StateFailed)
+		return fmt.Errorf("could not resolve %s", strings.Join(paramErrs, ", "))
+	}
 
 	// Login to docker registries
 	authedRegistries := []DockerRegistry{}
@@ -176,6 +217,15 @@ func (s *Setup) Execute(emitter Emitter, t *Task) error {
 	return nil
 }
 
+func setResolvedParameter(writer io.Writer, t *Task, param *Parameter) {
+	t.parameters[param.Name] = param
+	if param.IsSecret {
+		fmt.Fprintf(writer, "Set %s: ***\n", param.Name)
+	} else {
+		fmt.Fprintf(writer, "Set %s: %v\n", param.Name, param.Value)
+	}
+}
+
 func (s *Setup) Stop() error {
 	return nil
 }
@@ -1,11 +1,14 @@
 package build
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
 )
 
 type Step interface {
@@ -15,6 +18,9 @@ type Step interface {
 	GetType() string
 	GetDescription() string
 	GetDetails() string
+	GetWhen() string
+	GetExecutionOptions() StepExecutionOptions
+	GetArtifacts() []string
 	SetParams(map[string]*Parameter) error
 	GetOutputStreams() []*Stream
 
@@ -27,6 +33,9 @@ const (
 	StateBuilding = "building"
 	StateSuccess  = "succeeded"
 	StateFailed   = "failed"
+	StateSkipped  = "skipped"
+	// StateCancelled is a step or task that was stopped e.g. by SIGINT in vcli or a job-stop from the architect
+	StateCancelled = "cancelled"
 )
 
 //
@@ -59,14 +68,27 @@ type BaseStep struct {
 	ID          string `json:"id"`
 	Type        string `json:"type" yaml:"type"`
 	Description string `json:"description" yaml:"description"`
+	When        string `json:"when" yaml:"when"`
+
+	IgnoreErrors bool             `json:"ignoreErrors" yaml:"ignoreErrors"`
+	Retry        config.StepRetry `json:"retry" yaml:"retry"`
+	Timeout      config.Duration  `json:"timeout" yaml:"timeout"`
+	Artifacts    []string         `json:"artifacts" yaml:"artifacts"`
 
 	OutputStreams []*Stream  `json:"outputStreams" yaml:"-"`
 	Status        string     `json:"status"`
 	StartedAt     *time.Time `json:"startedAt"`
 	UpdatedAt     *time.Time `json:"updatedAt"`
 	CompletedAt   *time.Time `json:"completedAt"`
+
+	// ctx is the context of the step's current execution, cancelled by Stop
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
+// stepContextMutex guards the contexts of the steps' executions as steps are stopped from other goroutines
+var stepContextMutex sync.Mutex
+
 func newBaseStep(t string, streamNames []string) BaseStep {
 	streams := []*Stream{}
 	for _, streamName := range streamNames {
@@ -84,6 +106,26 @@ func newBaseStep(t string, streamNames []string) BaseStep {
 	}
 }
 
+func newBaseStepFromConfig(t string, streamNames []string, c config.BaseStep) BaseStep {
+	bS := newBaseStep(t, streamNames)
+	bS.Description = c.Description
+	bS.When = c.When
+	bS.IgnoreErrors = c.IgnoreErrors
+	bS.Retry = c.Retry
+	bS.Timeout = c.Timeout
+	bS.Artifacts = c.Artifacts
+
+	return bS
+}
+
+// StepExecutionOptions are the generic options that Task.executeStep enforces for every step type
+type StepExecutionOptions struct {
+	IgnoreErrors bool
+	Attempts     uint
+	Backoff      time.Duration
+	Timeout      time.Duration
+}
+
 func (bS *BaseStep) GetID() string {
 	return bS.ID
 }
@@ -96,10 +138,59 @@ func (bS *BaseStep) GetDescription() string {
 	return bS.Description
 }
 
+func (bS *BaseStep) GetWhen() string {
+	return bS.When
+}
+
+func (bS *BaseStep) GetExecutionOptions() StepExecutionOptions {
+	return StepExecutionOptions{
+		IgnoreErrors: bS.IgnoreErrors,
+		Attempts:     bS.Retry.Attempts + 1,
+		Backoff:      time.Duration(bS.Retry.Backoff),
+		Timeout:      time.Duration(bS.Timeout),
+	}
+}
+
+func (bS *BaseStep) GetArtifacts() []string {
+	return bS.Artifacts
+}
+
 func (bS *BaseStep) GetOutputStreams() []*Stream {
 	return bS.OutputStreams
 }
 
+// newContext returns the context for an execution of the step, derived from the Task's. cancelContext cancels it.
+func (bS *BaseStep) newContext(parent context.Context) context.Context {
+	stepContextMutex.Lock()
+	defer stepContextMutex.Unlock()
+	bS.ctx, bS.cancel = context.WithCancel(parent)
+	return bS.ctx
+}
+
+// cancelContext interrupts the step's current execution
+func (bS *BaseStep) cancelContext() {
+	stepContextMutex.Lock()
+	defer stepContextMutex.Unlock()
+	if bS.cancel != nil {
+		bS.cancel()
+	}
+}
+
+// isCancelled returns whether the step's current execution has been cancelled
+func (bS *BaseStep) isCancelled() bool {
+	stepContextMutex.Lock()
+	defer stepContextMutex.Unlock()
+	return bS.ctx != nil && bS.ctx.Err() != nil
+}
+
+// getFailedState returns the state of an execution of the step that returned an error
+func (bS *BaseStep) getFailedState() string {
+	if bS.isCancelled() {
+		return StateCancelled
+	}
+	return StateFailed
+}
+
 func (bS *BaseStep) GetStreamWriter(emitter Emitter, streamName string) (StreamWriter, error) {
 	for _, outputStream := range bS.GetOutputStreams() {
 		if outputStream.Name == streamName {
@@ -163,6 +254,18 @@ func unmarshalStep(rawMessage []byte) (Step, error) {
 				Type: "push",
 			},
 		}
+	case "tag":
+		s = &StepDockerTag{
+			BaseStep: BaseStep{
+				Type: "tag",
+			},
+		}
+	case "blueprint":
+		s = &StepBlueprint{
+			BaseStep: BaseStep{
+				Type: "blueprint",
+			},
+		}
 		// case "plugin":
 		// 	s = NewPlugin()
 		// 	break
@@ -0,0 +1,38 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepContextCancelledByTaskStop(t *testing.T) {
+	task := &Task{}
+	step := &BaseStep{}
+
+	ctx := step.newContext(task.getContext())
+	assert.Nil(t, ctx.Err())
+	assert.Equal(t, StateFailed, step.getFailedState())
+
+	task.Stop()
+	assert.NotNil(t, ctx.Err())
+	assert.Equal(t, StateCancelled, step.getFailedState())
+
+	// a stopped Task's steps are cancelled as soon as they start
+	ctx = step.newContext(task.getContext())
+	assert.NotNil(t, ctx.Err())
+}
+
+func TestStepContextCancelledByStepStop(t *testing.T) {
+	task := &Task{}
+	step := &BaseStep{}
+
+	ctx := step.newContext(task.getContext())
+	step.cancelContext()
+	assert.NotNil(t, ctx.Err())
+	assert.Nil(t, task.getContext().Err())
+
+	// retries get a new context
+	ctx = step.newContext(task.getContext())
+	assert.Nil(t, ctx.Err())
+}
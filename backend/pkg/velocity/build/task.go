@@ -1,11 +1,17 @@
 package build
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+	"go.uber.org/zap"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/velocity-ci/velocity/backend/pkg/git"
@@ -17,17 +23,46 @@ type Task struct {
 	ID         string `json:"id"`
 	parameters map[string]*Parameter
 
-	Blueprint    config.Blueprint `json:"blueprint"`
-	IgnoreErrors bool             `json:"ignoreErrors"`
-	Docker       TaskDocker       `json:"docker"`
-	Steps        []Step           `json:"steps"`
+	Blueprint config.Blueprint `json:"blueprint"`
+	// Matrix is the combination of the Blueprint's matrix that this Task runs with
+	Matrix       map[string]string `json:"matrix"`
+	IgnoreErrors bool              `json:"ignoreErrors"`
+	// Needs are the IDs of Tasks that must succeed before this Task runs
+	Needs []string `json:"needs"`
+	// When is a condition evaluated against the resolved parameters after setup. The Task is skipped when it is false.
+	When   string     `json:"when"`
+	Docker TaskDocker `json:"docker"`
+	Steps  []Step     `json:"steps"`
+	// Artifacts are the files collected from the workspace by this Task
+	Artifacts []*Artifact `json:"artifacts"`
+	// From are the Tasks whose artifacts are restored into the workspace during setup
+	From []*ArtifactSource `json:"from"`
 
 	Status      string     `json:"status"`
 	StartedAt   *time.Time `json:"startedAt"`
 	UpdatedAt   *time.Time `json:"updatedAt"`
 	CompletedAt *time.Time `json:"completedAt"`
 
-	ProjectRoot string `json:"-"`
+	ProjectRoot   string        `json:"-"`
+	ArtifactStore ArtifactStore `json:"-"`
+	// project names the project in cache volume labels
+	project string
+	// privateKey is the project's deploy key, forwarded to BuildKit ssh mounts
+	privateKey string
+	// platformImages are the per-platform images built for each tag, pushed as manifest lists
+	platformImages map[string][]string
+	// root is the project configuration, read from the project root during setup unless given by the plan
+	root *config.Root
+	// rootParameters are the root configuration's parameters when they have been resolved once for the plan
+	rootParameters []*Parameter
+	// derivedCache holds the outputs of derived parameter binaries, shared by the Tasks of a plan
+	derivedCache *derivedParameterCache
+
+	mutex   sync.Mutex
+	stopped bool
+	// ctx is the parent of the steps' contexts, cancelled by Stop
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func (t *Task) UnmarshalJSON(b []byte) error {
@@ -111,6 +146,46 @@ func (t *Task) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	// Deserialize Needs
+	if objMap["needs"] != nil {
+		err = json.Unmarshal(*objMap["needs"], &t.Needs)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Deserialize When
+	if objMap["when"] != nil {
+		err = json.Unmarshal(*objMap["when"], &t.When)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Deserialize Artifacts
+	if objMap["artifacts"] != nil {
+		err = json.Unmarshal(*objMap["artifacts"], &t.Artifacts)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Deserialize From
+	if objMap["from"] != nil {
+		err = json.Unmarshal(*objMap["from"], &t.From)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Deserialize Matrix
+	if objMap["matrix"] != nil {
+		err = json.Unmarshal(*objMap["matrix"], &t.Matrix)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Deserialize IgnoreErrors
 	err = json.Unmarshal(*objMap["ignoreErrors"], &t.IgnoreErrors)
 	if err != nil {
@@ -123,23 +198,76 @@ func (t *Task) UnmarshalJSON(b []byte) error {
 func (t *Task) Execute(emitter Emitter) error {
 	taskWriter := emitter.GetTaskWriter(t)
 	defer taskWriter.Close()
+	t.Status = StateBuilding
 	taskWriter.SetStatus(StateBuilding)
-	fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSIInfo, "-> running task %s (%s)", "\n"), t.Blueprint.Name, t.ID)
+	fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSIInfo, "-> running task %s (%s)", "\n"), t.GetName(), t.ID)
 	totalSteps := len(t.Steps)
 	for i, step := range t.Steps {
+		if t.isStopped() {
+			t.Status = StateCancelled
+			taskWriter.SetStatus(StateCancelled)
+			fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSIWarn, "-> cancelled task %s (%s)", "\n"), t.GetName(), t.ID)
+			return fmt.Errorf("task %s stopped", t.GetName())
+		}
 		err := t.executeStep(i+1, totalSteps, emitter, step)
-		if err != nil { // TODO: add support for ignoring errors from specific steps in Blueprint
+		if err != nil && t.isStopped() {
+			t.Status = StateCancelled
+			taskWriter.SetStatus(StateCancelled)
+			fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSIWarn, "-> cancelled task %s (%s)", "\n"), t.GetName(), t.ID)
+			return err
+		}
+		if err != nil {
+			t.Status = StateFailed
 			taskWriter.SetStatus(StateFailed)
-			fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSIError, "-> error in task %s (%s)", "\n"), t.Blueprint.Name, t.ID)
+			fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSIError, "-> error in task %s (%s)", "\n"), t.GetName(), t.ID)
 			return err
 		}
+		if _, ok := step.(*Setup); ok && t.When != "" {
+			// parameters are only available once setup has resolved them
+			run, err := EvaluateCondition(t.When, t.parameters)
+			if err != nil {
+				t.Status = StateFailed
+				taskWriter.SetStatus(StateFailed)
+				fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSIError, "-> error in task %s (%s): %s", "\n"), t.GetName(), t.ID, err)
+				return err
+			}
+			if !run {
+				for _, skippedStep := range t.Steps[i+1:] {
+					skipStep(emitter, skippedStep)
+				}
+				t.Status = StateSkipped
+				taskWriter.SetStatus(StateSkipped)
+				fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSIWarn, "-> skipped task %s (%s): %s is false", "\n"), t.GetName(), t.ID, t.When)
+				return nil
+			}
+		}
 	}
+	if err := t.collectArtifacts("", t.Blueprint.Artifacts, taskWriter); err != nil {
+		t.Status = StateFailed
+		taskWriter.SetStatus(StateFailed)
+		fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSIError, "-> error collecting artifacts in task %s (%s): %s", "\n"), t.GetName(), t.ID, err)
+		return err
+	}
+	t.Status = StateSuccess
 	taskWriter.SetStatus(StateSuccess)
-	fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSISuccess, "-> successfully completed task %s (%s)", "\n"), t.Blueprint.Name, t.ID)
+	fmt.Fprintf(taskWriter, output.ColorFmt(output.ANSISuccess, "-> successfully completed task %s (%s)", "\n"), t.GetName(), t.ID)
 	return nil
 }
 
+func skipStep(emitter Emitter, step Step) {
+	stepWriter := emitter.GetStepWriter(step)
+	defer stepWriter.Close()
+	stepWriter.SetStatus(StateSkipped)
+	fmt.Fprintf(stepWriter, output.ColorFmt(output.ANSIWarn, "-> skipped step %s %s (%s)", "\n"), step.GetType(), step.GetDescription(), step.GetID())
+}
+
 func (t *Task) Stop() error {
+	t.mutex.Lock()
+	t.stopped = true
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.mutex.Unlock()
 	for _, step := range t.Steps {
 		err := step.Stop()
 		if err != nil {
@@ -149,14 +277,140 @@ func (t *Task) Stop() error {
 	return nil
 }
 
+// getContext returns the Task's context, which is cancelled when the Task is stopped
+func (t *Task) getContext() context.Context {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.ctx == nil {
+		t.ctx, t.cancel = context.WithCancel(context.Background())
+		if t.stopped {
+			t.cancel()
+		}
+	}
+	return t.ctx
+}
+
+// getDerivedParameterCache returns the cache of derived parameter outputs, which is the plan's when it has given one
+func (t *Task) getDerivedParameterCache() *derivedParameterCache {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.derivedCache == nil {
+		t.derivedCache = newDerivedParameterCache()
+	}
+	return t.derivedCache
+}
+
+// refreshExpiredParameters resolves the derived parameters that expire before the next step could finish using
+// them again
+func (t *Task) refreshExpiredParameters(writer io.Writer) error {
+	deadline := time.Now().Add(derivedParameterExpiryMargin)
+	sources := []*config.ParameterDerived{}
+	for _, name := range sortedParameterPointerKeys(t.parameters) {
+		if param := t.parameters[name]; param.expiresBefore(deadline) && !isDerivedIn(param.source, sources) {
+			sources = append(sources, param.source)
+		}
+	}
+
+	for _, source := range sources {
+		params, err := resolveConfigParameterDerived(t.getContext(), source, t.getBackupResolver(), writer, t.getDerivedParameterCache())
+		if err != nil {
+			return fmt.Errorf("could not refresh parameter %s: %s", source.Use, err)
+		}
+		for _, param := range params {
+			t.parameters[param.Name] = param
+		}
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> refreshed expired parameter %s", "\n"), source.Use)
+	}
+	return nil
+}
+
+func sortedParameterPointerKeys(params map[string]*Parameter) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func isDerivedIn(needle *config.ParameterDerived, haystack []*config.ParameterDerived) bool {
+	for _, p := range haystack {
+		if p == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// isStopped returns whether the Task has been stopped, or its context cancelled, e.g. because the Task that called its
+// Blueprint was stopped
+func (t *Task) isStopped() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.stopped || (t.ctx != nil && t.ctx.Err() != nil)
+}
+
 func (t *Task) executeStep(i, totalSteps int, emitter Emitter, step Step) error {
 	stepWriter := emitter.GetStepWriter(step)
 	defer stepWriter.Close()
 	fmt.Fprintf(stepWriter, output.ColorFmt(output.ANSIInfo, "-> running step %d/%d: %s %s (%s)", "\n"), i, totalSteps, step.GetType(), step.GetDescription(), step.GetID())
+	if err := t.refreshExpiredParameters(stepWriter); err != nil {
+		stepWriter.SetStatus(StateFailed)
+		fmt.Fprintf(stepWriter, output.ColorFmt(output.ANSIError, "-> error in step %s: %s", "\n"), step.GetID(), err)
+		return err
+	}
 	step.SetParams(t.parameters)
-	err := step.Execute(emitter, t)
+	if when := step.GetWhen(); when != "" {
+		run, err := EvaluateCondition(when, t.parameters)
+		if err != nil {
+			stepWriter.SetStatus(StateFailed)
+			fmt.Fprintf(stepWriter, output.ColorFmt(output.ANSIError, "-> error in step %s: %s", "\n"), step.GetID(), err)
+			return err
+		}
+		if !run {
+			stepWriter.SetStatus(StateSkipped)
+			fmt.Fprintf(stepWriter, output.ColorFmt(output.ANSIWarn, "-> skipped step %d/%d %s %s (%s): %s is false", "\n"), i, totalSteps, step.GetType(), step.GetDescription(), step.GetID(), when)
+			return nil
+		}
+	}
+	options := step.GetExecutionOptions()
+	backoff := options.Backoff
+	var err error
+	for attempt := uint(1); attempt <= options.Attempts; attempt++ {
+		if attempt > 1 {
+			fmt.Fprintf(stepWriter, output.ColorFmt(output.ANSIWarn, "-> retrying step %s in %s (attempt %d/%d): %s", "\n"), step.GetID(), backoff, attempt, options.Attempts, err)
+			if !t.wait(backoff) {
+				break
+			}
+			backoff *= 2
+		}
+		var abandoned bool
+		abandoned, err = executeStepWithTimeout(emitter, t, step, options.Timeout)
+		if abandoned {
+			// the step may still be running so it is neither retried nor are the next steps run alongside it
+			stepWriter.SetStatus(StateFailed)
+			fmt.Fprintf(stepWriter, output.ColorFmt(output.ANSIError, "-> error in step %s: %s", "\n"), step.GetID(), err)
+			return err
+		}
+		if err == nil || t.isStopped() {
+			break
+		}
+	}
+	if err == nil {
+		err = t.collectArtifacts(step.GetID(), step.GetArtifacts(), stepWriter)
+	}
+	if err != nil && t.isStopped() {
+		stepWriter.SetStatus(StateCancelled)
+		fmt.Fprintf(stepWriter, output.ColorFmt(output.ANSIWarn, "-> cancelled step %s", "\n"), step.GetID())
+		return err
+	}
 	if err != nil {
 		stepWriter.SetStatus(StateFailed)
+		if options.IgnoreErrors {
+			fmt.Fprintf(stepWriter, output.ColorFmt(output.ANSIWarn, "-> ignoring error in step %s: %s", "\n"), step.GetID(), err)
+			return nil
+		}
 		fmt.Fprintf(stepWriter, output.ColorFmt(output.ANSIError, "-> error in step %s", "\n"), step.GetID())
 		return err
 	}
@@ -165,18 +419,97 @@ func (t *Task) executeStep(i, totalSteps int, emitter Emitter, step Step) error
 	return nil
 }
 
+// StepStopGracePeriod is how long a timed out step has to finish once it has been stopped before it is abandoned
+var StepStopGracePeriod = 30 * time.Second
+
+// executeStepWithTimeout stops the step once it has been running for longer than the timeout. A step that does not
+// finish within the StepStopGracePeriod after being stopped is abandoned, leaving it running in the background.
+func executeStepWithTimeout(emitter Emitter, t *Task, step Step, timeout time.Duration) (abandoned bool, err error) {
+	if timeout <= 0 {
+		return false, step.Execute(emitter, t)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- step.Execute(emitter, t)
+	}()
+
+	select {
+	case err := <-done:
+		return false, err
+	case <-time.After(timeout):
+		if err := step.Stop(); err != nil {
+			logging.GetLogger().Error("could not stop timed out step", zap.String("step", step.GetID()), zap.Error(err))
+		}
+		// wait for the step to finish so that a retry does not run alongside it
+		select {
+		case <-done:
+			return false, fmt.Errorf("step %s timed out after %s", step.GetID(), timeout)
+		case <-time.After(StepStopGracePeriod):
+			logging.GetLogger().Error("abandoned timed out step", zap.String("step", step.GetID()))
+			return true, fmt.Errorf("step %s timed out after %s and did not stop within %s", step.GetID(), timeout, StepStopGracePeriod)
+		}
+	}
+}
+
+// wait sleeps for the given duration, returning false early if the Task is stopped.
+func (t *Task) wait(d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if t.isStopped() {
+			return false
+		}
+		time.Sleep(minDuration(100*time.Millisecond, time.Until(deadline)))
+	}
+	return !t.isStopped()
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func NewTask(
 	c *config.Blueprint,
+	blueprints []*config.Blueprint,
 	paramResolver BackupResolver,
 	repository *git.Repository,
 	branch string,
 	commitSha string,
 	projectRoot string,
-) *Task {
-	steps := []Step{
-		NewStepSetup(paramResolver, repository, branch, commitSha),
+) (*Task, error) {
+	steps, err := newStepsFromConfig(c.Steps, blueprints, projectRoot, []string{c.Name})
+	if err != nil {
+		return nil, err
 	}
-	for _, configStep := range c.Steps {
+
+	return &Task{
+		ID:          uuid.NewV4().String(),
+		Blueprint:   *c,
+		ProjectRoot: projectRoot,
+		Steps:       append([]Step{NewStepSetup(paramResolver, repository, branch, commitSha)}, steps...),
+		parameters:  map[string]*Parameter{},
+		Status:      StateWaiting,
+		Matrix:      map[string]string{},
+		Needs:       []string{},
+		Artifacts:   []*Artifact{},
+		From:        []*ArtifactSource{},
+		Docker:      taskDockerFromBlueprintDocker(c.Docker),
+	}, nil
+}
+
+// newStepsFromConfig converts configuration steps into runnable steps.
+// callStack holds the names of the Blueprints being called so recursion can be detected.
+func newStepsFromConfig(
+	configSteps []config.Step,
+	blueprints []*config.Blueprint,
+	projectRoot string,
+	callStack []string,
+) ([]Step, error) {
+	steps := []Step{}
+	for _, configStep := range configSteps {
 		switch x := configStep.(type) {
 		case *config.StepDockerRun:
 			steps = append(steps, NewStepDockerRun(x))
@@ -189,18 +522,20 @@ func NewTask(
 			break
 		case *config.StepDockerPush:
 			steps = append(steps, NewStepDockerPush(x))
+			break
+		case *config.StepDockerTag:
+			steps = append(steps, NewStepDockerTag(x))
+			break
+		case *config.StepBlueprint:
+			step, err := NewStepBlueprint(x, blueprints, projectRoot, callStack)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
 		}
 	}
 
-	return &Task{
-		ID:          uuid.NewV4().String(),
-		Blueprint:   *c,
-		ProjectRoot: projectRoot,
-		Steps:       steps,
-		parameters:  map[string]*Parameter{},
-		Status:      StateWaiting,
-		Docker:      taskDockerFromBlueprintDocker(c.Docker),
-	}
+	return steps, nil
 }
 
 func taskDockerFromBlueprintDocker(blueprint config.BlueprintDocker) TaskDocker {
@@ -209,12 +544,23 @@ func taskDockerFromBlueprintDocker(blueprint config.BlueprintDocker) TaskDocker
 		taskDocker.Registries = append(taskDocker.Registries, DockerRegistry{
 			Address:   dR.Address,
 			Use:       dR.Use,
+			Version:   dR.Version,
+			SHA256:    dR.SHA256,
 			Arguments: dR.Arguments,
 		})
 	}
 	return taskDocker
 }
 
+func (t *Task) getBackupResolver() BackupResolver {
+	if len(t.Steps) > 0 {
+		if setup, ok := t.Steps[0].(*Setup); ok {
+			return setup.backupResolver
+		}
+	}
+	return nil
+}
+
 func (t *Task) UpdateSetup(
 	backupResolver BackupResolver,
 	repository *git.Repository,
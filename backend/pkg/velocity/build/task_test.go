@@ -0,0 +1,179 @@
+package build_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/build"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/config"
+)
+
+type fakeStep struct {
+	build.BaseStep
+	failures int
+	attempts int
+	stop     chan struct{}
+}
+
+func newFakeStep(failures int) *fakeStep {
+	return &fakeStep{
+		BaseStep: build.BaseStep{ID: "fake", Type: "fake"},
+		failures: failures,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (s *fakeStep) Execute(emitter build.Emitter, t *build.Task) error {
+	s.attempts++
+	if s.failures < 0 {
+		// hangs until stopped
+		<-s.stop
+		return fmt.Errorf("stopped")
+	}
+	if s.attempts <= s.failures {
+		return fmt.Errorf("attempt %d failed", s.attempts)
+	}
+	return nil
+}
+
+func (s *fakeStep) Stop() error {
+	close(s.stop)
+	return nil
+}
+
+func (s *fakeStep) GetDetails() string                               { return "" }
+func (s *fakeStep) SetParams(map[string]*build.Parameter) error      { return nil }
+func (s *fakeStep) Validate(params map[string]build.Parameter) error { return nil }
+
+func TestTaskExecuteRetriesStep(t *testing.T) {
+	step := newFakeStep(2)
+	step.Retry = config.StepRetry{Attempts: 2, Backoff: config.Duration(time.Millisecond)}
+	task := &build.Task{Steps: []build.Step{step}}
+
+	err := task.Execute(build.NewBlankEmitter())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, step.attempts)
+	assert.Equal(t, build.StateSuccess, task.Status)
+}
+
+func TestTaskExecuteFailsAfterRetries(t *testing.T) {
+	step := newFakeStep(3)
+	step.Retry = config.StepRetry{Attempts: 1}
+	task := &build.Task{Steps: []build.Step{step}}
+
+	err := task.Execute(build.NewBlankEmitter())
+	assert.Error(t, err)
+	assert.Equal(t, 2, step.attempts)
+	assert.Equal(t, build.StateFailed, task.Status)
+}
+
+func TestTaskExecuteIgnoresStepErrors(t *testing.T) {
+	failing := newFakeStep(1)
+	failing.IgnoreErrors = true
+	next := newFakeStep(0)
+	task := &build.Task{Steps: []build.Step{failing, next}}
+
+	err := task.Execute(build.NewBlankEmitter())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, next.attempts)
+	assert.Equal(t, build.StateSuccess, task.Status)
+}
+
+func TestTaskExecuteStopsStepAfterTimeout(t *testing.T) {
+	step := newFakeStep(-1)
+	step.Timeout = config.Duration(10 * time.Millisecond)
+	task := &build.Task{Steps: []build.Step{step}}
+
+	err := task.Execute(build.NewBlankEmitter())
+	assert.EqualError(t, err, "step fake timed out after 10ms")
+	assert.Equal(t, 1, step.attempts)
+}
+
+// unstoppableStep hangs until it is released, ignoring Stop
+type unstoppableStep struct {
+	*fakeStep
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *unstoppableStep) Execute(emitter build.Emitter, t *build.Task) error {
+	s.started <- struct{}{}
+	<-s.release
+	return nil
+}
+
+func (s *unstoppableStep) Stop() error {
+	return nil
+}
+
+func TestTaskExecuteAbandonsStepThatDoesNotStop(t *testing.T) {
+	gracePeriod := build.StepStopGracePeriod
+	build.StepStopGracePeriod = 20 * time.Millisecond
+	defer func() { build.StepStopGracePeriod = gracePeriod }()
+
+	step := &unstoppableStep{fakeStep: newFakeStep(0), started: make(chan struct{}, 3), release: make(chan struct{})}
+	defer close(step.release)
+	step.Timeout = config.Duration(10 * time.Millisecond)
+	step.Retry = config.StepRetry{Attempts: 2}
+	step.IgnoreErrors = true
+	next := newFakeStep(0)
+	task := &build.Task{Steps: []build.Step{step, next}}
+
+	done := make(chan error)
+	go func() {
+		done <- task.Execute(build.NewBlankEmitter())
+	}()
+	select {
+	case err := <-done:
+		assert.EqualError(t, err, "step fake timed out after 10ms and did not stop within 20ms")
+	case <-time.After(5 * time.Second):
+		t.Fatal("task did not return after the grace period")
+	}
+	assert.Equal(t, build.StateFailed, task.Status)
+	assert.Len(t, step.started, 1)
+	assert.Equal(t, 0, next.attempts)
+}
+
+func TestTaskExecuteCancelledWhenStopped(t *testing.T) {
+	step := newFakeStep(-1)
+	task := &build.Task{Steps: []build.Step{step}}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		task.Stop()
+	}()
+
+	err := task.Execute(build.NewBlankEmitter())
+	assert.EqualError(t, err, "stopped")
+	assert.Equal(t, build.StateCancelled, task.Status)
+}
+
+func TestTaskExecuteStopsCalledBlueprintRetries(t *testing.T) {
+	step := newFakeStep(10)
+	step.Retry = config.StepRetry{Attempts: 10, Backoff: config.Duration(time.Second)}
+	task := &build.Task{Steps: []build.Step{
+		&build.StepBlueprint{
+			BaseStep: build.BaseStep{
+				ID:            "blueprint",
+				Type:          "blueprint",
+				OutputStreams: []*build.Stream{{ID: "blueprint", Name: "blueprint"}},
+			},
+			Name:  "retried",
+			Steps: []build.Step{step},
+		},
+	}}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		task.Stop()
+	}()
+
+	start := time.Now()
+	err := task.Execute(build.NewBlankEmitter())
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, 1, step.attempts)
+	assert.Equal(t, build.StateCancelled, task.Status)
+}
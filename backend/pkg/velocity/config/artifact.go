@@ -0,0 +1,49 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ArtifactPaths are glob patterns, relative to the project root, of files to collect as artifacts.
+// `**` matches any number of directories and a matched directory collects every file beneath it.
+type ArtifactPaths []string
+
+// UnmarshalJSON provides custom JSON decoding
+func (a *ArtifactPaths) UnmarshalJSON(b []byte) error {
+	var paths []string
+	err := json.Unmarshal(b, &paths)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		if err := validateArtifactPath(p); err != nil {
+			return err
+		}
+	}
+	*a = paths
+
+	return nil
+}
+
+func validateArtifactPath(p string) error {
+	if p == "" {
+		return fmt.Errorf("artifact path must not be empty")
+	}
+	if path.IsAbs(p) || strings.HasPrefix(p, "\\") {
+		return fmt.Errorf("artifact path %s must be relative to the project root", p)
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return fmt.Errorf("artifact path %s must not leave the project root", p)
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("artifact path %s is not a valid glob: %s", p, err)
+		}
+	}
+
+	return nil
+}
@@ -20,9 +20,17 @@ type Blueprint struct {
 	Docker      BlueprintDocker `json:"docker"`
 	Parameters  []Parameter     `json:"parameters"`
 	Steps       []Step          `json:"steps"`
+	// Artifacts are collected from the workspace after every step has succeeded
+	Artifacts ArtifactPaths `json:"artifacts"`
+	// Cache are volumes mounted into every run step that persist between builds
+	Cache []*BlueprintCache `json:"cache"`
+	// Matrix runs the Blueprint once for every combination of its values
+	Matrix BlueprintMatrix `json:"matrix"`
 
 	ParseErrors      []string `json:"parseErrors"`
 	ValidationErrors []string `json:"validationErrors"`
+	// ValidationWarnings are problems that do not stop the Blueprint from running, e.g. ignored compose file keys
+	ValidationWarnings []string `json:"validationWarnings"`
 }
 
 func newBlueprint() *Blueprint {
@@ -32,10 +40,14 @@ func newBlueprint() *Blueprint {
 		Docker: BlueprintDocker{
 			Registries: []BlueprintDockerRegistry{},
 		},
-		Parameters:       []Parameter{},
-		Steps:            []Step{},
-		ParseErrors:      []string{},
-		ValidationErrors: []string{},
+		Parameters:         []Parameter{},
+		Steps:              []Step{},
+		Artifacts:          ArtifactPaths{},
+		Cache:              []*BlueprintCache{},
+		Matrix:             BlueprintMatrix{},
+		ParseErrors:        []string{},
+		ValidationErrors:   []string{},
+		ValidationWarnings: []string{},
 	}
 }
 
@@ -76,6 +88,7 @@ func (t *Blueprint) UnmarshalJSON(b []byte) error {
 					t.Parameters = append(t.Parameters, param)
 				}
 			}
+			t.ValidationErrors = append(t.ValidationErrors, validateParameters(t.Parameters)...)
 		}
 	}
 
@@ -83,6 +96,33 @@ func (t *Blueprint) UnmarshalJSON(b []byte) error {
 	if _, ok := objMap["docker"]; ok {
 		err = json.Unmarshal(*objMap["docker"], &t.Docker)
 		t = handleBlueprintUnmarshalError(t, err)
+		if err == nil {
+			t.ValidationErrors = append(t.ValidationErrors, validateBlueprintDocker(t.Docker)...)
+		}
+	}
+
+	// Deserialize Artifacts
+	if val, _ := objMap["artifacts"]; val != nil {
+		err = json.Unmarshal(*val, &t.Artifacts)
+		t = handleBlueprintUnmarshalError(t, err)
+	}
+
+	// Deserialize Cache
+	if val, _ := objMap["cache"]; val != nil {
+		err = json.Unmarshal(*val, &t.Cache)
+		t = handleBlueprintUnmarshalError(t, err)
+		if err == nil {
+			t.ValidationErrors = append(t.ValidationErrors, validateBlueprintCaches(t.Cache)...)
+		}
+	}
+
+	// Deserialize Matrix
+	if val, _ := objMap["matrix"]; val != nil {
+		err = json.Unmarshal(*val, &t.Matrix)
+		t = handleBlueprintUnmarshalError(t, err)
+		if err == nil {
+			t.ValidationErrors = append(t.ValidationErrors, validateBlueprintMatrix(t.Matrix)...)
+		}
 	}
 
 	// Deserialize Steps by type
@@ -98,6 +138,16 @@ func (t *Blueprint) UnmarshalJSON(b []byte) error {
 					err = json.Unmarshal(*rawMessage, s)
 					t = handleBlueprintUnmarshalError(t, err)
 					if err == nil {
+						switch x := s.(type) {
+						case *StepDockerRun:
+							t.ValidationErrors = append(t.ValidationErrors, validateStepDockerRunServices(x.Services)...)
+						case *StepDockerBuild:
+							t.ValidationErrors = append(t.ValidationErrors, validateStepDockerBuild(x)...)
+						case *StepDockerPush:
+							t.ValidationErrors = append(t.ValidationErrors, validateStepDockerPush(x)...)
+						case *StepDockerTag:
+							t.ValidationErrors = append(t.ValidationErrors, validateStepDockerTag(x)...)
+						}
 						t.Steps = append(t.Steps, s)
 					}
 				}
@@ -148,10 +198,71 @@ func GetBlueprintsFromRoot(root *Root) ([]*Blueprint, error) {
 			if err != nil {
 				return err
 			}
+			for _, s := range t.Steps {
+				if x, ok := s.(*StepDockerCompose); ok {
+					t.ValidationWarnings = append(t.ValidationWarnings, validateStepDockerComposeFile(x, root.Path)...)
+				}
+			}
 			blueprints = append(blueprints, t)
 		}
 		return nil
 	})
 
+	validateBlueprintCalls(blueprints)
+
 	return blueprints, err
 }
+
+// validateBlueprintCalls records unknown and recursive blueprint steps in each Blueprint's ValidationErrors
+func validateBlueprintCalls(blueprints []*Blueprint) {
+	blueprintsByName := map[string]*Blueprint{}
+	for _, b := range blueprints {
+		blueprintsByName[b.Name] = b
+	}
+
+	var findRecursion func(path []string) []string
+	findRecursion = func(path []string) []string {
+		for _, s := range blueprintsByName[path[len(path)-1]].Steps {
+			step, ok := s.(*StepBlueprint)
+			if !ok {
+				continue
+			}
+			if _, ok := blueprintsByName[step.Name]; !ok {
+				continue
+			}
+			if step.Name == path[0] {
+				return append(path, step.Name)
+			}
+			if isIn(step.Name, path) {
+				// recursion that does not include this Blueprint is reported on the Blueprints involved
+				continue
+			}
+			if recursion := findRecursion(append(append([]string{}, path...), step.Name)); recursion != nil {
+				return recursion
+			}
+		}
+		return nil
+	}
+
+	for _, b := range blueprints {
+		for _, s := range b.Steps {
+			if step, ok := s.(*StepBlueprint); ok {
+				if _, ok := blueprintsByName[step.Name]; !ok {
+					b.ValidationErrors = append(b.ValidationErrors, fmt.Sprintf("blueprint step calls unknown blueprint %s", step.Name))
+				}
+			}
+		}
+		if recursion := findRecursion([]string{b.Name}); recursion != nil {
+			b.ValidationErrors = append(b.ValidationErrors, fmt.Sprintf("blueprint recursion: %s", strings.Join(recursion, " -> ")))
+		}
+	}
+}
+
+func isIn(needle string, haystack []string) bool {
+	for _, v := range haystack {
+		if needle == v {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/ghodss/yaml"
@@ -14,6 +17,7 @@ description: "Hello Velocity"
 
 parameters:
   - use: https://velocityci.io/parameter-test
+    sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
     arguments:
       name: /velocityci/foo
     exports:
@@ -24,6 +28,7 @@ parameters:
 docker:
   registries:
     - use: https://velocityci.io/registry-test
+      sha256: 60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752
       arguments:
         username: registry_user
         password: registry_password
@@ -39,6 +44,7 @@ docker:
 		&ParameterDerived{
 			BaseParameter: BaseParameter{Type: "derived"},
 			Use:           "https://velocityci.io/parameter-test",
+			SHA256:        "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
 			Arguments: map[string]string{
 				"name": "/velocityci/foo",
 			},
@@ -57,6 +63,7 @@ docker:
 			{
 				Address: "",
 				Use:     "https://velocityci.io/registry-test",
+				SHA256:  "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752",
 				Arguments: map[string]string{
 					"username": "registry_user",
 					"password": "registry_password",
@@ -67,3 +74,277 @@ docker:
 
 	assert.Equal(t, expectedBlueprintConfig, blueprintConfig)
 }
+
+func TestBlueprintUnmarshalInvalidPluginReferences(t *testing.T) {
+	blueprintConfigYaml := `
+---
+parameters:
+  - use: https://velocityci.io/parameter-test
+  - use: parameter.aws-ssm
+    version: ^1.x.2
+  - use: parameter.aws-ssm
+    version: ~0.1
+    sha256: abc
+
+docker:
+  registries:
+    - use: https://velocityci.io/registry-test
+      version: 1.0.0
+      sha256: 60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752
+    - use: registry.ecr
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), &blueprintConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"plugin https://velocityci.io/parameter-test has no sha256",
+		`plugin parameter.aws-ssm version: constraint "^1.x.2": version "1.x.2" is not a semantic version`,
+		`plugin parameter.aws-ssm sha256 "abc" is not a hex encoded sha256 checksum`,
+		"plugin https://velocityci.io/registry-test is a URL so cannot have a version",
+	}, blueprintConfig.ValidationErrors)
+}
+
+func TestValidateBlueprintCalls(t *testing.T) {
+	a := newBlueprint()
+	a.Name = "a"
+	a.Steps = []Step{&StepBlueprint{Name: "b"}}
+	b := newBlueprint()
+	b.Name = "b"
+	b.Steps = []Step{&StepBlueprint{Name: "a"}, &StepBlueprint{Name: "missing"}}
+	c := newBlueprint()
+	c.Name = "c"
+	c.Steps = []Step{&StepBlueprint{Name: "a"}}
+
+	validateBlueprintCalls([]*Blueprint{a, b, c})
+
+	assert.Equal(t, []string{"blueprint recursion: a -> b -> a"}, a.ValidationErrors)
+	assert.Equal(t, []string{
+		"blueprint step calls unknown blueprint missing",
+		"blueprint recursion: b -> a -> b",
+	}, b.ValidationErrors)
+	assert.Empty(t, c.ValidationErrors)
+}
+
+func TestBlueprintUnmarshalArtifacts(t *testing.T) {
+	blueprintConfigYaml := `
+---
+artifacts:
+  - dist/*
+  - ../secrets
+steps:
+  - type: run
+    image: golang:1.12
+    artifacts:
+      - reports/**/*.xml
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), blueprintConfig)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"artifact path ../secrets must not leave the project root"}, blueprintConfig.ParseErrors)
+	assert.Equal(t, ArtifactPaths{}, blueprintConfig.Artifacts)
+	assert.Equal(t, ArtifactPaths{"reports/**/*.xml"}, blueprintConfig.Steps[0].(*StepDockerRun).Artifacts)
+}
+
+func TestBlueprintUnmarshalCache(t *testing.T) {
+	blueprintConfigYaml := `
+---
+cache:
+  - name: go-modules
+    path: /go/pkg/mod
+    key: ${git.branch}
+    files: [go.sum]
+  - name: go modules
+    path: go/pkg/mod
+  - name: go-modules
+    path: /go/pkg/mod
+    files: [../go.sum]
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), blueprintConfig)
+	assert.Nil(t, err)
+	assert.Empty(t, blueprintConfig.ParseErrors)
+
+	assert.Equal(t, &BlueprintCache{
+		Name:  "go-modules",
+		Path:  "/go/pkg/mod",
+		Key:   "${git.branch}",
+		Files: []string{"go.sum"},
+	}, blueprintConfig.Cache[0])
+	assert.Equal(t, []string{
+		`cache name "go modules" must only contain letters, numbers, '_', '.' and '-'`,
+		`cache go modules path "go/pkg/mod" must be absolute`,
+		"cache go-modules is declared more than once",
+		"cache go-modules path /go/pkg/mod is used by another cache",
+		"cache go-modules: artifact path ../go.sum must not leave the project root",
+	}, blueprintConfig.ValidationErrors)
+}
+
+func TestBlueprintUnmarshalMatrix(t *testing.T) {
+	blueprintConfigYaml := `
+---
+matrix:
+  go: ["1.12", "1.13"]
+  postgres: [10, 11]
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), blueprintConfig)
+	assert.Nil(t, err)
+	assert.Empty(t, blueprintConfig.ParseErrors)
+	assert.Empty(t, blueprintConfig.ValidationErrors)
+
+	assert.Equal(t, BlueprintMatrix{
+		"go":       {"1.12", "1.13"},
+		"postgres": {"10", "11"},
+	}, blueprintConfig.Matrix)
+	assert.Equal(t, []map[string]string{
+		{"go": "1.12", "postgres": "10"},
+		{"go": "1.12", "postgres": "11"},
+		{"go": "1.13", "postgres": "10"},
+		{"go": "1.13", "postgres": "11"},
+	}, blueprintConfig.Matrix.GetCombinations())
+}
+
+func TestBlueprintUnmarshalInvalidMatrix(t *testing.T) {
+	blueprintConfigYaml := `
+---
+matrix:
+  go version: ["1.12"]
+  postgres: []
+  redis: [5, 5]
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), blueprintConfig)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		`matrix key "go version" must only contain letters, numbers, '_' and '-'`,
+		"matrix postgres must have at least one value",
+		"matrix redis value 5 is declared more than once",
+	}, blueprintConfig.ValidationErrors)
+}
+
+func TestBlueprintUnmarshalTypedParameters(t *testing.T) {
+	blueprintConfigYaml := `
+---
+parameters:
+  - name: environment
+    description: Environment to deploy to
+    kind: enum
+    default: staging
+    otherOptions: [production]
+  - name: replicas
+    kind: int
+    default: 3
+  - name: dry_run
+    kind: bool
+    default: false
+  - name: version
+    kind: regex
+    pattern: v[0-9]+\.[0-9]+\.[0-9]+
+  - name: regions
+    kind: list
+    default: [eu-west-1, us-east-1]
+  - name: comment
+    optional: true
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), blueprintConfig)
+	assert.Nil(t, err)
+	assert.Empty(t, blueprintConfig.ParseErrors)
+	assert.Empty(t, blueprintConfig.ValidationErrors)
+
+	assert.Equal(t, &ParameterBasic{
+		BaseParameter: BaseParameter{Type: "basic"},
+		Name:          "environment",
+		Description:   "Environment to deploy to",
+		Kind:          ParameterKindEnum,
+		Default:       "staging",
+		OtherOptions:  []string{"production"},
+	}, blueprintConfig.Parameters[0])
+	assert.Equal(t, "3", blueprintConfig.Parameters[1].(*ParameterBasic).Default)
+	assert.Equal(t, "false", blueprintConfig.Parameters[2].(*ParameterBasic).Default)
+	assert.Equal(t, "eu-west-1,us-east-1", blueprintConfig.Parameters[4].(*ParameterBasic).Default)
+
+	environment := blueprintConfig.Parameters[0].(*ParameterBasic)
+	assert.Nil(t, environment.ValidateValue("production"))
+	assert.EqualError(t, environment.ValidateValue("dev"), `parameter environment value "dev" must be one of staging, production`)
+
+	replicas := blueprintConfig.Parameters[1].(*ParameterBasic)
+	assert.EqualError(t, replicas.ValidateValue("three"), `parameter replicas value "three" is not an int`)
+
+	version := blueprintConfig.Parameters[3].(*ParameterBasic)
+	assert.True(t, version.IsRequired())
+	assert.Nil(t, version.ValidateValue("v1.2.3"))
+	assert.EqualError(t, version.ValidateValue("v1.2.3-rc1"), `parameter version value "v1.2.3-rc1" does not match v[0-9]+\.[0-9]+\.[0-9]+`)
+	assert.EqualError(t, version.ValidateValue(""), "parameter version is required")
+
+	regions := blueprintConfig.Parameters[4].(*ParameterBasic)
+	assert.EqualError(t, regions.ValidateValue("eu-west-1,,us-east-1"), `parameter regions value "eu-west-1,,us-east-1" has an empty item`)
+
+	comment := blueprintConfig.Parameters[5].(*ParameterBasic)
+	assert.Equal(t, ParameterKindString, comment.GetKind())
+	assert.Nil(t, comment.ValidateValue(""))
+}
+
+func TestBlueprintUnmarshalInvalidParameters(t *testing.T) {
+	blueprintConfigYaml := `
+---
+parameters:
+  - name: environment
+    kind: enum
+  - name: replicas
+    kind: int
+    default: three
+  - name: version
+    kind: regex
+    pattern: "v[0-9"
+  - name: tag
+    pattern: v.*
+  - name: size
+    kind: float
+  - name: replicas
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), blueprintConfig)
+	assert.Nil(t, err)
+	assert.Empty(t, blueprintConfig.ParseErrors)
+
+	assert.Equal(t, []string{
+		"enum parameter environment has no options",
+		`invalid default: parameter replicas value "three" is not an int`,
+		"parameter version pattern is invalid: error parsing regexp: missing closing ]: `[0-9`",
+		"parameter tag pattern requires kind regex",
+		"parameter size kind float must be one of string, int, bool, enum, regex, list",
+		"parameter replicas is declared more than once",
+	}, blueprintConfig.ValidationErrors)
+}
+
+func TestGetBlueprintsFromRootWarnsAboutUnsupportedComposeKeys(t *testing.T) {
+	projectRoot, err := ioutil.TempDir("", "vci-blueprints-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(projectRoot)
+	assert.Nil(t, os.MkdirAll(filepath.Join(projectRoot, ".velocityci", "blueprints"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(projectRoot, ".velocityci", "blueprints", "test.yml"), []byte(`
+steps:
+  - type: compose
+    composeFile: docker-compose.yml
+`), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(projectRoot, "docker-compose.yml"), []byte(`
+version: '3'
+services:
+  app:
+    image: app
+    cap_add: [NET_ADMIN]
+`), 0644))
+
+	root, err := GetRootConfigFromPath(projectRoot)
+	assert.Nil(t, err)
+	blueprints, err := GetBlueprintsFromRoot(root)
+	assert.Nil(t, err)
+	assert.Len(t, blueprints, 1)
+	assert.Empty(t, blueprints[0].ValidationErrors)
+	assert.Equal(t, []string{
+		"compose file docker-compose.yml: unsupported key services.app.cap_add is ignored",
+	}, blueprints[0].ValidationWarnings)
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// BlueprintCache is a named Docker volume that is kept between builds and mounted into a Blueprint's run steps
+type BlueprintCache struct {
+	Name string `json:"name"`
+	// Path is where the volume is mounted in the containers
+	Path string `json:"path"`
+	// Key is a template, e.g. ${git.branch}, that separates the volume from others with the same name
+	Key string `json:"key"`
+	// Files are globs, relative to the project root, of files whose contents are hashed into the key e.g. go.sum
+	Files []string `json:"files"`
+}
+
+var cacheNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func validateBlueprintCaches(caches []*BlueprintCache) (errs []string) {
+	names := map[string]bool{}
+	paths := map[string]bool{}
+	for _, c := range caches {
+		if !cacheNameRegex.MatchString(c.Name) {
+			errs = append(errs, fmt.Sprintf("cache name %q must only contain letters, numbers, '_', '.' and '-'", c.Name))
+		}
+		if names[c.Name] {
+			errs = append(errs, fmt.Sprintf("cache %s is declared more than once", c.Name))
+		}
+		names[c.Name] = true
+
+		if !path.IsAbs(c.Path) {
+			errs = append(errs, fmt.Sprintf("cache %s path %q must be absolute", c.Name, c.Path))
+		}
+		if paths[c.Path] {
+			errs = append(errs, fmt.Sprintf("cache %s path %s is used by another cache", c.Name, c.Path))
+		}
+		paths[c.Path] = true
+
+		for _, f := range c.Files {
+			if err := validateArtifactPath(f); err != nil {
+				errs = append(errs, fmt.Sprintf("cache %s: %s", c.Name, err))
+			}
+		}
+	}
+
+	return errs
+}
@@ -1,11 +1,29 @@
 package config
 
+import "github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
+
 type BlueprintDocker struct {
 	Registries []BlueprintDockerRegistry `json:"registries"`
 }
 
 type BlueprintDockerRegistry struct {
-	Address   string            `json:"address"`
-	Use       string            `json:"use"`
+	Address string `json:"address"`
+	Use     string `json:"use"`
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+
 	Arguments map[string]string `json:"arguments"`
 }
+
+// GetPluginReference returns the reference to the binary that authenticates with the registry
+func (r *BlueprintDockerRegistry) GetPluginReference() plugin.Reference {
+	return plugin.Reference{Use: r.Use, Version: r.Version, SHA256: r.SHA256}
+}
+
+func validateBlueprintDocker(d BlueprintDocker) (errs []string) {
+	for _, r := range d.Registries {
+		errs = append(errs, validatePluginReference(r.GetPluginReference())...)
+	}
+
+	return errs
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// BlueprintMatrix expands a Blueprint into a Task for every combination of its values.
+// Each combination is available as parameters e.g. ${matrix.go}.
+type BlueprintMatrix map[string][]string
+
+// UnmarshalJSON allows matrix values to be given as strings, numbers or booleans
+func (m *BlueprintMatrix) UnmarshalJSON(b []byte) error {
+	var rawMatrix map[string][]interface{}
+	err := json.Unmarshal(b, &rawMatrix)
+	if err != nil {
+		return err
+	}
+
+	matrix := BlueprintMatrix{}
+	for k, rawValues := range rawMatrix {
+		values := []string{}
+		for _, rawValue := range rawValues {
+			switch x := rawValue.(type) {
+			case string:
+				values = append(values, x)
+			case float64, bool:
+				values = append(values, fmt.Sprintf("%v", x))
+			default:
+				return fmt.Errorf("could not unmarshal matrix %s value type %T", k, x)
+			}
+		}
+		matrix[k] = values
+	}
+	*m = matrix
+
+	return nil
+}
+
+// GetKeys returns the matrix's keys in order
+func (m BlueprintMatrix) GetKeys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// GetCombinations returns every combination of the matrix's values, ordered by key and then the order of the values.
+// A Blueprint without a matrix has a single, empty combination.
+func (m BlueprintMatrix) GetCombinations() []map[string]string {
+	combinations := []map[string]string{{}}
+	for _, k := range m.GetKeys() {
+		expanded := []map[string]string{}
+		for _, c := range combinations {
+			for _, v := range m[k] {
+				combination := map[string]string{k: v}
+				for ck, cv := range c {
+					combination[ck] = cv
+				}
+				expanded = append(expanded, combination)
+			}
+		}
+		combinations = expanded
+	}
+
+	return combinations
+}
+
+var matrixKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+func validateBlueprintMatrix(m BlueprintMatrix) (errs []string) {
+	for _, k := range m.GetKeys() {
+		if !matrixKeyRegex.MatchString(k) {
+			errs = append(errs, fmt.Sprintf("matrix key %q must only contain letters, numbers, '_' and '-'", k))
+		}
+		if len(m[k]) < 1 {
+			errs = append(errs, fmt.Sprintf("matrix %s must have at least one value", k))
+		}
+		seen := map[string]bool{}
+		for _, v := range m[k] {
+			if seen[v] {
+				errs = append(errs, fmt.Sprintf("matrix %s value %s is declared more than once", k, v))
+			}
+			seen[v] = true
+		}
+	}
+
+	return errs
+}
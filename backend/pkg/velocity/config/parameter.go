@@ -1,6 +1,14 @@
 package config
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
+)
 
 type Parameter interface {
 }
@@ -9,6 +17,25 @@ type BaseParameter struct {
 	Type string `json:"type"`
 }
 
+// Basic parameter kinds
+const (
+	ParameterKindString = "string"
+	ParameterKindInt    = "int"
+	ParameterKindBool   = "bool"
+	ParameterKindEnum   = "enum"
+	ParameterKindRegex  = "regex"
+	ParameterKindList   = "list"
+)
+
+var parameterKinds = []string{
+	ParameterKindString,
+	ParameterKindInt,
+	ParameterKindBool,
+	ParameterKindEnum,
+	ParameterKindRegex,
+	ParameterKindList,
+}
+
 func NewParameterBasic() *ParameterBasic {
 	return &ParameterBasic{
 		BaseParameter: BaseParameter{
@@ -19,10 +46,190 @@ func NewParameterBasic() *ParameterBasic {
 
 type ParameterBasic struct {
 	BaseParameter
-	Name         string   `json:"name"`
-	Default      string   `json:"default"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Kind is the kind of value the parameter accepts. It defaults to enum when there are otherOptions, else string.
+	Kind    string `json:"kind"`
+	Default string `json:"default"`
+	// Optional parameters without a default resolve to an empty value instead of failing
+	Optional bool `json:"optional"`
+	// OtherOptions are the values an enum parameter accepts besides its default
 	OtherOptions []string `json:"otherOptions"`
-	Secret       bool     `json:"secret"`
+	// Pattern is the regular expression that the whole value of a regex parameter must match
+	Pattern string `json:"pattern"`
+	// Separator splits the value of a list parameter, defaulting to ","
+	Separator string `json:"separator"`
+	Secret    bool   `json:"secret"`
+}
+
+// UnmarshalJSON provides custom JSON decoding so that defaults can be written as numbers, booleans and lists
+func (p *ParameterBasic) UnmarshalJSON(b []byte) error {
+	type parameterBasic ParameterBasic
+	aux := struct {
+		*parameterBasic
+		Default interface{} `json:"default"`
+	}{parameterBasic: (*parameterBasic)(p)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	switch x := aux.Default.(type) {
+	case nil:
+	case string:
+		p.Default = x
+	case float64:
+		p.Default = strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		p.Default = strconv.FormatBool(x)
+	case []interface{}:
+		items := []string{}
+		for _, item := range x {
+			items = append(items, fmt.Sprintf("%v", item))
+		}
+		p.Default = strings.Join(items, p.GetSeparator())
+	default:
+		return fmt.Errorf("parameter %s default must be a string, number, bool or list", p.Name)
+	}
+
+	return nil
+}
+
+// GetKind returns the kind of value the parameter accepts
+func (p *ParameterBasic) GetKind() string {
+	if p.Kind != "" {
+		return p.Kind
+	}
+	if len(p.OtherOptions) > 0 {
+		return ParameterKindEnum
+	}
+	return ParameterKindString
+}
+
+// GetOptions returns the values an enum parameter accepts
+func (p *ParameterBasic) GetOptions() []string {
+	if p.Default == "" {
+		return p.OtherOptions
+	}
+	options := []string{p.Default}
+	for _, o := range p.OtherOptions {
+		if o != p.Default {
+			options = append(options, o)
+		}
+	}
+	return options
+}
+
+// GetSeparator returns the separator of a list parameter's items
+func (p *ParameterBasic) GetSeparator() string {
+	if p.Separator != "" {
+		return p.Separator
+	}
+	return ","
+}
+
+// IsRequired returns whether the parameter needs a value to be given as it has no default and isn't optional
+func (p *ParameterBasic) IsRequired() bool {
+	return p.Default == "" && !p.Optional
+}
+
+// ValidateValue returns an error if the value is not of the parameter's kind. Empty values of optional parameters
+// are valid.
+func (p *ParameterBasic) ValidateValue(value string) error {
+	if value == "" {
+		if p.IsRequired() {
+			return fmt.Errorf("parameter %s is required", p.Name)
+		}
+		return nil
+	}
+
+	switch p.GetKind() {
+	case ParameterKindInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("parameter %s value %q is not an int", p.Name, value)
+		}
+	case ParameterKindBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("parameter %s value %q is not a bool", p.Name, value)
+		}
+	case ParameterKindEnum:
+		for _, o := range p.GetOptions() {
+			if value == o {
+				return nil
+			}
+		}
+		return fmt.Errorf("parameter %s value %q must be one of %s", p.Name, value, strings.Join(p.GetOptions(), ", "))
+	case ParameterKindRegex:
+		r, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", p.Pattern))
+		if err != nil {
+			return fmt.Errorf("parameter %s pattern is invalid: %s", p.Name, err)
+		}
+		if !r.MatchString(value) {
+			return fmt.Errorf("parameter %s value %q does not match %s", p.Name, value, p.Pattern)
+		}
+	case ParameterKindList:
+		for _, item := range strings.Split(value, p.GetSeparator()) {
+			if strings.TrimSpace(item) == "" {
+				return fmt.Errorf("parameter %s value %q has an empty item", p.Name, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateParameterBasic(p *ParameterBasic) (errs []string) {
+	if p.Name == "" {
+		return []string{"parameter has no name"}
+	}
+
+	if !isIn(p.GetKind(), parameterKinds) {
+		errs = append(errs, fmt.Sprintf("parameter %s kind %s must be one of %s", p.Name, p.Kind, strings.Join(parameterKinds, ", ")))
+		return errs
+	}
+	if len(p.OtherOptions) > 0 && p.GetKind() != ParameterKindEnum {
+		errs = append(errs, fmt.Sprintf("parameter %s otherOptions require kind enum", p.Name))
+	}
+	if p.GetKind() == ParameterKindEnum && len(p.GetOptions()) < 1 {
+		errs = append(errs, fmt.Sprintf("enum parameter %s has no options", p.Name))
+	}
+	if p.Pattern != "" && p.GetKind() != ParameterKindRegex {
+		errs = append(errs, fmt.Sprintf("parameter %s pattern requires kind regex", p.Name))
+	}
+	if p.GetKind() == ParameterKindRegex {
+		if p.Pattern == "" {
+			errs = append(errs, fmt.Sprintf("regex parameter %s has no pattern", p.Name))
+		} else if _, err := regexp.Compile(p.Pattern); err != nil {
+			errs = append(errs, fmt.Sprintf("parameter %s pattern is invalid: %s", p.Name, err))
+		}
+	}
+	if p.Separator != "" && p.GetKind() != ParameterKindList {
+		errs = append(errs, fmt.Sprintf("parameter %s separator requires kind list", p.Name))
+	}
+	if len(errs) < 1 && p.Default != "" {
+		if err := p.ValidateValue(p.Default); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid default: %s", err))
+		}
+	}
+
+	return errs
+}
+
+func validateParameters(params []Parameter) (errs []string) {
+	names := map[string]bool{}
+	for _, param := range params {
+		switch p := param.(type) {
+		case *ParameterBasic:
+			errs = append(errs, validateParameterBasic(p)...)
+			if names[p.Name] {
+				errs = append(errs, fmt.Sprintf("parameter %s is declared more than once", p.Name))
+			}
+			names[p.Name] = true
+		case *ParameterDerived:
+			errs = append(errs, validatePluginReference(p.GetPluginReference())...)
+		}
+	}
+
+	return errs
 }
 
 func NewParameterDerived() *ParameterDerived {
@@ -35,11 +242,20 @@ func NewParameterDerived() *ParameterDerived {
 
 type ParameterDerived struct {
 	BaseParameter
-	Use       string            `json:"use"`
+	Use     string `json:"use"`
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+
 	Secret    bool              `json:"secret"`
 	Arguments map[string]string `json:"arguments"`
 	Exports   map[string]string `json:"exports"`
-	Timeout   uint64            `json:"timeout"`
+	// Timeout is how many seconds the binary may run for. 0 is no timeout.
+	Timeout uint64 `json:"timeout"`
+}
+
+// GetPluginReference returns the reference to the binary that derives the parameters
+func (p *ParameterDerived) GetPluginReference() plugin.Reference {
+	return plugin.Reference{Use: p.Use, Version: p.Version, SHA256: p.SHA256}
 }
 
 func unmarshalParameter(b []byte) (p Parameter, err error) {
@@ -13,9 +13,63 @@ import (
 	"go.uber.org/zap"
 )
 
+// PipelineBlueprint represents a Blueprint in a Pipeline and the Blueprints it needs to have succeeded before it runs.
+type PipelineBlueprint struct {
+	// ID identifies the Blueprint in the graph returned by GetBlueprintGraph, where needs and from refer to IDs
+	ID    string   `json:"-"`
+	Name  string   `json:"name"`
+	Needs []string `json:"needs"`
+	// When is a condition evaluated against the resolved parameters. The Blueprint is skipped when it is false.
+	When string `json:"when"`
+	// From are the Blueprints whose artifacts are restored into the workspace before the steps run.
+	// A Blueprint needs every Blueprint it takes artifacts from.
+	From []string `json:"from"`
+}
+
+// UnmarshalJSON allows a PipelineBlueprint to be given as just the Blueprint name
+func (b *PipelineBlueprint) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		b.Name = name
+		b.Needs = []string{}
+		b.From = []string{}
+		return nil
+	}
+
+	type pipelineBlueprint PipelineBlueprint
+	aux := struct {
+		*pipelineBlueprint
+		From interface{} `json:"from"`
+	}{pipelineBlueprint: &pipelineBlueprint{Needs: []string{}}}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*b = PipelineBlueprint(*aux.pipelineBlueprint)
+
+	// From can be a single Blueprint name or a list of them
+	b.From = []string{}
+	switch x := aux.From.(type) {
+	case string:
+		b.From = append(b.From, x)
+	case []interface{}:
+		for _, f := range x {
+			name, ok := f.(string)
+			if !ok {
+				return fmt.Errorf("could not unmarshal from %v", f)
+			}
+			b.From = append(b.From, name)
+		}
+	case nil:
+	default:
+		return fmt.Errorf("could not unmarshal from type %T", x)
+	}
+
+	return nil
+}
+
 type Stage struct {
-	Name       string   `json:"name"`
-	Blueprints []string `json:"blueprints"`
+	Name       string               `json:"name"`
+	Blueprints []*PipelineBlueprint `json:"blueprints"`
 }
 
 func (s *Stage) UnmarshalJSON(b []byte) error {
@@ -45,8 +99,12 @@ func (s *Stage) UnmarshalJSON(b []byte) error {
 type Pipeline struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	// Parallelism limits how many Tasks run at once. 0 means unlimited.
+	Parallelism int `json:"parallelism"`
 
-	Stages []*Stage `json:"stages"`
+	// Stages are sugar for Blueprints that need every Blueprint in the previous Stage
+	Stages     []*Stage             `json:"stages"`
+	Blueprints []*PipelineBlueprint `json:"blueprints"`
 
 	ParseErrors      []string `json:"parseErrors"`
 	ValidationErrors []string `json:"validationErrors"`
@@ -57,6 +115,7 @@ func newPipeline() *Pipeline {
 		Name:             "",
 		Description:      "",
 		Stages:           []*Stage{},
+		Blueprints:       []*PipelineBlueprint{},
 		ParseErrors:      []string{},
 		ValidationErrors: []string{},
 	}
@@ -85,6 +144,16 @@ func (t *Pipeline) UnmarshalJSON(b []byte) error {
 		t = handlePipelineUnmarshalError(t, err)
 	}
 
+	// Deserialize Parallelism
+	if _, ok := objMap["parallelism"]; ok {
+		err = json.Unmarshal(*objMap["parallelism"], &t.Parallelism)
+		t = handlePipelineUnmarshalError(t, err)
+		if t.Parallelism < 0 {
+			t.ValidationErrors = append(t.ValidationErrors, "parallelism must not be negative")
+			t.Parallelism = 0
+		}
+	}
+
 	// Default Pipeline
 	if t.Name == "default" {
 		t.Description = "The default pipeline"
@@ -110,9 +179,151 @@ func (t *Pipeline) UnmarshalJSON(b []byte) error {
 		}
 	}
 
+	// Deserialize Blueprints
+	if val, _ := objMap["blueprints"]; val != nil {
+		err = json.Unmarshal(*val, &t.Blueprints)
+		t = handlePipelineUnmarshalError(t, err)
+	}
+
+	t.ValidationErrors = append(t.ValidationErrors, validateBlueprintGraph(t.GetBlueprintGraph())...)
+
 	return nil
 }
 
+// GetBlueprintGraph returns every Blueprint in the Pipeline with the dependencies implied by its Stage added to its needs.
+// Blueprints are identified by their name, except for Blueprints that are listed in more than one place in the Stages,
+// which run once for every place and are identified by their name and position e.g. build (stage 1.2). Needs and from
+// that name such a Blueprint refer to every place it is listed.
+func (t *Pipeline) GetBlueprintGraph() []*PipelineBlueprint {
+	occurrences := map[string]int{}
+	for _, stage := range t.Stages {
+		for _, b := range stage.Blueprints {
+			occurrences[b.Name]++
+		}
+	}
+	for _, b := range t.Blueprints {
+		occurrences[b.Name]++
+	}
+
+	graph := []*PipelineBlueprint{}
+	for i, stage := range t.Stages {
+		for j, b := range stage.Blueprints {
+			id := b.Name
+			if occurrences[b.Name] > 1 {
+				id = fmt.Sprintf("%s (stage %d.%d)", b.Name, i+1, j+1)
+			}
+			graph = append(graph, &PipelineBlueprint{ID: id, Name: b.Name, When: b.When})
+		}
+	}
+	for _, b := range t.Blueprints {
+		graph = append(graph, &PipelineBlueprint{ID: b.Name, Name: b.Name, When: b.When})
+	}
+
+	// needs and from refer to Blueprint names, which are resolved to the IDs of every place the Blueprint is listed
+	ids := map[string][]string{}
+	for _, node := range graph {
+		ids[node.Name] = appendMissing(ids[node.Name], []string{node.ID})
+	}
+	getIDs := func(names []string) []string {
+		resolved := []string{}
+		for _, name := range names {
+			if nameIDs, ok := ids[name]; ok {
+				resolved = appendMissing(resolved, nameIDs)
+			} else {
+				resolved = appendMissing(resolved, []string{name})
+			}
+		}
+		return resolved
+	}
+
+	n := 0
+	previousStage := []string{}
+	for _, stage := range t.Stages {
+		currentStage := []string{}
+		for _, b := range stage.Blueprints {
+			node := graph[n]
+			node.Needs = appendMissing(append(append([]string{}, previousStage...), getIDs(b.Needs)...), getIDs(b.From))
+			node.From = getIDs(b.From)
+			currentStage = append(currentStage, node.ID)
+			n++
+		}
+		if len(currentStage) > 0 {
+			previousStage = currentStage
+		}
+	}
+	for _, b := range t.Blueprints {
+		node := graph[n]
+		node.Needs = appendMissing(getIDs(b.Needs), getIDs(b.From))
+		node.From = getIDs(b.From)
+		n++
+	}
+
+	return graph
+}
+
+func appendMissing(values []string, newValues []string) []string {
+	for _, v := range newValues {
+		if !isIn(v, values) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func validateBlueprintGraph(graph []*PipelineBlueprint) (errs []string) {
+	needs := map[string][]string{}
+	for _, b := range graph {
+		if _, ok := needs[b.ID]; ok {
+			errs = append(errs, fmt.Sprintf("blueprint %s is declared more than once", b.ID))
+			continue
+		}
+		needs[b.ID] = b.Needs
+	}
+
+	for _, b := range graph {
+		for _, n := range b.Needs {
+			if _, ok := needs[n]; !ok {
+				errs = append(errs, fmt.Sprintf("blueprint %s needs unknown blueprint %s", b.ID, n))
+			}
+		}
+	}
+
+	// depth-first search for cycles
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var visit func(path []string)
+	visit = func(path []string) {
+		name := path[len(path)-1]
+		state[name] = visiting
+		for _, n := range needs[name] {
+			switch state[n] {
+			case visiting:
+				for i, p := range path {
+					if p == n {
+						errs = append(errs, fmt.Sprintf("dependency cycle: %s", strings.Join(append(path[i:], n), " -> ")))
+					}
+				}
+			case unvisited:
+				if _, ok := needs[n]; ok {
+					visit(append(append([]string{}, path...), n))
+				}
+			}
+		}
+		state[name] = visited
+	}
+	for _, b := range graph {
+		if state[b.ID] == unvisited {
+			visit([]string{b.ID})
+		}
+	}
+
+	return errs
+}
+
 func findPipelinesDirectory(root *Root) (string, error) {
 	pipelinesDir := filepath.Join(root.Project.ConfigPath, "pipelines")
 
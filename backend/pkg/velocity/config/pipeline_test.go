@@ -0,0 +1,184 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineUnmarshal(t *testing.T) {
+	pipelineConfigYaml := `
+---
+description: Tests and publishes
+parallelism: 2
+stages:
+  - name: test
+    blueprints:
+      - lint
+      - unit
+  - blueprints:
+      - publish
+`
+	pipelineConfig := newPipeline()
+	err := yaml.Unmarshal([]byte(pipelineConfigYaml), pipelineConfig)
+	assert.Nil(t, err)
+
+	expectedPipelineConfig := newPipeline()
+	expectedPipelineConfig.Description = "Tests and publishes"
+	expectedPipelineConfig.Parallelism = 2
+	expectedPipelineConfig.Stages = []*Stage{
+		{
+			Name: "test",
+			Blueprints: []*PipelineBlueprint{
+				{Name: "lint", Needs: []string{}, From: []string{}},
+				{Name: "unit", Needs: []string{}, From: []string{}},
+			},
+		},
+		{
+			Name: "stage 1",
+			Blueprints: []*PipelineBlueprint{
+				{Name: "publish", Needs: []string{}, From: []string{}},
+			},
+		},
+	}
+
+	assert.Equal(t, expectedPipelineConfig, pipelineConfig)
+}
+
+func TestPipelineUnmarshalNegativeParallelism(t *testing.T) {
+	pipelineConfigYaml := `
+---
+parallelism: -1
+`
+	pipelineConfig := newPipeline()
+	err := yaml.Unmarshal([]byte(pipelineConfigYaml), pipelineConfig)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 0, pipelineConfig.Parallelism)
+	assert.Len(t, pipelineConfig.ValidationErrors, 1)
+}
+
+func TestPipelineUnmarshalNeeds(t *testing.T) {
+	pipelineConfigYaml := `
+---
+stages:
+  - name: build
+    blueprints:
+      - build-image
+blueprints:
+  - name: integration
+    needs: [build-image]
+  - name: publish
+    when: ${git.describe} =~ "^v"
+    needs:
+      - integration
+`
+	pipelineConfig := newPipeline()
+	err := yaml.Unmarshal([]byte(pipelineConfigYaml), pipelineConfig)
+	assert.Nil(t, err)
+	assert.Empty(t, pipelineConfig.ValidationErrors)
+
+	assert.Equal(t, []*PipelineBlueprint{
+		{Name: "integration", Needs: []string{"build-image"}, From: []string{}},
+		{Name: "publish", Needs: []string{"integration"}, When: `${git.describe} =~ "^v"`, From: []string{}},
+	}, pipelineConfig.Blueprints)
+}
+
+func TestPipelineGetBlueprintGraph(t *testing.T) {
+	pipelineConfigYaml := `
+---
+stages:
+  - blueprints:
+      - lint
+      - unit
+  - blueprints:
+      - name: docs
+      - name: publish
+        needs: [docs]
+`
+	pipelineConfig := newPipeline()
+	err := yaml.Unmarshal([]byte(pipelineConfigYaml), pipelineConfig)
+	assert.Nil(t, err)
+	assert.Empty(t, pipelineConfig.ValidationErrors)
+
+	assert.Equal(t, []*PipelineBlueprint{
+		{ID: "lint", Name: "lint", Needs: []string{}, From: []string{}},
+		{ID: "unit", Name: "unit", Needs: []string{}, From: []string{}},
+		{ID: "docs", Name: "docs", Needs: []string{"lint", "unit"}, From: []string{}},
+		{ID: "publish", Name: "publish", Needs: []string{"lint", "unit", "docs"}, From: []string{}},
+	}, pipelineConfig.GetBlueprintGraph())
+}
+
+func TestPipelineUnmarshalInvalidGraph(t *testing.T) {
+	pipelineConfigYaml := `
+---
+blueprints:
+  - name: a
+    needs: [c]
+  - name: b
+    needs: [a, missing]
+  - name: c
+    needs: [b]
+  - name: c
+`
+	pipelineConfig := newPipeline()
+	err := yaml.Unmarshal([]byte(pipelineConfigYaml), pipelineConfig)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"blueprint c is declared more than once",
+		"blueprint b needs unknown blueprint missing",
+		"dependency cycle: a -> c -> b -> a",
+	}, pipelineConfig.ValidationErrors)
+}
+
+func TestPipelineUnmarshalFrom(t *testing.T) {
+	pipelineConfigYaml := `
+---
+blueprints:
+  - build-binaries
+  - name: package
+    from: build-binaries
+  - name: publish
+    needs: [package]
+    from: [build-binaries, package]
+`
+	pipelineConfig := newPipeline()
+	err := yaml.Unmarshal([]byte(pipelineConfigYaml), pipelineConfig)
+	assert.Nil(t, err)
+	assert.Empty(t, pipelineConfig.ValidationErrors)
+
+	assert.Equal(t, []*PipelineBlueprint{
+		{ID: "build-binaries", Name: "build-binaries", Needs: []string{}, From: []string{}},
+		{ID: "package", Name: "package", Needs: []string{"build-binaries"}, From: []string{"build-binaries"}},
+		{ID: "publish", Name: "publish", Needs: []string{"package", "build-binaries"}, From: []string{"build-binaries", "package"}},
+	}, pipelineConfig.GetBlueprintGraph())
+}
+
+func TestPipelineGetBlueprintGraphRepeatedStageBlueprints(t *testing.T) {
+	pipelineConfigYaml := `
+---
+stages:
+  - blueprints:
+      - migrate
+  - blueprints:
+      - deploy
+  - blueprints:
+      - migrate
+blueprints:
+  - name: notify
+    needs: [migrate]
+`
+	pipelineConfig := newPipeline()
+	err := yaml.Unmarshal([]byte(pipelineConfigYaml), pipelineConfig)
+	assert.Nil(t, err)
+	assert.Empty(t, pipelineConfig.ValidationErrors)
+
+	assert.Equal(t, []*PipelineBlueprint{
+		{ID: "migrate (stage 1.1)", Name: "migrate", Needs: []string{}, From: []string{}},
+		{ID: "deploy", Name: "deploy", Needs: []string{"migrate (stage 1.1)"}, From: []string{}},
+		{ID: "migrate (stage 3.1)", Name: "migrate", Needs: []string{"deploy"}, From: []string{}},
+		{ID: "notify", Name: "notify", Needs: []string{"migrate (stage 1.1)", "migrate (stage 3.1)"}, From: []string{}},
+	}, pipelineConfig.GetBlueprintGraph())
+}
@@ -6,9 +6,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/ghodss/yaml"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
 	"go.uber.org/zap"
 )
 
@@ -19,6 +21,8 @@ type Root struct {
 
 	Parameters []Parameter   `json:"parameters"`
 	Plugins    []*RootPlugin `json:"plugins"`
+
+	ValidationErrors []string `json:"validationErrors"`
 }
 
 type RootProject struct {
@@ -31,12 +35,61 @@ type RootGit struct {
 	Submodule bool `json:"submodule"`
 }
 
+// RootPlugin is a binary that is run on the events of builds and their tasks. Plugins never fail a build.
 type RootPlugin struct {
-	Use       string            `json:"use"`
+	Use     string `json:"use"`
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+
 	Arguments map[string]string `json:"arguments"`
 	Events    []string          `json:"events"`
 }
 
+// GetPluginReference returns the reference to the plugin's binary
+func (p *RootPlugin) GetPluginReference() plugin.Reference {
+	return plugin.Reference{Use: p.Use, Version: p.Version, SHA256: p.SHA256}
+}
+
+// validatePluginReference validates a reference to a binary, which must be pinned by sha256 when given by URL
+func validatePluginReference(ref plugin.Reference) []string {
+	if err := ref.Validate(); err != nil {
+		return []string{err.Error()}
+	}
+	return []string{}
+}
+
+// pluginEvents are the events that plugins can be run on
+var pluginEvents = []string{
+	"BUILD_START",
+	"BUILD_COMPLETE",
+	"BUILD_SUCCESS",
+	"BUILD_FAIL",
+	"TASK_START",
+	"TASK_COMPLETE",
+	"TASK_SUCCESS",
+	"TASK_FAIL",
+}
+
+func validateRootPlugins(plugins []*RootPlugin) (errs []string) {
+	for _, p := range plugins {
+		if p.Use == "" {
+			errs = append(errs, "plugin has no use")
+			continue
+		}
+		errs = append(errs, validatePluginReference(p.GetPluginReference())...)
+		if len(p.Events) < 1 {
+			errs = append(errs, fmt.Sprintf("plugin %s has no events", p.Use))
+		}
+		for _, e := range p.Events {
+			if !isIn(e, pluginEvents) {
+				errs = append(errs, fmt.Sprintf("plugin %s event %s must be one of %s", p.Use, e, strings.Join(pluginEvents, ", ")))
+			}
+		}
+	}
+
+	return errs
+}
+
 func newRoot() *Root {
 	return &Root{
 		Project: &RootProject{
@@ -45,8 +98,9 @@ func newRoot() *Root {
 		Git: &RootGit{
 			Submodule: true,
 		},
-		Parameters: []Parameter{},
-		Plugins:    []*RootPlugin{},
+		Parameters:       []Parameter{},
+		Plugins:          []*RootPlugin{},
+		ValidationErrors: []string{},
 	}
 }
 
@@ -91,19 +145,22 @@ func (r *Root) UnmarshalJSON(b []byte) error {
 				r.Parameters = append(r.Parameters, param)
 			}
 		}
+		r.ValidationErrors = append(r.ValidationErrors, validateParameters(r.Parameters)...)
 	}
 
-	// Deserialize Git
+	// Deserialize Plugins
 	if _, ok := objMap["plugins"]; ok {
 		err = json.Unmarshal(*objMap["plugins"], &r.Plugins)
 		if err != nil {
 			return err
 		}
+		r.ValidationErrors = append(r.ValidationErrors, validateRootPlugins(r.Plugins)...)
 	}
 
 	return nil
 }
 
+// GetRootConfig returns the configuration of the project that the working directory is in
 func GetRootConfig() (*Root, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -114,6 +171,12 @@ func GetRootConfig() (*Root, error) {
 		return nil, err
 	}
 
+	return GetRootConfigFromPath(projectRoot)
+}
+
+// GetRootConfigFromPath returns the configuration of the project at the given root directory from its .velocity.yml.
+// Projects without one have the default configuration.
+func GetRootConfigFromPath(projectRoot string) (*Root, error) {
 	rootConfig := newRoot()
 	rootConfigPath := filepath.Join(projectRoot, ".velocity.yml")
 	if f, err := os.Stat(rootConfigPath); !os.IsNotExist(err) {
@@ -141,3 +141,30 @@ plugins:
 	}
 	assert.Equal(t, expectedRepositoryConfig, &repositoryConfig)
 }
+
+func TestRootUnmarshalValidationErrors(t *testing.T) {
+	repositoryConfigYaml := `
+---
+parameters:
+- name: environment
+  kind: enum
+
+plugins:
+- use: plugin-slack-bin-uri
+  events:
+  - BUILD_START
+  - STEP_START
+- arguments:
+    CHANNEL: ci
+`
+
+	var repositoryConfig config.Root
+	err := yaml.Unmarshal([]byte(repositoryConfigYaml), &repositoryConfig)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"enum parameter environment has no options",
+		"plugin plugin-slack-bin-uri event STEP_START must be one of BUILD_START, BUILD_COMPLETE, BUILD_SUCCESS, BUILD_FAIL, TASK_START, TASK_COMPLETE, TASK_SUCCESS, TASK_FAIL",
+		"plugin has no use",
+	}, repositoryConfig.ValidationErrors)
+}
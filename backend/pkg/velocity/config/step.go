@@ -3,6 +3,11 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	v3 "github.com/velocity-ci/velocity/backend/pkg/velocity/docker/compose/v3"
 )
@@ -12,14 +17,68 @@ type Step interface{}
 type BaseStep struct {
 	Type        string `json:"type"`
 	Description string `json:"description"`
+	// When is a condition evaluated against the resolved parameters. The step is skipped when it is false.
+	When string `json:"when"`
+
+	IgnoreErrors bool      `json:"ignoreErrors"`
+	Retry        StepRetry `json:"retry"`
+	// Timeout stops the step once it has been running for longer than the duration. 0 means no timeout.
+	Timeout Duration `json:"timeout"`
+	// Artifacts are collected from the workspace after the step succeeds
+	Artifacts ArtifactPaths `json:"artifacts"`
+}
+
+// StepRetry configures how many times a failing step is retried after its first attempt.
+// The Backoff is waited before the first retry and doubles for each retry after that.
+type StepRetry struct {
+	Attempts uint     `json:"attempts"`
+	Backoff  Duration `json:"backoff"`
+}
+
+// Duration is a time.Duration configured as a string such as "1m30s" or as a number of seconds
+type Duration time.Duration
+
+// UnmarshalJSON provides custom JSON decoding
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var i interface{}
+	err := json.Unmarshal(b, &i)
+	if err != nil {
+		return err
+	}
+
+	switch x := i.(type) {
+	case float64:
+		*d = Duration(time.Duration(x * float64(time.Second)))
+	case string:
+		duration, err := time.ParseDuration(x)
+		if err != nil {
+			return err
+		}
+		*d = Duration(duration)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("could not unmarshal duration type %T", x)
+	}
+	if *d < 0 {
+		return fmt.Errorf("duration must not be negative: %s", string(b))
+	}
+
+	return nil
+}
+
+// MarshalJSON provides custom JSON encoding
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
 }
 
 type StepSetup struct{}
 
+// StepBlueprint calls another Blueprint with the given parameters
 type StepBlueprint struct {
 	BaseStep
-	Name         string `json:"name"`
-	IgnoreErrors string `json:"ignoreErrors"`
+	Name       string            `json:"name"`
+	Parameters map[string]string `json:"parameters"`
 }
 
 type StepDockerRun struct {
@@ -30,16 +89,131 @@ type StepDockerRun struct {
 	WorkingDir     string                             `json:"workingDir"`
 	MountPoint     string                             `json:"mountPoint"`
 	IgnoreExitCode bool                               `json:"ignoreExitCode"`
+	// Services are started on the step's network before the main container and removed once it exits
+	Services []*StepDockerRunService `json:"services"`
+}
+
+// StepDockerRunService is a sidecar container for a run step, e.g. a database for integration tests
+type StepDockerRunService struct {
+	Name        string                             `json:"name"`
+	Image       string                             `json:"image"`
+	Command     v3.DockerComposeServiceCommand     `json:"command"`
+	Environment v3.DockerComposeServiceEnvironment `json:"environment"`
+	// Aliases are additional hostnames for the service on the step's network. The name is always one.
+	Aliases []string `json:"aliases"`
+	// Healthcheck, when given, must pass before the main container is started
+	Healthcheck *v3.DockerComposeServiceHealthcheck `json:"healthcheck"`
+}
+
+var serviceNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func validateStepDockerRunServices(services []*StepDockerRunService) (errs []string) {
+	names := map[string]bool{}
+	for _, s := range services {
+		if !serviceNameRegex.MatchString(s.Name) {
+			errs = append(errs, fmt.Sprintf("service name %q must only contain letters, numbers, '_', '.' and '-'", s.Name))
+		}
+		if s.Name == "run" {
+			errs = append(errs, "service name run is reserved for the step's container")
+		}
+		if names[s.Name] {
+			errs = append(errs, fmt.Sprintf("service %s is declared more than once", s.Name))
+		}
+		names[s.Name] = true
+		if s.Image == "" {
+			errs = append(errs, fmt.Sprintf("service %s has no image", s.Name))
+		}
+	}
+
+	return errs
 }
 
 type StepDockerPush struct {
 	BaseStep
 	Tags []string `json:"tags"`
+	// Sign signs the pushed digests
+	Sign *StepDockerPushSign `json:"sign"`
+	// SBOM generates an SBOM of each pushed digest and attaches it with the signer
+	SBOM bool `json:"sbom"`
+}
+
+type StepDockerPushSign struct {
+	// Signer is key (the default), which saves the signatures as artifacts in .velocityci/signatures, or cosign
+	Signer string `json:"signer"`
+	// Key is the private key file, absolute or relative to the project root, a secret parameter holding the PEM
+	// encoded private key e.g. ${signing-key} or, for cosign, a key reference e.g. awskms:///alias/signing
+	Key string `json:"key"`
+}
+
+func validateStepDockerPush(s *StepDockerPush) (errs []string) {
+	if s.Sign != nil {
+		switch s.Sign.Signer {
+		case "", "key", "cosign":
+		default:
+			errs = append(errs, fmt.Sprintf("unknown signer %s, must be key or cosign", s.Sign.Signer))
+		}
+		if s.Sign.Key == "" {
+			errs = append(errs, "push sign has no key")
+		}
+		if s.Sign.Signer != "cosign" && strings.Contains(s.Sign.Key, "://") {
+			errs = append(errs, fmt.Sprintf("push sign key %s is a key reference, which requires the cosign signer", s.Sign.Key))
+		}
+	}
+	if s.SBOM && s.Sign == nil {
+		errs = append(errs, "push sbom requires sign")
+	}
+
+	return errs
+}
+
+// StepDockerTag pulls an image and pushes it as the tags e.g. to promote it from a staging to a production registry
+type StepDockerTag struct {
+	BaseStep
+	Source string   `json:"source"`
+	Tags   []string `json:"tags"`
+}
+
+func validateStepDockerTag(s *StepDockerTag) (errs []string) {
+	if s.Source == "" {
+		errs = append(errs, "tag step has no source")
+	}
+	if len(s.Tags) < 1 {
+		errs = append(errs, "tag step has no tags")
+	}
+
+	return errs
 }
 
 type StepDockerCompose struct {
 	BaseStep
 	ComposeFile string `json:"composeFile"`
+	// StartTimeout is how long services wait for the services they depend on. 0 uses the default of 2 minutes.
+	StartTimeout Duration `json:"startTimeout"`
+	// ExitCodeFrom is the service whose exit code determines the step's result
+	ExitCodeFrom string `json:"exitCodeFrom"`
+	// AbortOnContainerExit stops all services once one exits. It defaults to true; when false the step waits for
+	// every service to exit.
+	AbortOnContainerExit *bool `json:"abortOnContainerExit"`
+	// AllMustSucceed requires every service to exit with 0
+	AllMustSucceed bool `json:"allMustSucceed"`
+}
+
+// validateStepDockerComposeFile warns about the keys of the step's compose file that are ignored. Compose files that
+// cannot be read are reported when the step runs as they may be created by earlier steps.
+func validateStepDockerComposeFile(s *StepDockerCompose, projectRoot string) (warnings []string) {
+	dockerComposeYml, err := ioutil.ReadFile(filepath.Join(projectRoot, s.ComposeFile))
+	if err != nil {
+		return warnings
+	}
+	keys, err := v3.GetUnsupportedKeys(dockerComposeYml)
+	if err != nil {
+		return warnings
+	}
+	for _, key := range keys {
+		warnings = append(warnings, fmt.Sprintf("compose file %s: unsupported key %s is ignored", s.ComposeFile, key))
+	}
+
+	return warnings
 }
 
 type StepDockerBuild struct {
@@ -47,6 +221,53 @@ type StepDockerBuild struct {
 	Dockerfile string   `json:"dockerfile"`
 	Context    string   `json:"context"`
 	Tags       []string `json:"tags"`
+	// BuildArgs are passed as --build-arg and may use parameters
+	BuildArgs v3.DockerComposeServiceEnvironment `json:"buildArgs"`
+	// Target is the stage of a multi-stage Dockerfile to build
+	Target string `json:"target"`
+	// CacheFrom are images, usually previously pushed tags, whose layers may be used as the build cache
+	CacheFrom []string                           `json:"cacheFrom"`
+	Labels    v3.DockerComposeServiceEnvironment `json:"labels"`
+	// Network is the network the RUN instructions use e.g. host
+	Network string `json:"network"`
+	NoCache bool   `json:"noCache"`
+	// Pull always attempts to pull newer versions of the base images. It defaults to true.
+	Pull *bool `json:"pull"`
+	// Builder is legacy (the default) or buildkit
+	Builder string `json:"builder"`
+	// Secrets are parameters mounted with RUN --mount=type=secret,id=<parameter name>. BuildKit only.
+	Secrets []string `json:"secrets"`
+	// SSH forwards the project's deploy key to RUN --mount=type=ssh. BuildKit only.
+	SSH bool `json:"ssh"`
+	// Platforms are the os/arch[/variant] platforms to build the image for e.g. linux/arm64. BuildKit only.
+	// Pushing the tags pushes a manifest list of the platforms' images.
+	Platforms []string `json:"platforms"`
+}
+
+var platformRegex = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9]+(/[a-z0-9]+)?$`)
+
+func validateStepDockerBuild(s *StepDockerBuild) (errs []string) {
+	switch s.Builder {
+	case "", "legacy", "buildkit":
+	default:
+		errs = append(errs, fmt.Sprintf("unknown builder %s, must be legacy or buildkit", s.Builder))
+	}
+	if s.Builder != "buildkit" && len(s.Secrets) > 0 {
+		errs = append(errs, "build secrets require the buildkit builder")
+	}
+	if s.Builder != "buildkit" && s.SSH {
+		errs = append(errs, "build ssh forwarding requires the buildkit builder")
+	}
+	if s.Builder != "buildkit" && len(s.Platforms) > 0 {
+		errs = append(errs, "build platforms require the buildkit builder")
+	}
+	for _, p := range s.Platforms {
+		if !platformRegex.MatchString(p) {
+			errs = append(errs, fmt.Sprintf("invalid build platform %s, must be os/arch[/variant]", p))
+		}
+	}
+
+	return errs
 }
 
 func unmarshalStep(rawMessage []byte) (Step, error) {
@@ -64,7 +285,8 @@ func unmarshalStep(rawMessage []byte) (Step, error) {
 			BaseStep: BaseStep{
 				Type: "run",
 			},
-			Command: []string{},
+			Command:  []string{},
+			Services: []*StepDockerRunService{},
 		}
 	case "build":
 		s = &StepDockerBuild{
@@ -84,6 +306,19 @@ func unmarshalStep(rawMessage []byte) (Step, error) {
 				Type: "push",
 			},
 		}
+	case "tag":
+		s = &StepDockerTag{
+			BaseStep: BaseStep{
+				Type: "tag",
+			},
+		}
+	case "blueprint":
+		s = &StepBlueprint{
+			BaseStep: BaseStep{
+				Type: "blueprint",
+			},
+			Parameters: map[string]string{},
+		}
 	}
 
 	if s == nil {
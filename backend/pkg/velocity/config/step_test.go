@@ -2,9 +2,11 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/stretchr/testify/assert"
+	v3 "github.com/velocity-ci/velocity/backend/pkg/velocity/docker/compose/v3"
 )
 
 func TestDockerBuildUnmarshal(t *testing.T) {
@@ -72,6 +74,80 @@ steps:
 	assert.EqualValues(t, expectedBlueprintConfig, blueprintConfig)
 }
 
+func TestDockerBuildOptionsUnmarshal(t *testing.T) {
+	blueprintConfigYaml := `
+---
+steps:
+  - type: build
+    tags: [app:latest]
+    buildArgs:
+      GO_VERSION: 1.12
+      NPM_TOKEN: ${npm.token}
+    target: production
+    cacheFrom: [app:latest]
+    labels:
+      - org.label-schema.vcs-ref=${git.commit.sha}
+    network: host
+    noCache: true
+    pull: false
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), &blueprintConfig)
+	assert.Nil(t, err)
+
+	pull := false
+	assert.Equal(t, []Step{
+		&StepDockerBuild{
+			BaseStep: BaseStep{
+				Type: "build",
+			},
+			Tags: []string{"app:latest"},
+			BuildArgs: v3.DockerComposeServiceEnvironment{
+				"GO_VERSION": "1.12",
+				"NPM_TOKEN":  "${npm.token}",
+			},
+			Target:    "production",
+			CacheFrom: []string{"app:latest"},
+			Labels: v3.DockerComposeServiceEnvironment{
+				"org.label-schema.vcs-ref": "${git.commit.sha}",
+			},
+			Network: "host",
+			NoCache: true,
+			Pull:    &pull,
+		},
+	}, blueprintConfig.Steps)
+}
+
+func TestDockerComposeExitCodeUnmarshal(t *testing.T) {
+	blueprintConfigYaml := `
+---
+steps:
+  - type: compose
+    composeFile: test.docker-compose.yml
+    startTimeout: 30s
+    exitCodeFrom: test
+    abortOnContainerExit: false
+    allMustSucceed: true
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), &blueprintConfig)
+	assert.Nil(t, err)
+
+	abortOnContainerExit := false
+	assert.Equal(t, []Step{
+		&StepDockerCompose{
+			BaseStep: BaseStep{
+				Type: "compose",
+			},
+			ComposeFile:          "test.docker-compose.yml",
+			StartTimeout:         Duration(30 * time.Second),
+			ExitCodeFrom:         "test",
+			AbortOnContainerExit: &abortOnContainerExit,
+			AllMustSucceed:       true,
+		},
+	}, blueprintConfig.Steps)
+}
+
 func TestDockerPushUnmarshal(t *testing.T) {
 	blueprintConfigYaml := `
 ---
@@ -79,6 +155,7 @@ description: Pushes a docker container
 steps:
   - type: push
     description: Docker push
+    when: ${git.branch} == master
     tags:
       - test/a:333
       - test/b:344
@@ -94,6 +171,7 @@ steps:
 			BaseStep: BaseStep{
 				Type:        "push",
 				Description: "Docker push",
+				When:        "${git.branch} == master",
 			},
 			Tags: []string{
 				"test/a:333",
@@ -146,6 +224,7 @@ steps:
 			WorkingDir:     "/app",
 			MountPoint:     "/app",
 			IgnoreExitCode: false,
+			Services:       []*StepDockerRunService{},
 		},
 		&StepDockerRun{
 			BaseStep: BaseStep{
@@ -157,8 +236,276 @@ steps:
 			Environment: map[string]string{
 				"HELLO": "WORLD",
 			},
+			Services: []*StepDockerRunService{},
 		},
 	}
 
 	assert.Equal(t, expectedBlueprintConfig, blueprintConfig)
 }
+
+func TestBlueprintStepUnmarshal(t *testing.T) {
+	blueprintConfigYaml := `
+---
+description: Runs the shared checks
+steps:
+  - type: blueprint
+    description: Lint
+    name: shared/lint
+    ignoreErrors: true
+    parameters:
+      path: ./backend
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), blueprintConfig)
+	assert.Nil(t, err)
+
+	expectedBlueprintConfig := newBlueprint()
+	expectedBlueprintConfig.Description = "Runs the shared checks"
+	expectedBlueprintConfig.Steps = []Step{
+		&StepBlueprint{
+			BaseStep: BaseStep{
+				Type:         "blueprint",
+				Description:  "Lint",
+				IgnoreErrors: true,
+			},
+			Name: "shared/lint",
+			Parameters: map[string]string{
+				"path": "./backend",
+			},
+		},
+	}
+
+	assert.Equal(t, expectedBlueprintConfig, blueprintConfig)
+}
+
+func TestStepExecutionOptionsUnmarshal(t *testing.T) {
+	blueprintConfigYaml := `
+---
+steps:
+  - type: run
+    image: golang:1.12
+    ignoreErrors: true
+    timeout: 10m
+    retry:
+      attempts: 2
+      backoff: 30
+  - type: run
+    image: golang:1.12
+    timeout: forever
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), blueprintConfig)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []Step{
+		&StepDockerRun{
+			BaseStep: BaseStep{
+				Type:         "run",
+				IgnoreErrors: true,
+				Timeout:      Duration(10 * time.Minute),
+				Retry: StepRetry{
+					Attempts: 2,
+					Backoff:  Duration(30 * time.Second),
+				},
+			},
+			Image:    "golang:1.12",
+			Command:  []string{},
+			Services: []*StepDockerRunService{},
+		},
+	}, blueprintConfig.Steps)
+	assert.Len(t, blueprintConfig.ParseErrors, 1)
+}
+
+func TestDockerRunServicesUnmarshal(t *testing.T) {
+	blueprintConfigYaml := `
+---
+steps:
+  - type: run
+    image: golang:1.12
+    services:
+      - name: postgres
+        image: postgres:11
+        environment:
+          POSTGRES_PASSWORD: velocity
+        aliases: [db]
+        healthcheck:
+          test: pg_isready -U postgres
+          interval: 2s
+          retries: 10
+      - name: run
+        image: redis:5
+      - name: run
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), blueprintConfig)
+	assert.Nil(t, err)
+	assert.Empty(t, blueprintConfig.ParseErrors)
+
+	step := blueprintConfig.Steps[0].(*StepDockerRun)
+	assert.Len(t, step.Services, 3)
+	assert.Equal(t, &StepDockerRunService{
+		Name:  "postgres",
+		Image: "postgres:11",
+		Environment: map[string]string{
+			"POSTGRES_PASSWORD": "velocity",
+		},
+		Aliases: []string{"db"},
+		Healthcheck: &v3.DockerComposeServiceHealthcheck{
+			Test:     []string{"CMD-SHELL", "pg_isready -U postgres"},
+			Interval: "2s",
+			Retries:  10,
+		},
+	}, step.Services[0])
+
+	assert.Equal(t, []string{
+		"service name run is reserved for the step's container",
+		"service name run is reserved for the step's container",
+		"service run is declared more than once",
+		"service run has no image",
+	}, blueprintConfig.ValidationErrors)
+}
+
+func TestDockerBuildKitUnmarshal(t *testing.T) {
+	blueprintConfigYaml := `
+---
+steps:
+  - type: build
+    tags: [app:latest]
+    builder: buildkit
+    secrets: [npm.token]
+    ssh: true
+  - type: build
+    tags: [app:latest]
+    secrets: [npm.token]
+    ssh: true
+  - type: build
+    tags: [app:latest]
+    builder: kaniko
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), &blueprintConfig)
+	assert.Nil(t, err)
+
+	step := blueprintConfig.Steps[0].(*StepDockerBuild)
+	assert.Equal(t, "buildkit", step.Builder)
+	assert.Equal(t, []string{"npm.token"}, step.Secrets)
+	assert.True(t, step.SSH)
+
+	assert.Equal(t, []string{
+		"build secrets require the buildkit builder",
+		"build ssh forwarding requires the buildkit builder",
+		"unknown builder kaniko, must be legacy or buildkit",
+	}, blueprintConfig.ValidationErrors)
+}
+
+func TestDockerBuildPlatformsUnmarshal(t *testing.T) {
+	blueprintConfigYaml := `
+---
+steps:
+  - type: build
+    tags: [app:latest]
+    builder: buildkit
+    platforms: [linux/amd64, linux/arm64, linux/arm/v7]
+  - type: build
+    tags: [app:latest]
+    platforms: [linux/amd64]
+  - type: build
+    tags: [app:latest]
+    builder: buildkit
+    platforms: [arm64]
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), &blueprintConfig)
+	assert.Nil(t, err)
+
+	step := blueprintConfig.Steps[0].(*StepDockerBuild)
+	assert.Equal(t, []string{"linux/amd64", "linux/arm64", "linux/arm/v7"}, step.Platforms)
+
+	assert.Equal(t, []string{
+		"build platforms require the buildkit builder",
+		"invalid build platform arm64, must be os/arch[/variant]",
+	}, blueprintConfig.ValidationErrors)
+}
+
+func TestDockerPushSignUnmarshal(t *testing.T) {
+	blueprintConfigYaml := `
+---
+steps:
+  - type: push
+    tags: [app:latest]
+    sign:
+      key: keys/signing.key
+    sbom: true
+  - type: push
+    tags: [app:latest]
+    sign:
+      signer: notary
+  - type: push
+    tags: [app:latest]
+    sbom: true
+  - type: push
+    tags: [app:latest]
+    sign:
+      key: awskms:///alias/signing
+  - type: push
+    tags: [app:latest]
+    sign:
+      signer: cosign
+      key: awskms:///alias/signing
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), &blueprintConfig)
+	assert.Nil(t, err)
+
+	assert.Equal(t, &StepDockerPush{
+		BaseStep: BaseStep{
+			Type: "push",
+		},
+		Tags: []string{"app:latest"},
+		Sign: &StepDockerPushSign{
+			Key: "keys/signing.key",
+		},
+		SBOM: true,
+	}, blueprintConfig.Steps[0])
+
+	assert.Equal(t, []string{
+		"unknown signer notary, must be key or cosign",
+		"push sign has no key",
+		"push sbom requires sign",
+		"push sign key awskms:///alias/signing is a key reference, which requires the cosign signer",
+	}, blueprintConfig.ValidationErrors)
+}
+
+func TestDockerTagUnmarshal(t *testing.T) {
+	blueprintConfigYaml := `
+---
+steps:
+  - type: tag
+    description: Promote to production
+    source: staging.example.com/app:${git.commit.sha}
+    tags:
+      - registry.example.com/app:${git.commit.sha}
+      - registry.example.com/app:latest
+  - type: tag
+`
+	blueprintConfig := newBlueprint()
+	err := yaml.Unmarshal([]byte(blueprintConfigYaml), &blueprintConfig)
+	assert.Nil(t, err)
+
+	assert.Equal(t, &StepDockerTag{
+		BaseStep: BaseStep{
+			Type:        "tag",
+			Description: "Promote to production",
+		},
+		Source: "staging.example.com/app:${git.commit.sha}",
+		Tags: []string{
+			"registry.example.com/app:${git.commit.sha}",
+			"registry.example.com/app:latest",
+		},
+	}, blueprintConfig.Steps[0])
+
+	assert.Equal(t, []string{
+		"tag step has no source",
+		"tag step has no tags",
+	}, blueprintConfig.ValidationErrors)
+}
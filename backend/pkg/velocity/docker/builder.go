@@ -18,31 +18,47 @@ import (
 
 // NewImageBuilder returns a new Docker image builder
 func NewImageBuilder() *ImageBuilder {
-	return &ImageBuilder{
-		running: false,
-	}
+	return &ImageBuilder{}
 }
 
-// ImageBuilder represents a stoppable Docker image builder
-type ImageBuilder struct {
-	running bool
+// ImageBuilder builds images with the legacy builder. Builds are interrupted by cancelling their context.
+type ImageBuilder struct{}
 
-	buildResp types.ImageBuildResponse
-}
+// BuildOptions are the optional parameters of an image build
+type BuildOptions struct {
+	// BuildArgs are passed as --build-arg. A nil value takes the variable from the environment.
+	BuildArgs map[string]*string
+	// Target is the stage of a multi-stage Dockerfile to build
+	Target string
+	// CacheFrom are images whose layers may be used as the build cache
+	CacheFrom []string
+	Labels    map[string]string
+	// NetworkMode is the network the RUN instructions use e.g. host
+	NetworkMode string
+	NoCache     bool
+	// Pull always attempts to pull newer versions of the base images
+	Pull bool
 
-// IsRunning returns whether or not the builder is running
-func (iB *ImageBuilder) IsRunning() bool {
-	return iB.running
+	// Secrets are exposed to RUN --mount=type=secret,id=<key> instructions. BuildKit only.
+	Secrets map[string]string
+	// SSH forwards SSHKey, or the running ssh-agent when it is empty, to RUN --mount=type=ssh instructions.
+	// BuildKit only.
+	SSH    bool
+	SSHKey string
+	// Platform is the os/arch[/variant] to build the image for. BuildKit only.
+	Platform string
 }
 
 // Build builds a Docker image with the given parameters
 func (iB *ImageBuilder) Build(
+	ctx context.Context,
 	writer io.Writer,
 	secrets []string,
 	buildContext string,
 	dockerfile string,
 	tags []string,
 	authConfigs map[string]types.AuthConfig,
+	options BuildOptions,
 ) error {
 	logging.GetLogger().Debug("building image",
 		zap.String("Dockerfile", dockerfile),
@@ -50,6 +66,13 @@ func (iB *ImageBuilder) Build(
 		zap.Strings("tags", tags),
 	)
 
+	if len(options.Secrets) > 0 || options.SSH {
+		return fmt.Errorf("secret and ssh mounts require the %s builder", BuilderBuildKit)
+	}
+	if options.Platform != "" {
+		return fmt.Errorf("platform builds require the %s builder", BuilderBuildKit)
+	}
+
 	excludes, err := readDockerignore(buildContext)
 	if err != nil {
 		return err
@@ -62,35 +85,58 @@ func (iB *ImageBuilder) Build(
 		return err
 	}
 
-	iB.buildResp, err = dockerClient.ImageBuild(context.Background(), buildCtx, types.ImageBuildOptions{
+	buildResp, err := dockerClient.ImageBuild(ctx, buildCtx, types.ImageBuildOptions{
 		AuthConfigs: authConfigs,
-		PullParent:  true,
+		PullParent:  options.Pull,
 		Remove:      true,
 		Dockerfile:  dockerfile,
 		Tags:        tags,
+		BuildArgs:   options.BuildArgs,
+		Target:      options.Target,
+		CacheFrom:   options.CacheFrom,
+		Labels:      options.Labels,
+		NetworkMode: options.NetworkMode,
+		NoCache:     options.NoCache,
 	})
 	if err != nil {
-		return err
+		return interruptedError(ctx, "image build", err)
 	}
-	iB.running = true
-	defer iB.buildResp.Body.Close()
-	HandleOutput(iB.buildResp.Body, secrets, writer)
-	if !iB.running {
-		return fmt.Errorf("image build interrupted")
+	HandleOutput(buildResp.Body, secrets, writer)
+	if ctx.Err() != nil {
+		return interruptedError(ctx, "image build", ctx.Err())
 	}
-	iB.Stop()
 	fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> built: %s", "\n"), strings.Join(tags, ", "))
 	logging.GetLogger().Debug("finished building image", zap.String("Dockerfile", dockerfile), zap.String("build context", buildContext))
 	return nil
 }
 
-// Stop interrupts the build process
-func (iB *ImageBuilder) Stop() error {
-	if iB.IsRunning() {
-		iB.buildResp.Body.Close()
-		iB.running = false
+// PullCacheImages pulls the images to use as the build cache as the builder only uses local images. Images that
+// cannot be pulled, e.g. before the first push, are skipped.
+func PullCacheImages(
+	ctx context.Context,
+	writer io.Writer,
+	secrets []string,
+	images []string,
+	addressAuthTokens map[string]string,
+) {
+	for _, image := range images {
+		if ctx.Err() != nil {
+			return
+		}
+		pullResp, err := dockerClient.ImagePull(
+			ctx,
+			image,
+			types.ImagePullOptions{
+				RegistryAuth: getAuthToken(image, addressAuthTokens),
+			},
+		)
+		if err != nil {
+			fmt.Fprintf(writer, output.ColorFmt(output.ANSIWarn, "-> could not pull cache image %s: %s", "\n"), image, err)
+			continue
+		}
+		HandleOutput(pullResp, secrets, writer)
+		fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> pulled cache image: %s", "\n"), image)
 	}
-	return nil
 }
 
 // From: https://github.com/docker/cli/blob/c202b4b98704876b0476a8fda073c5ffa14ff76d/cli/command/image/build/dockerignore.go
@@ -3,7 +3,9 @@
 package docker_test
 
 import (
+	"context"
 	"testing"
+	"time"
 	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
@@ -23,7 +25,7 @@ func TestImageBuilderBuild(t *testing.T) {
 	tags := []string{}
 	authConfigs := map[string]types.AuthConfig{}
 
-	err := builder.Build(writer, secrets, buildContext, dockerfile, tags, authConfigs)
+	err := builder.Build(context.Background(), writer, secrets, buildContext, dockerfile, tags, authConfigs, docker.BuildOptions{Pull: true})
 	assert.Nil(t, err)
 }
 
@@ -37,16 +39,12 @@ func TestImageBuilderBuildInterrupt(t *testing.T) {
 	tags := []string{}
 	authConfigs := map[string]types.AuthConfig{}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
-		for {
-			if builder.IsRunning() {
-				err := builder.Stop()
-				assert.Nil(t, err)
-				break
-			}
-		}
+		time.Sleep(2 * time.Second)
+		cancel()
 	}()
 
-	err := builder.Build(writer, secrets, buildContext, dockerfile, tags, authConfigs)
+	err := builder.Build(ctx, writer, secrets, buildContext, dockerfile, tags, authConfigs, docker.BuildOptions{Pull: true})
 	assert.Error(t, err)
 }
@@ -0,0 +1,223 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+	"go.uber.org/zap"
+)
+
+// Builder builds Docker images
+type Builder interface {
+	Build(
+		ctx context.Context,
+		writer io.Writer,
+		secrets []string,
+		buildContext string,
+		dockerfile string,
+		tags []string,
+		authConfigs map[string]types.AuthConfig,
+		options BuildOptions,
+	) error
+}
+
+// Builder backends
+const (
+	BuilderLegacy   = "legacy"
+	BuilderBuildKit = "buildkit"
+)
+
+// NewBuilder returns the builder for the given backend, defaulting to the legacy builder
+func NewBuilder(backend string) Builder {
+	if backend == BuilderBuildKit {
+		return NewBuildKitBuilder()
+	}
+	return NewImageBuilder()
+}
+
+// NewBuildKitBuilder returns a new BuildKit image builder
+func NewBuildKitBuilder() *BuildKitBuilder {
+	return &BuildKitBuilder{}
+}
+
+// BuildKitBuilder builds images with BuildKit through the docker CLI, which provides the session that secret and
+// ssh mounts need. Builds are interrupted by cancelling their context.
+type BuildKitBuilder struct{}
+
+// Build builds a Docker image with BuildKit
+func (bB *BuildKitBuilder) Build(
+	ctx context.Context,
+	writer io.Writer,
+	secrets []string,
+	buildContext string,
+	dockerfile string,
+	tags []string,
+	authConfigs map[string]types.AuthConfig,
+	options BuildOptions,
+) error {
+	logging.GetLogger().Debug("building image with buildkit",
+		zap.String("Dockerfile", dockerfile),
+		zap.String("build context", buildContext),
+		zap.Strings("tags", tags),
+	)
+
+	// secrets, the ssh key and registry credentials are only written to this directory, never to the arguments
+	tmpDir, err := ioutil.TempDir("", "vci-buildkit-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := writeDockerConfig(tmpDir, authConfigs); err != nil {
+		return err
+	}
+	args, err := getBuildKitArgs(tmpDir, buildContext, dockerfile, tags, options)
+	if err != nil {
+		return err
+	}
+
+	env := []string{"DOCKER_BUILDKIT=1", fmt.Sprintf("DOCKER_CONFIG=%s", tmpDir)}
+	if err := runCommand(ctx, writer, secrets, env, append([]string{"docker"}, args...)); err != nil {
+		return interruptedError(ctx, "image build", fmt.Errorf("image build failed: %s", err))
+	}
+
+	fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> built: %s", "\n"), strings.Join(tags, ", "))
+	logging.GetLogger().Debug("finished building image with buildkit", zap.String("Dockerfile", dockerfile), zap.String("build context", buildContext))
+	return nil
+}
+
+func getBuildKitArgs(tmpDir, buildContext, dockerfile string, tags []string, options BuildOptions) ([]string, error) {
+	args := []string{"build", "--progress=plain", "-f", filepath.Join(buildContext, dockerfile)}
+	for _, t := range tags {
+		args = append(args, "-t", t)
+	}
+
+	// embed cache metadata so that pushed images can be used with cacheFrom
+	args = append(args, "--build-arg", "BUILDKIT_INLINE_CACHE=1")
+	for _, k := range sortedKeys(options.BuildArgs) {
+		if v := options.BuildArgs[k]; v != nil {
+			args = append(args, "--build-arg", fmt.Sprintf("%s=%s", k, *v))
+		} else {
+			args = append(args, "--build-arg", k)
+		}
+	}
+	if options.Target != "" {
+		args = append(args, "--target", options.Target)
+	}
+	for _, c := range options.CacheFrom {
+		args = append(args, "--cache-from", c)
+	}
+	labelKeys := []string{}
+	for k := range options.Labels {
+		labelKeys = append(labelKeys, k)
+	}
+	sort.Strings(labelKeys)
+	for _, k := range labelKeys {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", k, options.Labels[k]))
+	}
+	if options.NetworkMode != "" {
+		args = append(args, "--network", options.NetworkMode)
+	}
+	if options.Platform != "" {
+		args = append(args, "--platform", options.Platform)
+	}
+	if options.NoCache {
+		args = append(args, "--no-cache")
+	}
+	if options.Pull {
+		args = append(args, "--pull")
+	}
+
+	secretIDs := []string{}
+	for id := range options.Secrets {
+		secretIDs = append(secretIDs, id)
+	}
+	sort.Strings(secretIDs)
+	for i, id := range secretIDs {
+		src := filepath.Join(tmpDir, fmt.Sprintf("secret-%d", i))
+		if err := ioutil.WriteFile(src, []byte(options.Secrets[id]), 0600); err != nil {
+			return nil, err
+		}
+		args = append(args, "--secret", fmt.Sprintf("id=%s,src=%s", id, src))
+	}
+
+	if options.SSH {
+		if options.SSHKey != "" {
+			src := filepath.Join(tmpDir, "ssh-key")
+			if err := ioutil.WriteFile(src, []byte(options.SSHKey), 0600); err != nil {
+				return nil, err
+			}
+			args = append(args, "--ssh", fmt.Sprintf("default=%s", src))
+		} else if os.Getenv("SSH_AUTH_SOCK") != "" {
+			args = append(args, "--ssh", "default")
+		} else {
+			return nil, fmt.Errorf("ssh forwarding requires a deploy key or a running ssh-agent")
+		}
+	}
+
+	return append(args, buildContext), nil
+}
+
+// writeDockerConfig writes the registry credentials into a docker CLI config.json in the given directory
+func writeDockerConfig(dir string, authConfigs map[string]types.AuthConfig) error {
+	type auth struct {
+		Auth string `json:"auth"`
+	}
+	config := struct {
+		Auths map[string]auth `json:"auths"`
+	}{Auths: map[string]auth{}}
+	for address, a := range authConfigs {
+		config.Auths[address] = auth{
+			Auth: base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", a.Username, a.Password))),
+		}
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, "config.json"), b, 0600)
+}
+
+func sortedKeys(m map[string]*string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runCommand runs the command and writes its censored output to the writer. Cancelling the context kills it.
+func runCommand(ctx context.Context, writer io.Writer, secrets []string, env []string, command []string) error {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	r, w := io.Pipe()
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		HandleBuildKitOutput(r, secrets, writer)
+		close(done)
+	}()
+	err := cmd.Wait()
+	w.Close()
+	<-done
+
+	return err
+}
@@ -4,12 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/ghodss/yaml"
 )
 
 type DockerComposeYaml struct {
 	Version  string                          `json:"version"`
 	Services map[string]DockerComposeService `json:"services"`
+	Volumes  map[string]*DockerComposeVolume `json:"volumes"`
+}
+
+// DockerComposeVolume is a named volume that services can mount. External volumes must already exist.
+type DockerComposeVolume struct {
+	External bool   `json:"external" yaml:"external"`
+	Name     string `json:"name" yaml:"name"`
 }
 
 // func (y *DockerComposeYaml) UnmarshalJSON(b []byte) error {
@@ -72,15 +83,18 @@ func (env *DockerComposeServiceEnvironment) UnmarshalJSON(b []byte) error {
 	switch x := i.(type) {
 	case []interface{}:
 		for _, e := range x {
-			parts := strings.Split(e.(string), "=")
+			parts := strings.SplitN(fmt.Sprint(e), "=", 2)
 			key := parts[0]
-			val := parts[1]
+			val := ""
+			if len(parts) > 1 {
+				val = parts[1]
+			}
 			environment[key] = val
 		}
 		break
 	case map[string]interface{}:
 		for k, v := range x {
-			environment[k] = v.(string)
+			environment[k] = scalarString(v)
 		}
 		break
 	default:
@@ -97,8 +111,10 @@ type DockerComposeServiceNetwork struct {
 }
 
 type DockerComposeServiceBuild struct {
-	Context    string `json:"context" yaml:"context"`
-	Dockerfile string `json:"dockerfile" yaml:"dockerfile"`
+	Context    string                        `json:"context" yaml:"context"`
+	Dockerfile string                        `json:"dockerfile" yaml:"dockerfile"`
+	Args       DockerComposeServiceBuildArgs `json:"args" yaml:"args"`
+	Target     string                        `json:"target" yaml:"target"`
 }
 
 func (c *DockerComposeServiceBuild) UnmarshalJSON(b []byte) error {
@@ -114,8 +130,15 @@ func (c *DockerComposeServiceBuild) UnmarshalJSON(b []byte) error {
 		c.Dockerfile = "Dockerfile"
 		break
 	case map[string]interface{}:
-		c.Context = x["context"].(string)
-		c.Dockerfile = x["dockerfile"].(string)
+		type build DockerComposeServiceBuild
+		var b2 build
+		if err := json.Unmarshal(b, &b2); err != nil {
+			return err
+		}
+		*c = DockerComposeServiceBuild(b2)
+		if c.Context != "" && c.Dockerfile == "" {
+			c.Dockerfile = "Dockerfile"
+		}
 		break
 	default:
 		return fmt.Errorf("could not unmarshal build type %T", x)
@@ -124,16 +147,281 @@ func (c *DockerComposeServiceBuild) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// DockerComposeServiceBuildArgs are the build arguments of a service. An argument without a value is taken from the
+// environment.
+type DockerComposeServiceBuildArgs map[string]*string
+
+func (a *DockerComposeServiceBuildArgs) UnmarshalJSON(b []byte) error {
+	var i interface{}
+	err := json.Unmarshal(b, &i)
+	if err != nil {
+		return err
+	}
+
+	args := DockerComposeServiceBuildArgs{}
+	switch x := i.(type) {
+	case []interface{}:
+		for _, e := range x {
+			parts := strings.SplitN(fmt.Sprint(e), "=", 2)
+			if len(parts) > 1 {
+				args[parts[0]] = &parts[1]
+			} else {
+				args[parts[0]] = nil
+			}
+		}
+		break
+	case map[string]interface{}:
+		for k, v := range x {
+			if v == nil {
+				args[k] = nil
+				continue
+			}
+			val := scalarString(v)
+			args[k] = &val
+		}
+		break
+	default:
+		return fmt.Errorf("could not unmarshal build args type %T", x)
+	}
+
+	*a = args
+
+	return nil
+}
+
+// DockerComposeStringList is a list that may also be given as a single string
+type DockerComposeStringList []string
+
+func (l *DockerComposeStringList) UnmarshalJSON(b []byte) error {
+	var i interface{}
+	err := json.Unmarshal(b, &i)
+	if err != nil {
+		return err
+	}
+
+	list := DockerComposeStringList{}
+	switch x := i.(type) {
+	case []interface{}:
+		for _, e := range x {
+			list = append(list, scalarString(e))
+		}
+		break
+	case string:
+		list = append(list, x)
+		break
+	default:
+		return fmt.Errorf("could not unmarshal list type %T", x)
+	}
+
+	*l = list
+
+	return nil
+}
+
+// DockerComposeServicePorts are published ports in the short "[ip:]published:target[/protocol]" syntax
+type DockerComposeServicePorts []string
+
+func (p *DockerComposeServicePorts) UnmarshalJSON(b []byte) error {
+	var i []interface{}
+	err := json.Unmarshal(b, &i)
+	if err != nil {
+		return err
+	}
+
+	ports := DockerComposeServicePorts{}
+	for _, x := range i {
+		switch port := x.(type) {
+		case map[string]interface{}:
+			// long syntax
+			spec := scalarString(port["target"])
+			if published, ok := port["published"]; ok {
+				spec = fmt.Sprintf("%s:%s", scalarString(published), spec)
+			}
+			if protocol, ok := port["protocol"]; ok {
+				spec = fmt.Sprintf("%s/%s", spec, scalarString(protocol))
+			}
+			ports = append(ports, spec)
+			break
+		case string, float64:
+			ports = append(ports, scalarString(port))
+			break
+		default:
+			return fmt.Errorf("could not unmarshal port type %T", port)
+		}
+	}
+
+	*p = ports
+
+	return nil
+}
+
+// DockerComposeServiceExtraHosts are "hostname:ip" mappings added to a service's /etc/hosts
+type DockerComposeServiceExtraHosts []string
+
+func (h *DockerComposeServiceExtraHosts) UnmarshalJSON(b []byte) error {
+	var i interface{}
+	err := json.Unmarshal(b, &i)
+	if err != nil {
+		return err
+	}
+
+	hosts := DockerComposeServiceExtraHosts{}
+	switch x := i.(type) {
+	case []interface{}:
+		for _, e := range x {
+			hosts = append(hosts, strings.Replace(scalarString(e), "=", ":", 1))
+		}
+		break
+	case map[string]interface{}:
+		for k, v := range x {
+			hosts = append(hosts, fmt.Sprintf("%s:%s", k, scalarString(v)))
+		}
+		sort.Strings(hosts)
+		break
+	default:
+		return fmt.Errorf("could not unmarshal extra_hosts type %T", x)
+	}
+
+	*h = hosts
+
+	return nil
+}
+
+func scalarString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// DockerComposeServiceHealthcheck configures how Docker determines whether a container is healthy
+type DockerComposeServiceHealthcheck struct {
+	Test        DockerComposeServiceHealthcheckTest `json:"test" yaml:"test"`
+	Interval    string                              `json:"interval" yaml:"interval"`
+	Timeout     string                              `json:"timeout" yaml:"timeout"`
+	StartPeriod string                              `json:"start_period" yaml:"start_period"`
+	Retries     int                                 `json:"retries" yaml:"retries"`
+	Disable     bool                                `json:"disable" yaml:"disable"`
+}
+
+// DockerComposeServiceHealthcheckTest is the healthcheck command. A string is run with the container's shell.
+type DockerComposeServiceHealthcheckTest []string
+
+func (t *DockerComposeServiceHealthcheckTest) UnmarshalJSON(b []byte) error {
+	var i interface{}
+	err := json.Unmarshal(b, &i)
+	if err != nil {
+		return err
+	}
+
+	test := DockerComposeServiceHealthcheckTest{}
+	switch x := i.(type) {
+	case []interface{}:
+		for _, p := range x {
+			s, ok := p.(string)
+			if !ok {
+				return fmt.Errorf("could not unmarshal healthcheck test part type %T", p)
+			}
+			test = append(test, s)
+		}
+		break
+	case string:
+		test = append(test, "CMD-SHELL", x)
+		break
+	default:
+		return fmt.Errorf("could not unmarshal healthcheck test type %T", x)
+	}
+
+	*t = test
+
+	return nil
+}
+
+// Conditions a dependency must meet before the service depending on it is started
+const (
+	DependencyConditionStarted = "service_started"
+	DependencyConditionHealthy = "service_healthy"
+)
+
+// DockerComposeServiceDependsOn maps the services a service depends on to the condition each must meet
+type DockerComposeServiceDependsOn map[string]DockerComposeServiceDependency
+
+type DockerComposeServiceDependency struct {
+	Condition string `json:"condition" yaml:"condition"`
+}
+
+func (d *DockerComposeServiceDependsOn) UnmarshalJSON(b []byte) error {
+	var i interface{}
+	err := json.Unmarshal(b, &i)
+	if err != nil {
+		return err
+	}
+
+	dependsOn := DockerComposeServiceDependsOn{}
+	switch x := i.(type) {
+	case []interface{}:
+		for _, p := range x {
+			s, ok := p.(string)
+			if !ok {
+				return fmt.Errorf("could not unmarshal depends_on service type %T", p)
+			}
+			dependsOn[s] = DockerComposeServiceDependency{Condition: DependencyConditionStarted}
+		}
+		break
+	case map[string]interface{}:
+		for k := range x {
+			var dependency DockerComposeServiceDependency
+			if x[k] != nil {
+				v, _ := json.Marshal(x[k])
+				if err := json.Unmarshal(v, &dependency); err != nil {
+					return err
+				}
+			}
+			switch dependency.Condition {
+			case "":
+				dependency.Condition = DependencyConditionStarted
+			case DependencyConditionStarted, DependencyConditionHealthy:
+			default:
+				return fmt.Errorf("unsupported depends_on condition for %s: %s", k, dependency.Condition)
+			}
+			dependsOn[k] = dependency
+		}
+		break
+	default:
+		return fmt.Errorf("could not unmarshal depends_on type %T", x)
+	}
+
+	*d = dependsOn
+
+	return nil
+}
+
 type DockerComposeService struct {
-	Image       string                                 `json:"image"`
-	Build       DockerComposeServiceBuild              `json:"build"`
-	WorkingDir  string                                 `json:"working_dir"`
-	Command     DockerComposeServiceCommand            `json:"command"`
-	Links       []string                               `json:"links"`
-	Environment DockerComposeServiceEnvironment        `json:"environment"`
-	Volumes     []string                               `json:"volumes"`
-	Expose      []string                               `json:"expose"`
-	Networks    map[string]DockerComposeServiceNetwork `json:"networks"`
+	Image       string                           `json:"image"`
+	Build       DockerComposeServiceBuild        `json:"build"`
+	WorkingDir  string                           `json:"working_dir"`
+	Command     DockerComposeServiceCommand      `json:"command"`
+	Entrypoint  DockerComposeServiceCommand      `json:"entrypoint"`
+	Links       []string                         `json:"links"`
+	DependsOn   DockerComposeServiceDependsOn    `json:"depends_on"`
+	Healthcheck *DockerComposeServiceHealthcheck `json:"healthcheck"`
+	Environment DockerComposeServiceEnvironment  `json:"environment"`
+	// EnvFile paths are relative to the compose file. Environment takes precedence over them.
+	EnvFile    DockerComposeStringList                `json:"env_file"`
+	Volumes    []string                               `json:"volumes"`
+	Tmpfs      DockerComposeStringList                `json:"tmpfs"`
+	Expose     DockerComposeStringList                `json:"expose"`
+	Ports      DockerComposeServicePorts              `json:"ports"`
+	ExtraHosts DockerComposeServiceExtraHosts         `json:"extra_hosts"`
+	User       string                                 `json:"user"`
+	Privileged bool                                   `json:"privileged"`
+	Networks   map[string]DockerComposeServiceNetwork `json:"networks"`
 }
 
 func GetServiceOrder(services map[string]DockerComposeService, serviceOrder []string) []string {
@@ -141,7 +429,7 @@ func GetServiceOrder(services map[string]DockerComposeService, serviceOrder []st
 		if isIn(serviceName, serviceOrder) {
 			break
 		}
-		for _, linkedService := range serviceDef.Links {
+		for _, linkedService := range getServiceDependencies(serviceDef) {
 			serviceOrder = getLinkedServiceOrder(linkedService, services, serviceOrder)
 		}
 		serviceOrder = append(serviceOrder, serviceName)
@@ -158,12 +446,68 @@ func getLinkedServiceOrder(serviceName string, services map[string]DockerCompose
 	if isIn(serviceName, serviceOrder) {
 		return serviceOrder
 	}
-	for _, linkedService := range services[serviceName].Links {
+	for _, linkedService := range getServiceDependencies(services[serviceName]) {
 		serviceOrder = getLinkedServiceOrder(linkedService, services, serviceOrder)
 	}
 	return append(serviceOrder, serviceName)
 }
 
+// getServiceDependencies returns the names of the services that a service links to or depends on
+func getServiceDependencies(s DockerComposeService) []string {
+	dependencies := []string{}
+	for _, l := range s.Links {
+		dependencies = append(dependencies, strings.Split(l, ":")[0])
+	}
+	for name := range s.DependsOn {
+		if !isIn(name, dependencies) {
+			dependencies = append(dependencies, name)
+		}
+	}
+	sort.Strings(dependencies[len(s.Links):])
+
+	return dependencies
+}
+
+// ValidateServiceDependencies returns an error if a service links to or depends on a service that is not defined
+// or if the dependencies form a cycle
+func ValidateServiceDependencies(services map[string]DockerComposeService) error {
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	visited := map[string]bool{}
+	for _, name := range names {
+		if err := validateServiceDependencies(name, services, []string{}, visited); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateServiceDependencies(serviceName string, services map[string]DockerComposeService, path []string, visited map[string]bool) error {
+	if isIn(serviceName, path) {
+		return fmt.Errorf("service dependency cycle: %s", strings.Join(append(path, serviceName), " -> "))
+	}
+	if visited[serviceName] {
+		return nil
+	}
+	path = append(path, serviceName)
+	for _, dependency := range getServiceDependencies(services[serviceName]) {
+		if _, ok := services[dependency]; !ok {
+			return fmt.Errorf("service %s depends on undefined service %s", serviceName, dependency)
+		}
+		if err := validateServiceDependencies(dependency, services, path, visited); err != nil {
+			return err
+		}
+	}
+	visited[serviceName] = true
+
+	return nil
+}
+
 func isIn(needle string, haystack []string) bool {
 	for _, v := range haystack {
 		if needle == v {
@@ -172,3 +516,90 @@ func isIn(needle string, haystack []string) bool {
 	}
 	return false
 }
+
+var supportedKeys = map[string][]string{
+	"":             {"version", "services", "volumes"},
+	"service":      {"image", "build", "working_dir", "command", "entrypoint", "links", "depends_on", "healthcheck", "environment", "env_file", "volumes", "tmpfs", "expose", "ports", "extra_hosts", "user", "privileged", "networks"},
+	"build":        {"context", "dockerfile", "args", "target"},
+	"healthcheck":  {"test", "interval", "timeout", "start_period", "retries", "disable"},
+	"network":      {"aliases"},
+	"volume":       {"external", "name"},
+	"depends_on":   {"condition"},
+	"service_port": {"target", "published", "protocol"},
+}
+
+// GetUnsupportedKeys returns the dotted paths, e.g. services.app.cap_add, of the keys in a compose file that are
+// not supported and would be ignored. Extension fields starting with x- are allowed anywhere.
+func GetUnsupportedKeys(dockerComposeYml []byte) ([]string, error) {
+	var contents map[string]interface{}
+	if err := yaml.Unmarshal(dockerComposeYml, &contents); err != nil {
+		return nil, err
+	}
+
+	unsupported := findUnsupportedKeys("", contents, "")
+	if services, ok := contents["services"].(map[string]interface{}); ok {
+		for serviceName, s := range services {
+			service, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			path := fmt.Sprintf("services.%s", serviceName)
+			unsupported = append(unsupported, findUnsupportedKeys(path, service, "service")...)
+			if build, ok := service["build"].(map[string]interface{}); ok {
+				unsupported = append(unsupported, findUnsupportedKeys(path+".build", build, "build")...)
+			}
+			if healthcheck, ok := service["healthcheck"].(map[string]interface{}); ok {
+				unsupported = append(unsupported, findUnsupportedKeys(path+".healthcheck", healthcheck, "healthcheck")...)
+			}
+			if networks, ok := service["networks"].(map[string]interface{}); ok {
+				for networkName, n := range networks {
+					if networkName != "default" {
+						unsupported = append(unsupported, fmt.Sprintf("%s.networks.%s", path, networkName))
+					}
+					if network, ok := n.(map[string]interface{}); ok {
+						unsupported = append(unsupported, findUnsupportedKeys(fmt.Sprintf("%s.networks.%s", path, networkName), network, "network")...)
+					}
+				}
+			}
+			if dependsOn, ok := service["depends_on"].(map[string]interface{}); ok {
+				for dependency, d := range dependsOn {
+					if condition, ok := d.(map[string]interface{}); ok {
+						unsupported = append(unsupported, findUnsupportedKeys(fmt.Sprintf("%s.depends_on.%s", path, dependency), condition, "depends_on")...)
+					}
+				}
+			}
+			if ports, ok := service["ports"].([]interface{}); ok {
+				for i, p := range ports {
+					if port, ok := p.(map[string]interface{}); ok {
+						unsupported = append(unsupported, findUnsupportedKeys(fmt.Sprintf("%s.ports.%d", path, i), port, "service_port")...)
+					}
+				}
+			}
+		}
+	}
+	if volumes, ok := contents["volumes"].(map[string]interface{}); ok {
+		for volumeName, v := range volumes {
+			if volume, ok := v.(map[string]interface{}); ok {
+				unsupported = append(unsupported, findUnsupportedKeys(fmt.Sprintf("volumes.%s", volumeName), volume, "volume")...)
+			}
+		}
+	}
+	sort.Strings(unsupported)
+
+	return unsupported, nil
+}
+
+func findUnsupportedKeys(path string, m map[string]interface{}, kind string) []string {
+	unsupported := []string{}
+	for k := range m {
+		if strings.HasPrefix(k, "x-") || isIn(k, supportedKeys[kind]) {
+			continue
+		}
+		if path == "" {
+			unsupported = append(unsupported, k)
+		} else {
+			unsupported = append(unsupported, fmt.Sprintf("%s.%s", path, k))
+		}
+	}
+	return unsupported
+}
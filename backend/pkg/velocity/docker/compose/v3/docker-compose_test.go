@@ -218,3 +218,204 @@ func TestGetServiceOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestDockerComposeDependsOnUnmarshal(t *testing.T) {
+	dockerComposeYaml := `
+---
+version: '3'
+
+services:
+
+  app:
+    image: golang:1.12
+    depends_on:
+      database:
+        condition: service_healthy
+      cache: {}
+
+  worker:
+    image: golang:1.12
+    depends_on:
+      - database
+
+  database:
+    image: postgres:11
+    healthcheck:
+      test: ["CMD", "pg_isready", "-U", "postgres"]
+      interval: 2s
+      timeout: 5s
+      start_period: 10s
+      retries: 5
+
+  cache:
+    image: redis:5
+    healthcheck:
+      test: redis-cli ping
+`
+	var dockerComposeConf v3.DockerComposeYaml
+	err := yaml.Unmarshal([]byte(dockerComposeYaml), &dockerComposeConf)
+	assert.Nil(t, err)
+
+	assert.Equal(t, v3.DockerComposeServiceDependsOn{
+		"database": {Condition: v3.DependencyConditionHealthy},
+		"cache":    {Condition: v3.DependencyConditionStarted},
+	}, dockerComposeConf.Services["app"].DependsOn)
+	assert.Equal(t, v3.DockerComposeServiceDependsOn{
+		"database": {Condition: v3.DependencyConditionStarted},
+	}, dockerComposeConf.Services["worker"].DependsOn)
+	assert.Equal(t, &v3.DockerComposeServiceHealthcheck{
+		Test:        v3.DockerComposeServiceHealthcheckTest{"CMD", "pg_isready", "-U", "postgres"},
+		Interval:    "2s",
+		Timeout:     "5s",
+		StartPeriod: "10s",
+		Retries:     5,
+	}, dockerComposeConf.Services["database"].Healthcheck)
+	assert.Equal(t, v3.DockerComposeServiceHealthcheckTest{"CMD-SHELL", "redis-cli ping"}, dockerComposeConf.Services["cache"].Healthcheck.Test)
+
+	assert.Nil(t, v3.ValidateServiceDependencies(dockerComposeConf.Services))
+	serviceOrder := v3.GetServiceOrder(dockerComposeConf.Services, []string{})
+	assert.Len(t, serviceOrder, 4)
+	position := map[string]int{}
+	for i, serviceName := range serviceOrder {
+		position[serviceName] = i
+	}
+	assert.True(t, position["database"] < position["app"])
+	assert.True(t, position["cache"] < position["app"])
+	assert.True(t, position["database"] < position["worker"])
+}
+
+func TestDockerComposeDependsOnUnmarshalUnsupportedCondition(t *testing.T) {
+	dockerComposeYaml := `
+---
+version: '3'
+services:
+  app:
+    image: golang:1.12
+    depends_on:
+      database:
+        condition: service_completed
+`
+	var dockerComposeConf v3.DockerComposeYaml
+	err := yaml.Unmarshal([]byte(dockerComposeYaml), &dockerComposeConf)
+	assert.EqualError(t, err, "error unmarshaling JSON: unsupported depends_on condition for database: service_completed")
+}
+
+func TestValidateServiceDependencies(t *testing.T) {
+	assert.EqualError(t, v3.ValidateServiceDependencies(map[string]v3.DockerComposeService{
+		"app": {DependsOn: v3.DockerComposeServiceDependsOn{"database": {}}},
+	}), "service app depends on undefined service database")
+
+	assert.EqualError(t, v3.ValidateServiceDependencies(map[string]v3.DockerComposeService{
+		"app":      {DependsOn: v3.DockerComposeServiceDependsOn{"database": {}}},
+		"database": {Links: []string{"app:frontend"}},
+	}), "service dependency cycle: app -> database -> app")
+}
+
+func TestDockerComposeExtendedUnmarshal(t *testing.T) {
+	dockerComposeYaml := `
+---
+version: '3.4'
+
+x-defaults: &defaults
+  user: "1000"
+  env_file: .env
+  extra_hosts:
+    - "registry.local:10.0.0.1"
+
+services:
+  app:
+    <<: *defaults
+    build:
+      context: app
+      args:
+        GO_VERSION: 1.12
+        NETRC:
+      target: test
+    entrypoint: /bin/sh -c
+    ports:
+      - 8080:80
+      - "127.0.0.1:5432:5432/tcp"
+      - target: 9000
+        published: 9000
+        protocol: udp
+    expose:
+      - 3000
+    tmpfs: /run
+    privileged: true
+    volumes:
+      - data:/var/lib/data
+    x-notes: ignored
+
+volumes:
+  data: {}
+  shared:
+    external: true
+`
+	var dockerComposeConf v3.DockerComposeYaml
+	err := yaml.Unmarshal([]byte(dockerComposeYaml), &dockerComposeConf)
+	assert.Nil(t, err)
+
+	goVersion := "1.12"
+	assert.Equal(t, v3.DockerComposeService{
+		Build: v3.DockerComposeServiceBuild{
+			Context:    "app",
+			Dockerfile: "Dockerfile",
+			Args: v3.DockerComposeServiceBuildArgs{
+				"GO_VERSION": &goVersion,
+				"NETRC":      nil,
+			},
+			Target: "test",
+		},
+		Entrypoint: v3.DockerComposeServiceCommand{"/bin/sh", "-c"},
+		EnvFile:    v3.DockerComposeStringList{".env"},
+		Volumes:    []string{"data:/var/lib/data"},
+		Tmpfs:      v3.DockerComposeStringList{"/run"},
+		Expose:     v3.DockerComposeStringList{"3000"},
+		Ports:      v3.DockerComposeServicePorts{"8080:80", "127.0.0.1:5432:5432/tcp", "9000:9000/udp"},
+		ExtraHosts: v3.DockerComposeServiceExtraHosts{"registry.local:10.0.0.1"},
+		User:       "1000",
+		Privileged: true,
+	}, dockerComposeConf.Services["app"])
+	assert.Equal(t, map[string]*v3.DockerComposeVolume{
+		"data":   {},
+		"shared": {External: true},
+	}, dockerComposeConf.Volumes)
+}
+
+func TestGetUnsupportedKeys(t *testing.T) {
+	dockerComposeYaml := `
+---
+version: '3'
+x-defaults: &defaults
+  image: alpine
+networks:
+  backend: {}
+services:
+  app:
+    <<: *defaults
+    cap_add: [SYS_ADMIN]
+    x-notes: ignored
+    build:
+      context: .
+      cache_from: [alpine]
+    healthcheck:
+      test: "true"
+    networks:
+      backend:
+        ipv4_address: 10.0.0.2
+  db:
+    image: postgres
+    deploy:
+      replicas: 2
+`
+	unsupported, err := v3.GetUnsupportedKeys([]byte(dockerComposeYaml))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"networks",
+		"services.app.build.cache_from",
+		"services.app.cap_add",
+		"services.app.networks.backend",
+		"services.app.networks.backend.ipv4_address",
+		"services.db.deploy",
+	}, unsupported)
+}
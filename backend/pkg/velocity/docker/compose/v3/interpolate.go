@@ -0,0 +1,135 @@
+package v3
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// LookupFunc returns the value of the named variable and whether it is set
+type LookupFunc func(name string) (string, bool)
+
+var interpolationRegex = regexp.MustCompile(`\$\$|\$\{([^}]*)\}`)
+
+// Interpolate returns a copy of the compose file with the ${NAME}, ${NAME:-default}, ${NAME-default},
+// ${NAME:?error} and ${NAME?error} variables in its values substituted. $$ is a literal $.
+func (y *DockerComposeYaml) Interpolate(lookup LookupFunc) (*DockerComposeYaml, error) {
+	interpolated, err := interpolateValue(reflect.ValueOf(*y), lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	result := interpolated.Interface().(DockerComposeYaml)
+	return &result, nil
+}
+
+// interpolateValue returns a deep copy of the value with its strings interpolated
+func interpolateValue(v reflect.Value, lookup LookupFunc) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := InterpolateString(v.String(), lookup)
+		if err != nil {
+			return v, err
+		}
+		n := reflect.New(v.Type()).Elem()
+		n.SetString(s)
+		return n, nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+		e, err := interpolateValue(v.Elem(), lookup)
+		if err != nil {
+			return v, err
+		}
+		n := reflect.New(v.Type().Elem())
+		n.Elem().Set(e)
+		return n, nil
+	case reflect.Struct:
+		n := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			f, err := interpolateValue(v.Field(i), lookup)
+			if err != nil {
+				return v, err
+			}
+			n.Field(i).Set(f)
+		}
+		return n, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		n := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, err := interpolateValue(v.Index(i), lookup)
+			if err != nil {
+				return v, err
+			}
+			n.Index(i).Set(e)
+		}
+		return n, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		n := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			e, err := interpolateValue(v.MapIndex(k), lookup)
+			if err != nil {
+				return v, err
+			}
+			n.SetMapIndex(k, e)
+		}
+		return n, nil
+	}
+
+	return v, nil
+}
+
+// InterpolateString substitutes the variables in a string
+func InterpolateString(s string, lookup LookupFunc) (string, error) {
+	var err error
+	result := interpolationRegex.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		value, e := interpolateVariable(match[2:len(match)-1], lookup)
+		if e != nil && err == nil {
+			err = e
+		}
+		return value
+	})
+
+	return result, err
+}
+
+var interpolationSeparatorRegex = regexp.MustCompile(`:?[-?]`)
+
+func interpolateVariable(expression string, lookup LookupFunc) (string, error) {
+	if expression == "" {
+		return "", fmt.Errorf("invalid interpolation: ${}")
+	}
+	// parameter names may contain the separators, e.g. ${my-param}, so an exact match takes precedence
+	if value, ok := lookup(expression); ok {
+		return value, nil
+	}
+
+	loc := interpolationSeparatorRegex.FindStringIndex(expression)
+	if loc == nil || loc[0] < 1 {
+		return "", fmt.Errorf("parameter %s missing", expression)
+	}
+	name, separator, arg := expression[:loc[0]], expression[loc[0]:loc[1]], expression[loc[1]:]
+	value, ok := lookup(name)
+	unset := !ok || (strings.HasPrefix(separator, ":") && value == "")
+	switch {
+	case !unset:
+		return value, nil
+	case strings.HasSuffix(separator, "-"):
+		return arg, nil
+	case arg == "":
+		return "", fmt.Errorf("parameter %s is required", name)
+	default:
+		return "", fmt.Errorf("parameter %s: %s", name, arg)
+	}
+}
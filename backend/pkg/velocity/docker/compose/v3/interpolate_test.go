@@ -0,0 +1,90 @@
+package v3_test
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	v3 "github.com/velocity-ci/velocity/backend/pkg/velocity/docker/compose/v3"
+)
+
+func lookupFrom(params map[string]string) v3.LookupFunc {
+	return func(name string) (string, bool) {
+		v, ok := params[name]
+		return v, ok
+	}
+}
+
+func TestInterpolateString(t *testing.T) {
+	lookup := lookupFrom(map[string]string{
+		"git.commit.sha.short": "abc1234",
+		"empty":                "",
+		"my-param":             "hyphenated",
+	})
+
+	tests := []struct {
+		in   string
+		want string
+		err  string
+	}{
+		{in: "app:${git.commit.sha.short}", want: "app:abc1234"},
+		{in: "${my-param}", want: "hyphenated"},
+		{in: "${missing:-fallback}", want: "fallback"},
+		{in: "${empty:-fallback}", want: "fallback"},
+		{in: "${empty-fallback}", want: ""},
+		{in: "${missing-fallback}", want: "fallback"},
+		{in: "echo $$HOME $HOME", want: "echo $HOME $HOME"},
+		{in: "${missing}", err: "parameter missing missing"},
+		{in: "${empty:?must be set}", err: "parameter empty: must be set"},
+		{in: "${missing?}", err: "parameter missing is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := v3.InterpolateString(tt.in, lookup)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDockerComposeYamlInterpolate(t *testing.T) {
+	dockerComposeYaml := `
+---
+version: '3'
+services:
+  app:
+    image: app:${git.commit.sha.short}
+    command: ./test --db ${db.host:-database}
+    environment:
+      TOKEN: ${token}
+    build:
+      args:
+        VERSION: ${git.commit.sha.short}
+`
+	var dockerComposeConf v3.DockerComposeYaml
+	err := yaml.Unmarshal([]byte(dockerComposeYaml), &dockerComposeConf)
+	assert.Nil(t, err)
+
+	interpolated, err := dockerComposeConf.Interpolate(lookupFrom(map[string]string{
+		"git.commit.sha.short": "abc1234",
+		"token":                "s3cr3t",
+	}))
+	assert.Nil(t, err)
+
+	app := interpolated.Services["app"]
+	assert.Equal(t, "app:abc1234", app.Image)
+	assert.Equal(t, v3.DockerComposeServiceCommand{"./test", "--db", "database"}, app.Command)
+	assert.Equal(t, v3.DockerComposeServiceEnvironment{"TOKEN": "s3cr3t"}, app.Environment)
+	assert.Equal(t, "abc1234", *app.Build.Args["VERSION"])
+	assert.Equal(t, "", app.Build.Context)
+
+	// the original is left untouched
+	assert.Equal(t, "app:${git.commit.sha.short}", dockerComposeConf.Services["app"].Image)
+
+	_, err = dockerComposeConf.Interpolate(lookupFrom(map[string]string{}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	v3 "github.com/velocity-ci/velocity/backend/pkg/velocity/docker/compose/v3"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+)
+
+// DefaultStartTimeout is how long a container waits for its dependencies before failing
+const DefaultStartTimeout = 2 * time.Minute
+
+// Conditions a dependency must meet before a container that depends on it is started
+const (
+	ConditionStarted = v3.DependencyConditionStarted
+	ConditionHealthy = v3.DependencyConditionHealthy
+)
+
+const dependencyPollInterval = 500 * time.Millisecond
+
+type containerDependency struct {
+	container *Container
+	condition string
+}
+
+// NewHealthConfig converts a compose healthcheck into Docker's container healthcheck configuration
+func NewHealthConfig(h *v3.DockerComposeServiceHealthcheck) (*container.HealthConfig, error) {
+	if h == nil {
+		return nil, nil
+	}
+	if h.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}, nil
+	}
+
+	healthConfig := &container.HealthConfig{
+		Test:    h.Test,
+		Retries: h.Retries,
+	}
+	var err error
+	if healthConfig.Interval, err = parseHealthDuration("interval", h.Interval); err != nil {
+		return nil, err
+	}
+	if healthConfig.Timeout, err = parseHealthDuration("timeout", h.Timeout); err != nil {
+		return nil, err
+	}
+	if healthConfig.StartPeriod, err = parseHealthDuration("start_period", h.StartPeriod); err != nil {
+		return nil, err
+	}
+
+	return healthConfig, nil
+}
+
+func parseHealthDuration(field, d string) (time.Duration, error) {
+	if d == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(d)
+	if err != nil {
+		return 0, fmt.Errorf("invalid healthcheck %s: %s", field, err)
+	}
+	return duration, nil
+}
+
+// DependsOn makes the container wait for the given container to meet the condition before it is started
+func (c *Container) DependsOn(dependency *Container, condition string) {
+	c.dependencies = append(c.dependencies, &containerDependency{
+		container: dependency,
+		condition: condition,
+	})
+}
+
+// waitForDependencies blocks until all of the container's dependencies meet their conditions or the timeout is reached
+func (c *Container) waitForDependencies(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, d := range c.dependencies {
+		fmt.Fprintf(c.writer,
+			output.ColorFmt(output.ANSIInfo, "-> %s waiting for %s (%s)", "\n"),
+			GetContainerName(c.name),
+			GetContainerName(d.container.name),
+			d.condition,
+		)
+		for {
+			ready, err := d.container.meetsCondition(d.condition)
+			if err != nil {
+				return fmt.Errorf("dependency %s: %s", d.container.name, err)
+			}
+			if ready {
+				break
+			}
+			if c.isStopped() {
+				return fmt.Errorf("stopped while waiting for %s", d.container.name)
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timed out after %s waiting for %s to be %s", timeout, d.container.name, conditionState(d.condition))
+			}
+			time.Sleep(dependencyPollInterval)
+		}
+	}
+
+	return nil
+}
+
+func (c *Container) meetsCondition(condition string) (bool, error) {
+	if err := c.getErr(); err != nil {
+		return false, err
+	}
+	c.mutex.Lock()
+	containerID := c.containerID
+	c.mutex.Unlock()
+	if containerID == "" {
+		return false, nil
+	}
+
+	info, err := dockerClient.ContainerInspect(context.Background(), containerID)
+	if err != nil {
+		return false, err
+	}
+	if info.State.Running {
+		if condition != ConditionHealthy {
+			return true, nil
+		}
+		if info.State.Health == nil {
+			return false, fmt.Errorf("no healthcheck is configured")
+		}
+		switch info.State.Health.Status {
+		case types.Healthy:
+			return true, nil
+		case types.Unhealthy:
+			return false, fmt.Errorf("container is unhealthy")
+		}
+		return false, nil
+	}
+	if info.State.Status == "exited" || info.State.Status == "dead" {
+		if condition != ConditionHealthy && info.State.ExitCode == 0 {
+			return true, nil
+		}
+		return false, fmt.Errorf("container exited with code %d", info.State.ExitCode)
+	}
+
+	return false, nil
+}
+
+func conditionState(condition string) string {
+	if condition == ConditionHealthy {
+		return "healthy"
+	}
+	return "started"
+}
@@ -0,0 +1,182 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+	"go.uber.org/zap"
+)
+
+// PlatformTag returns the tag that a platform's image is built as before the images are combined into a manifest
+// list e.g. app:1.0 and linux/arm64 is app:1.0-linux-arm64
+func PlatformTag(tag, platform string) string {
+	suffix := strings.Replace(platform, "/", "-", -1)
+	if strings.LastIndex(tag, ":") <= strings.LastIndex(tag, "/") {
+		return fmt.Sprintf("%s:latest-%s", tag, suffix)
+	}
+	return fmt.Sprintf("%s-%s", tag, suffix)
+}
+
+// qemuArchitectures are the names that QEMU's binfmt_misc handlers are registered as for each architecture
+var qemuArchitectures = map[string]string{
+	"amd64":   "x86_64",
+	"386":     "i386",
+	"arm64":   "aarch64",
+	"arm":     "arm",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+// CheckEmulation returns an error when images for the platform cannot be built on this host because it is a
+// foreign architecture without a registered QEMU emulator
+func CheckEmulation(platform string) error {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || parts[0] != "linux" {
+		return fmt.Errorf("unsupported platform %s", platform)
+	}
+	arch := parts[1]
+	if arch == runtime.GOARCH {
+		return nil
+	}
+	qemuArch, ok := qemuArchitectures[arch]
+	if !ok {
+		return fmt.Errorf("unsupported platform %s", platform)
+	}
+	if _, err := os.Stat(fmt.Sprintf("/proc/sys/fs/binfmt_misc/qemu-%s", qemuArch)); err != nil {
+		return fmt.Errorf("no emulator is registered for %s, install one with: docker run --privileged --rm tonistiigi/binfmt --install %s", platform, arch)
+	}
+	return nil
+}
+
+// TagImage tags a local image
+func TagImage(ctx context.Context, source, target string) error {
+	return dockerClient.ImageTag(ctx, source, target)
+}
+
+// NewManifestPusher returns a new manifest list pusher
+func NewManifestPusher() *ManifestPusher {
+	return &ManifestPusher{}
+}
+
+// ManifestPusher pushes manifest lists through the docker CLI. Pushes are interrupted by cancelling their context.
+type ManifestPusher struct{}
+
+// Push creates a manifest list for the tag from the already pushed images, pushes it and returns its digest
+func (mP *ManifestPusher) Push(
+	ctx context.Context,
+	writer io.Writer,
+	secrets []string,
+	tag string,
+	images []string,
+	authConfigs map[string]types.AuthConfig,
+) (string, error) {
+	logging.GetLogger().Debug("pushing manifest list",
+		zap.String("tag", tag),
+		zap.Strings("images", images),
+	)
+
+	tmpDir, err := ioutil.TempDir("", "vci-manifest-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := writeDockerConfig(tmpDir, authConfigs); err != nil {
+		return "", err
+	}
+	env := []string{"DOCKER_CLI_EXPERIMENTAL=enabled", fmt.Sprintf("DOCKER_CONFIG=%s", tmpDir)}
+
+	if err := runCommand(ctx, writer, secrets, env, append([]string{"docker", "manifest", "create", "--amend", tag}, images...)); err != nil {
+		return "", interruptedError(ctx, "manifest push", fmt.Errorf("manifest push failed: %s", err))
+	}
+	// docker manifest push prints the manifest list's digest
+	var pushOutput bytes.Buffer
+	if err := runCommand(ctx, io.MultiWriter(writer, &pushOutput), secrets, env, []string{"docker", "manifest", "push", "--purge", tag}); err != nil {
+		return "", interruptedError(ctx, "manifest push", fmt.Errorf("manifest push failed: %s", err))
+	}
+	digest := lastDigest(pushOutput.Bytes())
+	if digest == "" {
+		return "", fmt.Errorf("manifest push failed: no digest was reported for %s", tag)
+	}
+
+	fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> pushed manifest list: %s %s (%s)", "\n"), tag, digest, strings.Join(images, ", "))
+	logging.GetLogger().Debug("finished pushing manifest list", zap.String("tag", tag), zap.String("digest", digest))
+	return digest, nil
+}
+
+// InspectManifest returns the registry manifest of the image, which is a manifest list for multi-platform images
+func InspectManifest(ctx context.Context, image string, authConfigs map[string]types.AuthConfig) ([]byte, error) {
+	tmpDir, err := ioutil.TempDir("", "vci-manifest-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := writeDockerConfig(tmpDir, authConfigs); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "docker", "manifest", "inspect", image)
+	cmd.Env = append(os.Environ(), "DOCKER_CLI_EXPERIMENTAL=enabled", fmt.Sprintf("DOCKER_CONFIG=%s", tmpDir))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	b, err := cmd.Output()
+	if err != nil {
+		return nil, interruptedError(ctx, "manifest inspect", fmt.Errorf("could not inspect %s: %s", image, strings.TrimSpace(stderr.String())))
+	}
+	return b, nil
+}
+
+// PlatformImage is the image of a platform in a manifest list
+type PlatformImage struct {
+	// Platform is os/architecture[/variant] e.g. linux/arm/v7
+	Platform string
+	// Image references the platform's manifest by digest
+	Image string
+}
+
+// ManifestListImages returns the image of every platform in the image's manifest list, or none when the manifest
+// is of a single platform image
+func ManifestListImages(image string, manifest []byte) ([]PlatformImage, error) {
+	var list struct {
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				Architecture string `json:"architecture"`
+				OS           string `json:"os"`
+				Variant      string `json:"variant"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(manifest, &list); err != nil {
+		return nil, fmt.Errorf("could not parse the manifest of %s: %s", image, err)
+	}
+	images := []PlatformImage{}
+	for _, m := range list.Manifests {
+		if digestRegex.FindString(m.Digest) != m.Digest {
+			return nil, fmt.Errorf("manifest list of %s has an invalid digest %q", image, m.Digest)
+		}
+		platform := fmt.Sprintf("%s/%s", m.Platform.OS, m.Platform.Architecture)
+		if m.Platform.Variant != "" {
+			platform = fmt.Sprintf("%s/%s", platform, m.Platform.Variant)
+		}
+		images = append(images, PlatformImage{Platform: platform, Image: DigestImage(image, m.Digest)})
+	}
+	return images, nil
+}
+
+// DigestImage returns the reference to the digest in the image's repository e.g. app:1.0 and sha256:... is
+// app@sha256:...
+func DigestImage(image, digest string) string {
+	return fmt.Sprintf("%s@%s", imageRepository(image), digest)
+}
@@ -0,0 +1,46 @@
+package docker_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/docker"
+)
+
+func TestPlatformTag(t *testing.T) {
+	assert.Equal(t, "app:1.0-linux-arm64", docker.PlatformTag("app:1.0", "linux/arm64"))
+	assert.Equal(t, "app:latest-linux-arm-v7", docker.PlatformTag("app", "linux/arm/v7"))
+	assert.Equal(t, "localhost:5000/app:latest-linux-amd64", docker.PlatformTag("localhost:5000/app", "linux/amd64"))
+	assert.Equal(t, "localhost:5000/app:2-linux-amd64", docker.PlatformTag("localhost:5000/app:2", "linux/amd64"))
+}
+
+func TestManifestListImages(t *testing.T) {
+	amd64 := "sha256:" + strings.Repeat("a", 64)
+	arm := "sha256:" + strings.Repeat("b", 64)
+	list := fmt.Sprintf(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+		"manifests": [
+			{"digest": %q, "platform": {"architecture": "amd64", "os": "linux"}},
+			{"digest": %q, "platform": {"architecture": "arm", "os": "linux", "variant": "v7"}}
+		]
+	}`, amd64, arm)
+	images, err := docker.ManifestListImages("localhost:5000/app:1.0", []byte(list))
+	assert.Nil(t, err)
+	assert.Equal(t, []docker.PlatformImage{
+		{Platform: "linux/amd64", Image: "localhost:5000/app@" + amd64},
+		{Platform: "linux/arm/v7", Image: "localhost:5000/app@" + arm},
+	}, images)
+
+	image := fmt.Sprintf(`{"schemaVersion": 2, "config": {"digest": %q}, "layers": []}`, amd64)
+	images, err = docker.ManifestListImages("localhost:5000/app:1.0", []byte(image))
+	assert.Nil(t, err)
+	assert.Empty(t, images)
+
+	assert.Equal(t, "registry.example.com:5000/team/app@"+amd64, docker.DigestImage("registry.example.com:5000/team/app:1.0", amd64))
+
+	_, err = docker.ManifestListImages("app", []byte(`{"manifests": [{"digest": "latest"}]}`))
+	assert.EqualError(t, err, `manifest list of app has an invalid digest "latest"`)
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"regexp"
 	"strings"
 
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
 	"go.uber.org/zap"
 )
 
@@ -36,6 +38,38 @@ func HandleOutput(body io.ReadCloser, censored []string, writer io.Writer) {
 	body.Close()
 }
 
+var buildKitStepRegex = regexp.MustCompile(`^#\d+ \[`)
+
+// HandleBuildKitOutput writes BuildKit's plain progress output with step headers and errors highlighted. Lines of
+// any length are read so that the docker CLI never blocks writing to a reader that has stopped.
+func HandleBuildKitOutput(body io.ReadCloser, censored []string, writer io.Writer) {
+	reader := bufio.NewReader(body)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if line == "" && err != nil {
+			break
+		}
+		o := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		for _, c := range censored {
+			o = strings.Replace(o, c, "***", -1)
+		}
+		switch {
+		case buildKitStepRegex.MatchString(o):
+			o = output.ColorFmt(output.ANSIInfo, o, "\n")
+		case strings.Contains(o, " ERROR") || strings.HasPrefix(strings.ToLower(o), "error"):
+			o = output.ColorFmt(output.ANSIError, o, "\n")
+		default:
+			o = fmt.Sprintf("%s\n", o)
+		}
+		writer.Write([]byte(o))
+		if err != nil {
+			break
+		}
+	}
+	body.Close()
+}
+
 func handleLogOutput(b []byte) string {
 	if len(b) <= 8 {
 		return ""
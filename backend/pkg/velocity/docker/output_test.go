@@ -0,0 +1,22 @@
+package docker_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/docker"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+)
+
+func TestHandleBuildKitOutputLongLines(t *testing.T) {
+	output.ColorDisable()
+	long := strings.Repeat("a", 256*1024)
+	body := ioutil.NopCloser(strings.NewReader("#1 [internal] load\r\n" + long + "\ntoken s3cr3t\nlast"))
+
+	var b bytes.Buffer
+	docker.HandleBuildKitOutput(body, []string{"s3cr3t"}, &b)
+	assert.Equal(t, "#1 [internal] load\n"+long+"\ntoken ***\nlast\n", b.String())
+}
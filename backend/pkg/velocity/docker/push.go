@@ -1,9 +1,14 @@
 package docker
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"regexp"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
@@ -13,53 +18,99 @@ import (
 
 // NewImagePusher returns a new Docker image pusher
 func NewImagePusher() *ImagePusher {
-	return &ImagePusher{
-		running: false,
-	}
+	return &ImagePusher{}
 }
 
-// Represents a stoppable Docker image pusher
-type ImagePusher struct {
-	running bool
-
-	response io.ReadCloser
-}
+// ImagePusher pushes images. Pushes are interrupted by cancelling their context.
+type ImagePusher struct{}
 
-// Push pushes a docker image with the given parameters
+// Push pushes a docker image with the given parameters and returns the pushed digest
 func (iP *ImagePusher) Push(
+	ctx context.Context,
 	writer io.Writer,
 	secrets []string,
 	tag string,
 	addressAuthTokens map[string]string,
-) error {
+) (string, error) {
 	// Determine correct authToken
 	authToken := getAuthToken(tag, addressAuthTokens)
 	logging.GetLogger().Debug("pushing image",
 		zap.String("tag", tag),
 		zap.String("registry auth", authToken),
 	)
-	reader, err := dockerClient.ImagePush(context.Background(), tag, types.ImagePushOptions{
+	reader, err := dockerClient.ImagePush(ctx, tag, types.ImagePushOptions{
 		All:          true,
 		RegistryAuth: authToken,
 	})
 	if err != nil {
-		return err
+		return "", interruptedError(ctx, "image push", err)
+	}
+	var pushOutput bytes.Buffer
+	HandleOutput(struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(reader, &pushOutput), reader}, secrets, writer)
+	if ctx.Err() != nil {
+		return "", interruptedError(ctx, "image push", ctx.Err())
+	}
+	digest, err := pushDigest(tag, pushOutput.Bytes(), secrets)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> pushed: %s %s", "\n"), tag, digest)
+	logging.GetLogger().Debug("finished pushing image", zap.String("tag", tag), zap.String("digest", digest))
+
+	return digest, nil
+}
+
+// pushDigest returns the digest of the pushed image from the push's JSON stream, or the error that the stream ended
+// with. A push that did not report a digest has failed.
+func pushDigest(tag string, b []byte, secrets []string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		var m struct {
+			Error       string `json:"error"`
+			ErrorDetail *struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			continue
+		}
+		message := m.Error
+		if m.ErrorDetail != nil && m.ErrorDetail.Message != "" {
+			message = m.ErrorDetail.Message
+		}
+		if message != "" {
+			for _, c := range secrets {
+				message = strings.Replace(message, c, "***", -1)
+			}
+			return "", fmt.Errorf("could not push %s: %s", tag, message)
+		}
 	}
-	HandleOutput(reader, secrets, writer)
-	if !iP.running {
-		return fmt.Errorf("image push interrupted")
+
+	digest := lastDigest(b)
+	if digest == "" {
+		return "", fmt.Errorf("could not push %s: no digest was reported", tag)
 	}
-	iP.Stop()
-	fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> pushed: %s", "\n"), tag)
-	logging.GetLogger().Debug("finished pushing image", zap.String("tag", tag))
+	return digest, nil
+}
+
+var digestRegex = regexp.MustCompile(`sha256:[a-f0-9]{64}`)
 
-	return nil
+// lastDigest returns the last image digest in the output of a push, which is the digest of the pushed manifest
+func lastDigest(b []byte) string {
+	digests := digestRegex.FindAll(b, -1)
+	if len(digests) < 1 {
+		return ""
+	}
+	return string(digests[len(digests)-1])
 }
 
-// Stop interrupts an image push process
-func (iP *ImagePusher) Stop() {
-	if iP.running {
-		iP.response.Close()
-		iP.running = false
+// interruptedError returns an error that says the operation was interrupted if its context is done
+func interruptedError(ctx context.Context, operation string, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s interrupted: %s", operation, ctx.Err())
 	}
+	return err
 }
@@ -0,0 +1,31 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushDigest(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	stream := strings.Join([]string{
+		`{"status":"The push refers to repository [localhost:5000/app]"}`,
+		`{"status":"Pushed","progressDetail":{},"id":"0123456789ab"}`,
+		`{"status":"1.0: digest: ` + digest + ` size: 528"}`,
+		`{"progressDetail":{},"aux":{"Tag":"1.0","Digest":"` + digest + `","Size":528}}`,
+	}, "\n")
+	d, err := pushDigest("localhost:5000/app:1.0", []byte(stream), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, digest, d)
+
+	failed := strings.Join([]string{
+		`{"status":"The push refers to repository [localhost:5000/app]"}`,
+		`{"errorDetail":{"message":"unauthorized: token s3cr3t is invalid"},"error":"unauthorized: token s3cr3t is invalid"}`,
+	}, "\n")
+	_, err = pushDigest("localhost:5000/app:1.0", []byte(failed), []string{"s3cr3t"})
+	assert.EqualError(t, err, "could not push localhost:5000/app:1.0: unauthorized: token *** is invalid")
+
+	_, err = pushDigest("localhost:5000/app:1.0", []byte(`{"status":"The push refers to repository [localhost:5000/app]"}`), nil)
+	assert.EqualError(t, err, "could not push localhost:5000/app:1.0: no digest was reported")
+}
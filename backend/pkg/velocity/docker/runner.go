@@ -32,8 +32,12 @@ type ContainerManager struct {
 	authTokens  map[string]string
 
 	containers      []*Container
+	volumes         []*Volume
 	running         bool
 	firstStoppedSvc string
+	startTimeout    time.Duration
+	// abortOnContainerExit stops all of the containers once the first of them exits
+	abortOnContainerExit bool
 }
 
 // NewContainerManager returns a new container manager
@@ -43,11 +47,14 @@ func NewContainerManager(
 	registryAuthTokens map[string]string,
 ) *ContainerManager {
 	return &ContainerManager{
-		id:          id,
-		containers:  []*Container{},
-		authConfigs: registryAuthConfigs,
-		authTokens:  registryAuthTokens,
-		running:     false,
+		id:                   id,
+		containers:           []*Container{},
+		volumes:              []*Volume{},
+		authConfigs:          registryAuthConfigs,
+		authTokens:           registryAuthTokens,
+		running:              false,
+		startTimeout:         DefaultStartTimeout,
+		abortOnContainerExit: true,
 	}
 }
 
@@ -57,6 +64,24 @@ func (cM *ContainerManager) AddContainer(container *Container) error {
 	return nil
 }
 
+// AddServiceContainer adds a container that runs alongside the others. It does not end the execution when it exits
+// and its exit code does not count towards success.
+func (cM *ContainerManager) AddServiceContainer(container *Container) error {
+	container.isService = true
+	return cM.AddContainer(container)
+}
+
+// SetAbortOnContainerExit sets whether all containers are stopped when the first exits, or only once every
+// non-service container has exited. It defaults to true.
+func (cM *ContainerManager) SetAbortOnContainerExit(abort bool) {
+	cM.abortOnContainerExit = abort
+}
+
+// SetStartTimeout sets how long containers wait for their dependencies before failing
+func (cM *ContainerManager) SetStartTimeout(timeout time.Duration) {
+	cM.startTimeout = timeout
+}
+
 func (cM *ContainerManager) doContainers(f func(c *Container) error) error {
 	if cM.IsRunning() {
 		cM.mutex.Lock()
@@ -70,8 +95,8 @@ func (cM *ContainerManager) doContainers(f func(c *Container) error) error {
 	return nil
 }
 
-// Execute runs the containers
-func (cM *ContainerManager) Execute(secrets []string) error {
+// Execute runs the containers. Cancelling the context interrupts pulling and building their images.
+func (cM *ContainerManager) Execute(ctx context.Context, secrets []string) error {
 	defer cM.Stop()
 	cM.running = true
 
@@ -89,11 +114,16 @@ func (cM *ContainerManager) Execute(secrets []string) error {
 		}
 
 		cM.networkID = networkResp.ID
+
+		err = cM.createVolumes()
 		cM.mutex.Unlock()
+		if err != nil {
+			return err
+		}
 	}
 
 	err := cM.doContainers(func(c *Container) error {
-		return c.Get(secrets, cM.authConfigs, cM.authTokens)
+		return c.Get(ctx, secrets, cM.authConfigs, cM.authTokens)
 	})
 	if err != nil {
 		return err
@@ -115,20 +145,26 @@ func (cM *ContainerManager) Execute(secrets []string) error {
 
 	if cM.IsRunning() {
 		cM.mutex.Lock()
-		firstStoppedSvcCh := make(chan string, 1)
+		stoppedSvcCh := make(chan string, len(cM.containers))
 		// Start services
 		for _, container := range cM.containers {
 			cM.wg.Add(1)
-			go container.Run(&cM.wg, secrets, firstStoppedSvcCh)
+			go container.Run(&cM.wg, secrets, cM.startTimeout, stoppedSvcCh)
 		}
 		cM.mutex.Unlock()
-		cM.firstStoppedSvc = <-firstStoppedSvcCh
+		cM.firstStoppedSvc = cM.waitForStoppedSvcs(stoppedSvcCh)
 	}
 
 	if !cM.IsRunning() {
 		return fmt.Errorf("containers interrupted")
 	}
 
+	for _, c := range cM.containers {
+		if err := c.getErr(); err != nil {
+			return fmt.Errorf("%s: %s", GetContainerName(c.name), err)
+		}
+	}
+
 	if err := cM.Stop(); err != nil {
 		return err
 	}
@@ -136,6 +172,36 @@ func (cM *ContainerManager) Execute(secrets []string) error {
 	return nil
 }
 
+// waitForStoppedSvcs waits for the first non-service container to stop, or all of them if the containers are not
+// aborted when one exits. It returns the name of the first to stop.
+func (cM *ContainerManager) waitForStoppedSvcs(stoppedSvcCh chan string) string {
+	remaining := 0
+	for _, c := range cM.containers {
+		if !c.isService {
+			remaining++
+		}
+	}
+	firstStoppedSvc := ""
+	for range cM.containers {
+		if remaining < 1 {
+			break
+		}
+		name := <-stoppedSvcCh
+		for _, c := range cM.containers {
+			if c.name == name && !c.isService {
+				if firstStoppedSvc == "" {
+					firstStoppedSvc = name
+				}
+				remaining--
+			}
+		}
+		if cM.abortOnContainerExit && firstStoppedSvc != "" {
+			break
+		}
+	}
+	return firstStoppedSvc
+}
+
 // IsRunning returns whether or not the containers are running
 func (cM *ContainerManager) IsRunning() bool {
 	cM.mutex.Lock()
@@ -155,12 +221,24 @@ func (cM *ContainerManager) IsSuccessful() bool {
 	return false
 }
 
+// GetExitCode returns the exit code of the named container once the containers have been stopped
+func (cM *ContainerManager) GetExitCode(name string) (int, error) {
+	cM.mutex.Lock()
+	defer cM.mutex.Unlock()
+	for _, c := range cM.containers {
+		if c.name == name {
+			return c.exitCode, nil
+		}
+	}
+	return 0, fmt.Errorf("no container named %s", name)
+}
+
 // IsAllSuccessful returns whether or not all of the services exited successfully
 func (cM *ContainerManager) IsAllSuccessful() bool {
 	cM.mutex.Lock()
 	defer cM.mutex.Unlock()
 	for _, c := range cM.containers {
-		if c.exitCode != 0 {
+		if !c.isService && c.exitCode != 0 {
 			return false
 		}
 	}
@@ -179,6 +257,9 @@ func (cM *ContainerManager) Stop() error {
 			}
 		}
 		cM.wg.Wait()
+		if err := cM.removeEphemeralVolumes(); err != nil {
+			return err
+		}
 		if err := dockerClient.NetworkRemove(context.Background(), cM.networkID); err != nil {
 			logging.GetLogger().Error("could not remove docker network", zap.String("networkID", cM.networkID), zap.Error(err))
 			return err
@@ -200,10 +281,17 @@ type Container struct {
 	networkConfig   *network.NetworkingConfig
 	networkAliases  []string
 
+	// isService containers run alongside the others and are stopped with them
+	isService    bool
+	dependencies []*containerDependency
+
 	containerID string
 	networkID   string
 	running     bool
+	stopped     bool
+	removed     bool
 	exitCode    int
+	err         error
 	mutex       sync.Mutex
 }
 
@@ -242,27 +330,39 @@ func (c *Container) ConfigureNetwork(networkID string) error {
 }
 
 // Get will ensure that the container exists inside Docker by building or pulling it
-func (c *Container) Get(secrets []string, authConfigs map[string]types.AuthConfig, authTokens map[string]string) error {
+func (c *Container) Get(
+	ctx context.Context,
+	secrets []string,
+	authConfigs map[string]types.AuthConfig,
+	authTokens map[string]string,
+) error {
 	if c.build != nil && (c.build.Dockerfile != "" || c.build.Context != "") {
-		return c.Build(secrets, authConfigs, authTokens)
+		return c.Build(ctx, secrets, authConfigs, authTokens)
 	}
-	return c.Pull(secrets, authConfigs, authTokens)
+	return c.Pull(ctx, secrets, authConfigs, authTokens)
 }
 
 // Build builds the container
 func (c *Container) Build(
+	ctx context.Context,
 	secrets []string,
 	authConfigs map[string]types.AuthConfig,
 	addressAuthToken map[string]string,
 ) error {
 	builder := NewImageBuilder()
 	err := builder.Build(
+		ctx,
 		c.writer,
 		secrets,
 		c.build.Context,
 		c.build.Dockerfile,
 		[]string{GetImageName(c.name)},
 		authConfigs,
+		BuildOptions{
+			BuildArgs: c.build.Args,
+			Target:    c.build.Target,
+			Pull:      true,
+		},
 	)
 	if err != nil {
 		logging.GetLogger().Error("could not build image", zap.String("err", err.Error()))
@@ -275,6 +375,7 @@ func (c *Container) Build(
 
 // Pull pulls the container
 func (c *Container) Pull(
+	ctx context.Context,
 	secrets []string,
 	authConfigs map[string]types.AuthConfig,
 	addressAuthToken map[string]string,
@@ -284,7 +385,7 @@ func (c *Container) Pull(
 	c.containerConfig.Image = resolvePullImage(c.image)
 	authToken := getAuthToken(c.image, addressAuthToken)
 	pullResp, err := dockerClient.ImagePull(
-		context.Background(),
+		ctx,
 		c.image,
 		types.ImagePullOptions{
 			RegistryAuth: authToken,
@@ -293,10 +394,13 @@ func (c *Container) Pull(
 	if err != nil {
 		logging.GetLogger().Error("could not pull image", zap.String("image", c.image), zap.String("err", err.Error()))
 		fmt.Fprintf(c.writer, output.ColorFmt(output.ANSIError, "-> could not pull image: %s", "\n"), err.Error())
-		return err
+		return interruptedError(ctx, "image pull", err)
 	}
 	defer pullResp.Close()
 	HandleOutput(pullResp, secrets, c.writer)
+	if ctx.Err() != nil {
+		return interruptedError(ctx, "image pull", ctx.Err())
+	}
 
 	fmt.Fprintf(c.writer, output.ColorFmt(output.ANSIInfo, "-> pulled image: %s", "\n"), c.image)
 	return nil
@@ -323,11 +427,23 @@ func (c *Container) Create() error {
 	return nil
 }
 
-// Run runs the created container in Docker
-func (c *Container) Run(wg *sync.WaitGroup, secrets []string, firstStoppedSvcCh chan string) error {
-	defer func() { firstStoppedSvcCh <- c.name }()
+// Run runs the created container in Docker once its dependencies are ready
+func (c *Container) Run(wg *sync.WaitGroup, secrets []string, startTimeout time.Duration, stoppedSvcCh chan string) error {
+	defer func() { stoppedSvcCh <- c.name }()
 	defer wg.Done()
+	if err := c.waitForDependencies(startTimeout); err != nil {
+		if c.isStopped() {
+			return nil
+		}
+		c.setErr(err)
+		fmt.Fprintf(c.writer, output.ColorFmt(output.ANSIError, "-> %s could not start: %s", "\n"), GetContainerName(c.name), err)
+		return err
+	}
 	c.mutex.Lock()
+	if c.stopped {
+		c.mutex.Unlock()
+		return nil
+	}
 	c.running = true
 	fmt.Fprintf(c.writer, output.ColorFmt(output.ANSIInfo, "-> %s running", "\n"), GetContainerName(c.name))
 	err := dockerClient.ContainerStart(
@@ -341,6 +457,8 @@ func (c *Container) Run(wg *sync.WaitGroup, secrets []string, firstStoppedSvcCh
 			zap.String("err", err.Error()),
 			zap.String("containerID", c.containerID),
 		)
+		c.err = err
+		c.mutex.Unlock()
 		return err
 	}
 	logsResp, err := dockerClient.ContainerLogs(
@@ -354,6 +472,8 @@ func (c *Container) Run(wg *sync.WaitGroup, secrets []string, firstStoppedSvcCh
 			zap.String("err", err.Error()),
 			zap.String("containerID", c.containerID),
 		)
+		c.err = err
+		c.mutex.Unlock()
 		return err
 	}
 	defer logsResp.Close()
@@ -367,6 +487,25 @@ func (c *Container) Run(wg *sync.WaitGroup, secrets []string, firstStoppedSvcCh
 func (c *Container) Stop() error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	c.stopped = true
+	if !c.running && c.containerID != "" && !c.removed {
+		// the container was created but never started
+		c.removed = true
+		err := dockerClient.ContainerRemove(
+			context.Background(),
+			c.containerID,
+			types.ContainerRemoveOptions{RemoveVolumes: true, Force: true},
+		)
+		if err != nil {
+			logging.GetLogger().Error(
+				"could not remove container",
+				zap.String("err", err.Error()),
+				zap.String("containerID", c.containerID),
+			)
+			return err
+		}
+		fmt.Fprintf(c.writer, output.ColorFmt(output.ANSIInfo, "-> %s removed", "\n"), GetContainerName(c.name))
+	}
 	if c.running {
 		c.running = false
 		stopTimeout, _ := time.ParseDuration("1s")
@@ -414,12 +553,31 @@ func (c *Container) Stop() error {
 			)
 			return err
 		}
+		c.removed = true
 		fmt.Fprintf(c.writer, output.ColorFmt(output.ANSIInfo, "-> %s removed", "\n"), GetContainerName(c.name))
 
 	}
 	return nil
 }
 
+func (c *Container) isStopped() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stopped
+}
+
+func (c *Container) getErr() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+func (c *Container) setErr(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.err = err
+}
+
 // GetContainerName returns the vci normalised container name
 func GetContainerName(serviceName string) string {
 	return fmt.Sprintf(
@@ -3,6 +3,7 @@
 package docker_test
 
 import (
+	"context"
 	"testing"
 	"testing/iotest"
 	"time"
@@ -43,7 +44,7 @@ func TestServiceRunner(t *testing.T) {
 		nil,
 	))
 
-	err := containerManager.Execute(secrets)
+	err := containerManager.Execute(context.Background(), secrets)
 	assert.Nil(t, err)
 }
 
@@ -87,6 +88,6 @@ func TestServiceRunnerInterrupt(t *testing.T) {
 		}
 	}()
 
-	err := containerManager.Execute(secrets)
+	err := containerManager.Execute(context.Background(), secrets)
 	assert.Error(t, err)
 }
@@ -0,0 +1,278 @@
+package docker
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/output"
+)
+
+// Signers
+const (
+	SignerKey    = "key"
+	SignerCosign = "cosign"
+)
+
+// Signer signs pushed image digests and attaches SBOMs to them
+type Signer interface {
+	Sign(ctx context.Context, writer io.Writer, image, digest string) error
+	AttachSBOM(ctx context.Context, writer io.Writer, image, digest string, sbom []byte) error
+}
+
+// SignatureStore saves the signatures and SBOMs written by the key signer and returns where they were saved
+type SignatureStore interface {
+	Save(name string, b []byte) (string, error)
+}
+
+// NewSigner returns the signer of the given kind, defaulting to the key signer. The key is a PEM encoded private
+// key, the path of one or, for cosign, a key reference e.g. awskms://. The key signer saves the signatures and SBOMs
+// into the store.
+func NewSigner(
+	kind string,
+	key string,
+	store SignatureStore,
+	secrets []string,
+	authConfigs map[string]types.AuthConfig,
+) (Signer, error) {
+	switch kind {
+	case "", SignerKey:
+		if IsKeyReference(key) {
+			return nil, fmt.Errorf("the key signer cannot use the key reference %s, use the cosign signer", key)
+		}
+		if isPEM(key) {
+			return NewKeySigner([]byte(key), "signing key", store)
+		}
+		b, err := ioutil.ReadFile(key)
+		if err != nil {
+			return nil, err
+		}
+		return NewKeySigner(b, key, store)
+	case SignerCosign:
+		return &CosignSigner{key: key, secrets: secrets, authConfigs: authConfigs}, nil
+	}
+	return nil, fmt.Errorf("unknown signer %s", kind)
+}
+
+// IsKeyReference returns whether the key is a cosign key reference e.g. env://COSIGN_KEY or awskms://key-id
+func IsKeyReference(key string) bool {
+	return strings.Contains(key, "://") && !isPEM(key)
+}
+
+func isPEM(key string) bool {
+	return strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN ")
+}
+
+// SignaturePayload is the simple signing payload that is signed for an image digest
+type SignaturePayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// NewSignaturePayload returns the payload that is signed for the image's digest
+func NewSignaturePayload(image, digest string) ([]byte, error) {
+	p := SignaturePayload{}
+	p.Critical.Identity.DockerReference = imageRepository(image)
+	p.Critical.Image.DockerManifestDigest = digest
+	p.Critical.Type = "cosign container image signature"
+	return json.Marshal(p)
+}
+
+// KeySignature is a signature written by the key signer
+type KeySignature struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// KeySigner signs digests with a local PEM encoded PKCS8, EC or PKCS1 private key
+type KeySigner struct {
+	signer crypto.Signer
+	store  SignatureStore
+}
+
+// NewKeySigner parses the PEM encoded private key, which is named in errors
+func NewKeySigner(b []byte, keyName string, store SignatureStore) (*KeySigner, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM encoded private key", keyName)
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key %s: %s", keyName, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key %s", keyName)
+	}
+
+	return &KeySigner{signer: signer, store: store}, nil
+}
+
+// Sign saves a signature of the digest as <image>@<digest>.sig
+func (s *KeySigner) Sign(ctx context.Context, writer io.Writer, image, digest string) error {
+	payload, err := NewSignaturePayload(image, digest)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(payload)
+	signature, err := s.signer.Sign(rand.Reader, hash[:], crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(KeySignature{Payload: payload, Signature: signature})
+	if err != nil {
+		return err
+	}
+
+	path, err := s.write(image, digest, "sig", b)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> signed: %s@%s (%s)", "\n"), image, digest, path)
+	return nil
+}
+
+// AttachSBOM saves the SBOM as <image>@<digest>.sbom.json
+func (s *KeySigner) AttachSBOM(ctx context.Context, writer io.Writer, image, digest string, sbom []byte) error {
+	path, err := s.write(image, digest, "sbom.json", sbom)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, output.ColorFmt(output.ANSIInfo, "-> attached sbom: %s@%s (%s)", "\n"), image, digest, path)
+	return nil
+}
+
+func (s *KeySigner) write(image, digest, extension string, b []byte) (string, error) {
+	name := strings.NewReplacer("/", "_", ":", "_").Replace(fmt.Sprintf("%s@%s", imageRepository(image), digest))
+	return s.store.Save(fmt.Sprintf("%s.%s", name, extension), b)
+}
+
+// CosignSigner signs digests and attaches SBOMs in the registry with the cosign CLI. PEM encoded keys are passed to
+// cosign in its environment.
+type CosignSigner struct {
+	key         string
+	secrets     []string
+	authConfigs map[string]types.AuthConfig
+}
+
+// Sign signs the digest with cosign
+func (s *CosignSigner) Sign(ctx context.Context, writer io.Writer, image, digest string) error {
+	key := s.key
+	if isPEM(key) {
+		key = fmt.Sprintf("env://%s", cosignKeyEnv)
+	}
+	return s.run(ctx, writer, "cosign", "sign", "--yes", "--key", key, fmt.Sprintf("%s@%s", imageRepository(image), digest))
+}
+
+// cosignKeyEnv is the environment variable that PEM encoded keys are passed to cosign in
+const cosignKeyEnv = "VELOCITY_COSIGN_KEY"
+
+// AttachSBOM attaches the SBOM to the digest with cosign
+func (s *CosignSigner) AttachSBOM(ctx context.Context, writer io.Writer, image, digest string, sbom []byte) error {
+	f, err := ioutil.TempFile("", "vci-sbom-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(sbom); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	return s.run(ctx, writer, "cosign", "attach", "sbom", "--type", "spdx", "--sbom", f.Name(), fmt.Sprintf("%s@%s", imageRepository(image), digest))
+}
+
+func (s *CosignSigner) run(ctx context.Context, writer io.Writer, command ...string) error {
+	tmpDir, err := ioutil.TempDir("", "vci-cosign-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := writeDockerConfig(tmpDir, s.authConfigs); err != nil {
+		return err
+	}
+	env := []string{fmt.Sprintf("DOCKER_CONFIG=%s", tmpDir)}
+	if isPEM(s.key) {
+		env = append(env, fmt.Sprintf("%s=%s", cosignKeyEnv, s.key))
+	}
+	if err := runCommand(ctx, writer, s.secrets, env, command); err != nil {
+		return interruptedError(ctx, "cosign", fmt.Errorf("cosign failed: %s", err))
+	}
+	return nil
+}
+
+// SBOMGenerator generates SPDX SBOMs of pushed images with the syft CLI. Generation is interrupted by cancelling its
+// context.
+type SBOMGenerator struct{}
+
+// NewSBOMGenerator returns a new SBOM generator
+func NewSBOMGenerator() *SBOMGenerator {
+	return &SBOMGenerator{}
+}
+
+// Generate returns an SPDX JSON SBOM of the pushed digest
+func (g *SBOMGenerator) Generate(
+	ctx context.Context,
+	writer io.Writer,
+	secrets []string,
+	image string,
+	digest string,
+	authConfigs map[string]types.AuthConfig,
+) ([]byte, error) {
+	tmpDir, err := ioutil.TempDir("", "vci-sbom-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := writeDockerConfig(tmpDir, authConfigs); err != nil {
+		return nil, err
+	}
+
+	sbomFile := filepath.Join(tmpDir, "sbom.spdx.json")
+	err = runCommand(ctx, writer, secrets, []string{fmt.Sprintf("DOCKER_CONFIG=%s", tmpDir)}, []string{
+		"syft",
+		fmt.Sprintf("registry:%s@%s", imageRepository(image), digest),
+		"-o", fmt.Sprintf("spdx-json=%s", sbomFile),
+	})
+	if err != nil {
+		return nil, interruptedError(ctx, "sbom generation", fmt.Errorf("sbom generation failed: %s", err))
+	}
+
+	return ioutil.ReadFile(sbomFile)
+}
+
+// imageRepository returns the image without its tag
+func imageRepository(image string) string {
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i]
+	}
+	return image
+}
@@ -0,0 +1,82 @@
+package docker_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/docker"
+)
+
+// signatureStore keeps the saved signatures and SBOMs in memory
+type signatureStore map[string][]byte
+
+func (s signatureStore) Save(name string, b []byte) (string, error) {
+	s[name] = b
+	return name, nil
+}
+
+func TestKeySigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vci-sign-test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	keyFile := filepath.Join(dir, "signing.key")
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+
+	store := signatureStore{}
+	signer, err := docker.NewSigner(docker.SignerKey, keyFile, store, nil, nil)
+	assert.Nil(t, err)
+
+	digest := "sha256:0d7bd6ad5ab0a9b2ba26f16ddbe0e9ab2b0db44ca8ab2ca5c6d2d7a9b0f6ce5e"
+	var out bytes.Buffer
+	assert.Nil(t, signer.Sign(context.Background(), &out, "localhost:5000/app:1.0", digest))
+	assert.Nil(t, signer.AttachSBOM(context.Background(), &out, "localhost:5000/app:1.0", digest, []byte(`{"spdxVersion":"SPDX-2.2"}`)))
+
+	b, ok := store["localhost_5000_app@sha256_"+digest[7:]+".sig"]
+	assert.True(t, ok)
+	signature := docker.KeySignature{}
+	assert.Nil(t, json.Unmarshal(b, &signature))
+
+	expectedPayload, err := docker.NewSignaturePayload("localhost:5000/app:1.0", digest)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedPayload, signature.Payload)
+	hash := sha256.Sum256(signature.Payload)
+	var rs struct{ R, S *big.Int }
+	_, err = asn1.Unmarshal(signature.Signature, &rs)
+	assert.Nil(t, err)
+	assert.True(t, ecdsa.Verify(&key.PublicKey, hash[:], rs.R, rs.S))
+
+	assert.Equal(t, `{"spdxVersion":"SPDX-2.2"}`, string(store["localhost_5000_app@sha256_"+digest[7:]+".sbom.json"]))
+
+	// keys from secret parameters are PEM encoded keys rather than paths
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	assert.Nil(t, err)
+	_, err = docker.NewSigner(docker.SignerKey, string(keyPEM), signatureStore{}, nil, nil)
+	assert.Nil(t, err)
+
+	_, err = docker.NewSigner(docker.SignerKey, "awskms:///alias/signing", store, nil, nil)
+	assert.EqualError(t, err, "the key signer cannot use the key reference awskms:///alias/signing, use the cosign signer")
+	_, err = docker.NewSigner(docker.SignerCosign, "awskms:///alias/signing", store, nil, nil)
+	assert.Nil(t, err)
+
+	_, err = docker.NewSigner("notary", keyFile, store, nil, nil)
+	assert.EqualError(t, err, "unknown signer notary")
+}
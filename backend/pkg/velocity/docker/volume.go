@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"go.uber.org/zap"
+)
+
+// Labels set on cache volumes so they can be found by `vcli cache`
+const (
+	CacheLabel          = "velocity-ci.cache"
+	CacheProjectLabel   = "velocity-ci.cache.project"
+	CacheBlueprintLabel = "velocity-ci.cache.blueprint"
+	CacheNameLabel      = "velocity-ci.cache.name"
+	CacheKeyLabel       = "velocity-ci.cache.key"
+)
+
+// Volume is a named Docker volume that outlives the containers it is mounted into
+type Volume struct {
+	Name   string
+	Labels map[string]string
+	// Ephemeral volumes are removed with the containers
+	Ephemeral bool
+}
+
+// AddVolume adds a volume for the container manager to create before its containers
+func (cM *ContainerManager) AddVolume(volume *Volume) error {
+	cM.volumes = append(cM.volumes, volume)
+	return nil
+}
+
+// createVolumes creates the volumes that do not exist yet. Existing volumes are left untouched.
+func (cM *ContainerManager) createVolumes() error {
+	for _, v := range cM.volumes {
+		labels := map[string]string{"owner": owner}
+		for k, l := range v.Labels {
+			labels[k] = l
+		}
+		_, err := dockerClient.VolumeCreate(context.Background(), volumetypes.VolumeCreateBody{
+			Name:   v.Name,
+			Labels: labels,
+		})
+		if err != nil {
+			logging.GetLogger().Error("could not create docker volume", zap.String("volume", v.Name), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// removeEphemeralVolumes removes the ephemeral volumes once the containers using them have been removed
+func (cM *ContainerManager) removeEphemeralVolumes() error {
+	for _, v := range cM.volumes {
+		if !v.Ephemeral {
+			continue
+		}
+		if err := dockerClient.VolumeRemove(context.Background(), v.Name, true); err != nil && !client.IsErrNotFound(err) {
+			logging.GetLogger().Error("could not remove docker volume", zap.String("volume", v.Name), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// ListCacheVolumes returns the cache volumes with all of the given labels
+func ListCacheVolumes(labels map[string]string) ([]*types.Volume, error) {
+	args := filters.NewArgs(
+		filters.Arg("label", fmt.Sprintf("owner=%s", owner)),
+		filters.Arg("label", CacheLabel),
+	)
+	for k, v := range labels {
+		args.Add("label", fmt.Sprintf("%s=%s", k, v))
+	}
+	resp, err := dockerClient.VolumeList(context.Background(), args)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Volumes, nil
+}
+
+// RemoveVolume removes the named volume. Volumes in use by a container are not removed.
+func RemoveVolume(name string) error {
+	return dockerClient.VolumeRemove(context.Background(), name, false)
+}
@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Index lists the released versions of plugins by name and where to download their binaries from
+type Index struct {
+	Plugins map[string][]*IndexRelease `json:"plugins"`
+}
+
+// IndexRelease is a version of a plugin with its binaries keyed by platform, e.g. linux/amd64
+type IndexRelease struct {
+	Version  string                  `json:"version"`
+	Binaries map[string]*IndexBinary `json:"binaries"`
+}
+
+type IndexBinary struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// Binary is a plugin binary that a reference resolves to
+type Binary struct {
+	Use     string `json:"use"`
+	Version string `json:"version,omitempty"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
+}
+
+// GetPlatform returns the platform that binaries are resolved for
+func GetPlatform() string {
+	return fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// Resolve returns the binary of the highest version of the named plugin that meets the version constraint and has
+// a binary for the platform
+func (i *Index) Resolve(name, version, platform string) (*Binary, error) {
+	releases, ok := i.Plugins[name]
+	if !ok {
+		return nil, fmt.Errorf("plugin %s is not in the plugin index", name)
+	}
+	constraint, err := ParseConstraint(version)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s version: %s", name, err)
+	}
+
+	var latest *Version
+	var binary *Binary
+	for _, release := range releases {
+		v, err := ParseVersion(release.Version)
+		if err != nil || !constraint.Check(v) {
+			continue
+		}
+		b, ok := release.Binaries[platform]
+		if !ok || (latest != nil && v.Compare(latest) <= 0) {
+			continue
+		}
+		latest = v
+		binary = &Binary{
+			Use:     name,
+			Version: release.Version,
+			URL:     b.URL,
+			SHA256:  strings.ToLower(b.SHA256),
+		}
+	}
+	if binary == nil {
+		if version == "" {
+			return nil, fmt.Errorf("plugin %s has no release for %s", name, platform)
+		}
+		return nil, fmt.Errorf("plugin %s has no release matching %s for %s", name, version, platform)
+	}
+	if !isSHA256(binary.SHA256) {
+		return nil, fmt.Errorf("plugin %s %s has no valid sha256 in the plugin index", name, binary.Version)
+	}
+
+	return binary, nil
+}
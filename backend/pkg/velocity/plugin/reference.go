@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Reference refers to a plugin binary either by URL, which must be pinned by its sha256 checksum, or by its name in
+// the plugin index and an optional version constraint.
+type Reference struct {
+	Use     string `json:"use"`
+	Version string `json:"version,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+// IsURL returns whether the plugin is referred to by URL rather than by name
+func (r Reference) IsURL() bool {
+	return strings.Contains(r.Use, "://")
+}
+
+func (r Reference) String() string {
+	if r.Version != "" {
+		return fmt.Sprintf("%s@%s", r.Use, r.Version)
+	}
+	return r.Use
+}
+
+// Validate returns why the reference cannot be resolved, if it cannot
+func (r Reference) Validate() error {
+	if r.Use == "" {
+		return fmt.Errorf("plugin has no use")
+	}
+	if r.SHA256 != "" && !isSHA256(r.SHA256) {
+		return fmt.Errorf("plugin %s sha256 %q is not a hex encoded sha256 checksum", r.Use, r.SHA256)
+	}
+	if r.IsURL() {
+		if r.SHA256 == "" {
+			return fmt.Errorf("plugin %s has no sha256", r.Use)
+		}
+		if r.Version != "" {
+			return fmt.Errorf("plugin %s is a URL so cannot have a version", r.Use)
+		}
+		return nil
+	}
+	if _, err := ParseConstraint(r.Version); err != nil {
+		return fmt.Errorf("plugin %s version: %s", r.Use, err)
+	}
+
+	return nil
+}
+
+func isSHA256(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == 32
+}
@@ -0,0 +1,348 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// CacheDirEnv overrides the directory that plugin binaries are cached in
+	CacheDirEnv = "VELOCITY_PLUGIN_CACHE"
+	// IndexEnv is the URL or path of the plugin index that plugins referred to by name are resolved from
+	IndexEnv = "VELOCITY_PLUGIN_INDEX"
+	// DownloadTimeout is how long downloading the index or a binary may take
+	DownloadTimeout = 5 * time.Minute
+)
+
+// Store is a per-user cache of plugin binaries that is shared by every project. Binaries are stored by their sha256
+// checksum, which is verified whenever they are fetched.
+type Store struct {
+	Dir      string
+	IndexURL string
+	Client   *http.Client
+
+	mutex sync.Mutex
+	index *Index
+	// binaryMutexes prevent concurrently running Tasks from downloading the same binary over each other without
+	// waiting for the downloads of other binaries
+	binaryMutexes map[string]*sync.Mutex
+}
+
+// Entry is a binary in the store
+type Entry struct {
+	Binary
+	Size    int64     `json:"size"`
+	Fetched time.Time `json:"fetched"`
+}
+
+var (
+	storesMutex sync.Mutex
+	stores      = map[string]*Store{}
+)
+
+// GetStore returns the store in the user's cache directory, or VELOCITY_PLUGIN_CACHE, which resolves plugins by
+// name from VELOCITY_PLUGIN_INDEX
+func GetStore() (*Store, error) {
+	dir := os.Getenv(CacheDirEnv)
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(cacheDir, "velocityci", "plugins")
+	}
+	indexURL := os.Getenv(IndexEnv)
+
+	storesMutex.Lock()
+	defer storesMutex.Unlock()
+	key := fmt.Sprintf("%s\n%s", dir, indexURL)
+	if _, ok := stores[key]; !ok {
+		stores[key] = NewStore(dir, indexURL)
+	}
+	return stores[key], nil
+}
+
+func NewStore(dir, indexURL string) *Store {
+	return &Store{
+		Dir:           dir,
+		IndexURL:      indexURL,
+		Client:        &http.Client{Timeout: DownloadTimeout},
+		binaryMutexes: map[string]*sync.Mutex{},
+	}
+}
+
+// getBinaryMutex returns the mutex that guards the binary with the sha256 checksum
+func (s *Store) getBinaryMutex(sum string) *sync.Mutex {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.binaryMutexes[sum]; !ok {
+		s.binaryMutexes[sum] = &sync.Mutex{}
+	}
+	return s.binaryMutexes[sum]
+}
+
+// Resolve returns the binary that the reference refers to, looking plugins referred to by name up in the index
+func (s *Store) Resolve(ref Reference) (*Binary, error) {
+	if err := ref.Validate(); err != nil {
+		return nil, err
+	}
+	if ref.IsURL() {
+		return &Binary{Use: ref.Use, URL: ref.Use, SHA256: strings.ToLower(ref.SHA256)}, nil
+	}
+
+	index, err := s.getIndex()
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve plugin %s: %s", ref.Use, err)
+	}
+	b, err := index.Resolve(ref.Use, ref.Version, GetPlatform())
+	if err != nil {
+		return nil, err
+	}
+	if ref.SHA256 != "" && !strings.EqualFold(ref.SHA256, b.SHA256) {
+		return nil, fmt.Errorf("plugin %s %s sha256 %s does not match the pinned sha256 %s", ref.Use, b.Version, b.SHA256, ref.SHA256)
+	}
+	return b, nil
+}
+
+func (s *Store) getIndex() (*Index, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.index != nil {
+		return s.index, nil
+	}
+	if s.IndexURL == "" {
+		return nil, fmt.Errorf("no plugin index is set in %s", IndexEnv)
+	}
+
+	r, err := s.open(s.IndexURL)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var index Index
+	if err := yaml.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("plugin index %s is invalid: %s", s.IndexURL, err)
+	}
+	s.index = &index
+	return s.index, nil
+}
+
+// Fetch returns the path of the referenced binary, downloading it if it is not in the store or no longer matches
+// its checksum
+func (s *Store) Fetch(ref Reference, writer io.Writer) (string, error) {
+	b, err := s.Resolve(ref)
+	if err != nil {
+		return "", err
+	}
+
+	binaryMutex := s.getBinaryMutex(b.SHA256)
+	binaryMutex.Lock()
+	defer binaryMutex.Unlock()
+
+	binaryLocation := s.getPath(b.SHA256)
+	err = verifyFile(binaryLocation, b.SHA256)
+	if err == nil {
+		return binaryLocation, nil
+	}
+	if !os.IsNotExist(err) {
+		logging.GetLogger().Warn("cached binary is invalid", zap.String("path", binaryLocation), zap.Error(err))
+		fmt.Fprintf(writer, "-> cached plugin %s is invalid, downloading it again: %s\n", ref, err)
+	}
+
+	if err := s.download(b, writer); err != nil {
+		return "", err
+	}
+	return binaryLocation, nil
+}
+
+// Verify returns the path of the referenced binary, or an error if it has not been fetched or no longer matches its
+// checksum
+func (s *Store) Verify(ref Reference) (string, error) {
+	b, err := s.Resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	binaryLocation := s.getPath(b.SHA256)
+	if err := verifyFile(binaryLocation, b.SHA256); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("plugin %s has not been fetched", ref)
+		}
+		return "", err
+	}
+	return binaryLocation, nil
+}
+
+// List returns the binaries in the store ordered by when they were fetched
+func (s *Store) List() ([]*Entry, error) {
+	files, err := ioutil.ReadDir(filepath.Join(s.Dir, "sha256"))
+	if os.IsNotExist(err) {
+		return []*Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*Entry{}
+	for _, f := range files {
+		if f.IsDir() || !isSHA256(f.Name()) {
+			continue
+		}
+		e := &Entry{}
+		if b, err := ioutil.ReadFile(s.getEntryPath(f.Name())); err == nil {
+			json.Unmarshal(b, e)
+		}
+		e.SHA256 = f.Name()
+		e.Size = f.Size()
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Fetched.Before(entries[j].Fetched)
+	})
+
+	return entries, nil
+}
+
+// VerifyEntry returns an error if the binary no longer matches its checksum
+func (s *Store) VerifyEntry(e *Entry) error {
+	return verifyFile(s.getPath(e.SHA256), e.SHA256)
+}
+
+// Remove removes the binary from the store
+func (s *Store) Remove(e *Entry) error {
+	binaryMutex := s.getBinaryMutex(e.SHA256)
+	binaryMutex.Lock()
+	defer binaryMutex.Unlock()
+	if err := os.Remove(s.getPath(e.SHA256)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(s.getEntryPath(e.SHA256)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) getPath(sum string) string {
+	return filepath.Join(s.Dir, "sha256", sum)
+}
+
+func (s *Store) getEntryPath(sum string) string {
+	return fmt.Sprintf("%s.json", s.getPath(sum))
+}
+
+// download downloads the binary into a temporary file in the store and only moves it into place once its checksum
+// has been verified, so that a failed or partial download never leaves a binary behind
+func (s *Store) download(b *Binary, writer io.Writer) (err error) {
+	binaryLocation := s.getPath(b.SHA256)
+	if err := os.MkdirAll(filepath.Dir(binaryLocation), 0755); err != nil {
+		return err
+	}
+
+	logging.GetLogger().Debug("downloading binary", zap.String("from", b.URL), zap.String("to", binaryLocation))
+	fmt.Fprintf(writer, "-> downloading plugin %s\n", b.URL)
+	r, err := s.open(b.URL)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(binaryLocation), ".download-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmpFile.Close()
+		if err != nil {
+			os.Remove(tmpFile.Name())
+		}
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hash), r)
+	if err != nil {
+		return fmt.Errorf("could not download plugin %s: %s", b.URL, err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != b.SHA256 {
+		return fmt.Errorf("plugin %s sha256 is %s, expected %s", b.URL, sum, b.SHA256)
+	}
+	if err := tmpFile.Chmod(0755); err != nil {
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile.Name(), binaryLocation); err != nil {
+		return err
+	}
+
+	entryBytes, _ := json.Marshal(&Entry{Binary: *b, Size: size, Fetched: time.Now().UTC()})
+	if err := ioutil.WriteFile(s.getEntryPath(b.SHA256), entryBytes, 0644); err != nil {
+		logging.GetLogger().Warn("could not write binary entry", zap.String("sha256", b.SHA256), zap.Error(err))
+	}
+
+	logging.GetLogger().Debug("downloaded binary", zap.String("from", b.URL), zap.String("to", binaryLocation), zap.Int64("bytes", size))
+	fmt.Fprintf(writer, "-> downloaded plugin %s (%d bytes)\n", b.URL, size)
+	return nil
+}
+
+// open opens the file at the http(s) or file URL, or path
+func (s *Store) open(u string) (io.ReadCloser, error) {
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	switch parsedURL.Scheme {
+	case "http", "https":
+		resp, err := s.Client.Get(u)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("could not download %s: %s", u, resp.Status)
+		}
+		return resp.Body, nil
+	case "file":
+		return os.Open(parsedURL.Path)
+	case "":
+		return os.Open(u)
+	default:
+		return nil, fmt.Errorf("could not download %s: unsupported scheme %s", u, parsedURL.Scheme)
+	}
+}
+
+// verifyFile returns an error if the file does not exist or does not match the sha256 checksum
+func verifyFile(path, sum string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != sum {
+		return fmt.Errorf("%s sha256 is %s, expected %s", path, actual, sum)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package plugin_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
+)
+
+func getSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// newPluginServer serves the binaries and a plugin index of them at /index.json
+func newPluginServer(binaries map[string][]byte) *httptest.Server {
+	mux := http.NewServeMux()
+	for path, b := range binaries {
+		b := b
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Write(b)
+		})
+	}
+	server := httptest.NewServer(mux)
+	release := func(version, path string) string {
+		return fmt.Sprintf(`{"version": %q, "binaries": {%q: {"url": "%s%s", "sha256": %q}}}`,
+			version, plugin.GetPlatform(), server.URL, path, getSHA256(binaries[path]))
+	}
+	index := fmt.Sprintf(`{"plugins": {"parameter.aws-ssm": [%s, %s, %s]}}`,
+		release("0.1.0", "/aws-ssm-0.1.0"),
+		release("0.1.1", "/aws-ssm-0.1.1"),
+		release("1.0.0", "/aws-ssm-1.0.0"),
+	)
+	mux.HandleFunc("/index.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(index))
+	})
+	return server
+}
+
+func newStore(t *testing.T, server *httptest.Server) (*plugin.Store, func()) {
+	dir, err := ioutil.TempDir("", "vci-plugin-store-")
+	assert.Nil(t, err)
+	return plugin.NewStore(dir, fmt.Sprintf("%s/index.json", server.URL)), func() { os.RemoveAll(dir) }
+}
+
+func TestStoreFetchURL(t *testing.T) {
+	bin := []byte("#!/bin/sh\necho hello\n")
+	server := newPluginServer(map[string][]byte{"/hello": bin})
+	defer server.Close()
+	store, remove := newStore(t, server)
+	defer remove()
+
+	ref := plugin.Reference{Use: fmt.Sprintf("%s/hello", server.URL), SHA256: getSHA256(bin)}
+	path, err := store.Fetch(ref, ioutil.Discard)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(store.Dir, "sha256", ref.SHA256), path)
+	b, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, bin, b)
+
+	path, err = store.Verify(ref)
+	assert.Nil(t, err)
+
+	// a corrupted binary is downloaded again
+	assert.Nil(t, ioutil.WriteFile(path, []byte("corrupt"), 0755))
+	_, err = store.Verify(ref)
+	assert.Error(t, err)
+	_, err = store.Fetch(ref, ioutil.Discard)
+	assert.Nil(t, err)
+	_, err = store.Verify(ref)
+	assert.Nil(t, err)
+
+	entries, err := store.List()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, ref.Use, entries[0].URL)
+	assert.Equal(t, int64(len(bin)), entries[0].Size)
+}
+
+func TestStoreFetchInvalid(t *testing.T) {
+	bin := []byte("#!/bin/sh\necho hello\n")
+	server := newPluginServer(map[string][]byte{"/hello": bin})
+	defer server.Close()
+	store, remove := newStore(t, server)
+	defer remove()
+
+	_, err := store.Fetch(plugin.Reference{Use: fmt.Sprintf("%s/hello", server.URL)}, ioutil.Discard)
+	assert.EqualError(t, err, fmt.Sprintf("plugin %s/hello has no sha256", server.URL))
+
+	_, err = store.Fetch(plugin.Reference{Use: fmt.Sprintf("%s/missing", server.URL), SHA256: getSHA256(bin)}, ioutil.Discard)
+	assert.EqualError(t, err, fmt.Sprintf("could not download %s/missing: 404 Not Found", server.URL))
+
+	other := getSHA256([]byte("other"))
+	_, err = store.Fetch(plugin.Reference{Use: fmt.Sprintf("%s/hello", server.URL), SHA256: other}, ioutil.Discard)
+	assert.EqualError(t, err, fmt.Sprintf("plugin %s/hello sha256 is %s, expected %s", server.URL, getSHA256(bin), other))
+
+	// failed downloads leave nothing behind
+	files, err := ioutil.ReadDir(filepath.Join(store.Dir, "sha256"))
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+func TestStoreResolveIndex(t *testing.T) {
+	server := newPluginServer(map[string][]byte{
+		"/aws-ssm-0.1.0": []byte("0.1.0"),
+		"/aws-ssm-0.1.1": []byte("0.1.1"),
+		"/aws-ssm-1.0.0": []byte("1.0.0"),
+	})
+	defer server.Close()
+	store, remove := newStore(t, server)
+	defer remove()
+
+	b, err := store.Resolve(plugin.Reference{Use: "parameter.aws-ssm"})
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0.0", b.Version)
+
+	b, err = store.Resolve(plugin.Reference{Use: "parameter.aws-ssm", Version: "^0.1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "0.1.1", b.Version)
+	assert.Equal(t, fmt.Sprintf("%s/aws-ssm-0.1.1", server.URL), b.URL)
+	assert.Equal(t, getSHA256([]byte("0.1.1")), b.SHA256)
+
+	path, err := store.Fetch(plugin.Reference{Use: "parameter.aws-ssm", Version: "0.1.0"}, ioutil.Discard)
+	assert.Nil(t, err)
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "0.1.0", string(content))
+
+	_, err = store.Resolve(plugin.Reference{Use: "parameter.aws-ssm", Version: "^2"})
+	assert.EqualError(t, err, fmt.Sprintf("plugin parameter.aws-ssm has no release matching ^2 for %s", plugin.GetPlatform()))
+
+	pinned := getSHA256([]byte("other"))
+	_, err = store.Resolve(plugin.Reference{Use: "parameter.aws-ssm", Version: "1", SHA256: pinned})
+	assert.EqualError(t, err, fmt.Sprintf(
+		"plugin parameter.aws-ssm 1.0.0 sha256 %s does not match the pinned sha256 %s", getSHA256([]byte("1.0.0")), pinned,
+	))
+
+	_, err = store.Resolve(plugin.Reference{Use: "registry.ecr"})
+	assert.EqualError(t, err, "plugin registry.ecr is not in the plugin index")
+
+	_, err = plugin.NewStore(store.Dir, "").Resolve(plugin.Reference{Use: "registry.ecr"})
+	assert.EqualError(t, err, "could not resolve plugin registry.ecr: no plugin index is set in VELOCITY_PLUGIN_INDEX")
+}
+
+func TestStoreFetchStalledDownload(t *testing.T) {
+	bin := []byte("#!/bin/sh\necho hello\n")
+	stalled := make(chan struct{})
+	server := newPluginServer(map[string][]byte{"/hello": bin})
+	defer server.Close()
+	defer close(stalled)
+	stalledServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-stalled:
+		case <-r.Context().Done():
+		}
+	}))
+	defer stalledServer.Close()
+	store, remove := newStore(t, server)
+	defer remove()
+	store.Client.Timeout = 500 * time.Millisecond
+
+	stalledErr := make(chan error)
+	go func() {
+		_, err := store.Fetch(plugin.Reference{Use: fmt.Sprintf("%s/stalled", stalledServer.URL), SHA256: getSHA256([]byte("stalled"))}, ioutil.Discard)
+		stalledErr <- err
+	}()
+
+	// other binaries are fetched while the stalled download is waiting
+	time.Sleep(100 * time.Millisecond)
+	_, err := store.Fetch(plugin.Reference{Use: fmt.Sprintf("%s/hello", server.URL), SHA256: getSHA256(bin)}, ioutil.Discard)
+	assert.Nil(t, err)
+	select {
+	case err := <-stalledErr:
+		t.Fatalf("stalled download returned before the timeout: %v", err)
+	default:
+	}
+
+	select {
+	case err := <-stalledErr:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled download did not time out")
+	}
+}
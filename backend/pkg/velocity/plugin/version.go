@@ -0,0 +1,251 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version, e.g. 1.2.3 or v1.2.3-rc.1. Build metadata is ignored.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+}
+
+// ParseVersion parses a semantic version with an optional v prefix
+func ParseVersion(s string) (*Version, error) {
+	v, precision, err := parsePartialVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	if precision < 3 {
+		return nil, fmt.Errorf("version %q must have a major, minor and patch version", s)
+	}
+	return v, nil
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s = fmt.Sprintf("%s-%s", s, v.Prerelease)
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 when the version is lower than, equal to or higher than the other version.
+// Prereleases are lower than their release.
+func (v *Version) Compare(o *Version) int {
+	for _, c := range [][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c[0] < c[1] {
+			return -1
+		}
+		if c[0] > c[1] {
+			return 1
+		}
+	}
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case o.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// comparePrerelease compares dot separated prerelease identifiers, numerically when both are numbers
+func comparePrerelease(a, b string) int {
+	aIDs := strings.Split(a, ".")
+	bIDs := strings.Split(b, ".")
+	for i := 0; i < len(aIDs) && i < len(bIDs); i++ {
+		aN, aErr := strconv.ParseUint(aIDs[i], 10, 64)
+		bN, bErr := strconv.ParseUint(bIDs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if aN != bN {
+				if aN < bN {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case aIDs[i] != bIDs[i]:
+			if aIDs[i] < bIDs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(aIDs) < len(bIDs):
+		return -1
+	case len(aIDs) > len(bIDs):
+		return 1
+	}
+	return 0
+}
+
+// parsePartialVersion parses versions that may leave out their minor and patch versions or give them as x or *,
+// returning how many of the major, minor and patch versions were given
+func parsePartialVersion(s string) (v *Version, precision int, _ error) {
+	v = &Version{}
+	raw := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(raw, "+"); i >= 0 {
+		raw = raw[:i]
+	}
+	if i := strings.Index(raw, "-"); i >= 0 {
+		v.Prerelease = raw[i+1:]
+		raw = raw[:i]
+		if v.Prerelease == "" {
+			return nil, 0, fmt.Errorf("version %q has an empty prerelease", s)
+		}
+	}
+	parts := strings.Split(raw, ".")
+	if len(parts) > 3 {
+		return nil, 0, fmt.Errorf("version %q has more than 3 parts", s)
+	}
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			continue
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil || precision < i {
+			return nil, 0, fmt.Errorf("version %q is not a semantic version", s)
+		}
+		*numbers[i] = n
+		precision++
+	}
+	if v.Prerelease != "" && precision < 3 {
+		return nil, 0, fmt.Errorf("version %q must have a major, minor and patch version to have a prerelease", s)
+	}
+	return v, precision, nil
+}
+
+// Constraint selects versions, e.g. 1.2.3, 1.2, ^1.2, ~1.2.3 or >=1.2, <2. Comma separated constraints must all be
+// met. An empty constraint or latest selects any version. Prereleases are only selected by constraints that name a
+// prerelease of the same version.
+type Constraint struct {
+	raw   string
+	terms []constraintTerm
+}
+
+type constraintTerm struct {
+	operator  string
+	version   *Version
+	precision int
+}
+
+var constraintOperators = []string{">=", "<=", ">", "<", "=", "^", "~"}
+
+// ParseConstraint parses a version constraint
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: s}
+	if s = strings.TrimSpace(s); s == "" || s == "latest" || s == "*" {
+		return c, nil
+	}
+	for _, rawTerm := range strings.Split(s, ",") {
+		rawTerm = strings.TrimSpace(rawTerm)
+		t := constraintTerm{}
+		for _, op := range constraintOperators {
+			if strings.HasPrefix(rawTerm, op) {
+				t.operator = op
+				rawTerm = strings.TrimSpace(strings.TrimPrefix(rawTerm, op))
+				break
+			}
+		}
+		v, precision, err := parsePartialVersion(rawTerm)
+		if err != nil {
+			return nil, fmt.Errorf("constraint %q: %s", c.raw, err)
+		}
+		if precision < 1 {
+			return nil, fmt.Errorf("constraint %q has no version", c.raw)
+		}
+		t.version = v
+		t.precision = precision
+		c.terms = append(c.terms, t)
+	}
+
+	return c, nil
+}
+
+func (c *Constraint) String() string {
+	return c.raw
+}
+
+// Check returns whether the version meets the constraint
+func (c *Constraint) Check(v *Version) bool {
+	if v.Prerelease != "" && !c.allowsPrereleaseOf(v) {
+		return false
+	}
+	for _, t := range c.terms {
+		if !t.check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Constraint) allowsPrereleaseOf(v *Version) bool {
+	for _, t := range c.terms {
+		if t.version.Prerelease != "" &&
+			t.version.Major == v.Major && t.version.Minor == v.Minor && t.version.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func (t constraintTerm) check(v *Version) bool {
+	lower := t.version
+	switch t.operator {
+	case ">=":
+		return v.Compare(lower) >= 0
+	case ">":
+		if t.precision < 3 {
+			return v.Compare(t.next()) >= 0
+		}
+		return v.Compare(lower) > 0
+	case "<":
+		return v.Compare(lower) < 0
+	case "<=":
+		if t.precision < 3 {
+			return v.Compare(t.next()) < 0
+		}
+		return v.Compare(lower) <= 0
+	case "^":
+		upper := &Version{Major: lower.Major + 1}
+		if lower.Major == 0 && t.precision > 1 {
+			upper = &Version{Minor: lower.Minor + 1}
+			if lower.Minor == 0 && t.precision > 2 {
+				upper = &Version{Patch: lower.Patch + 1}
+			}
+		}
+		return v.Compare(lower) >= 0 && v.Compare(upper) < 0
+	case "~":
+		upper := &Version{Major: lower.Major + 1}
+		if t.precision > 1 {
+			upper = &Version{Major: lower.Major, Minor: lower.Minor + 1}
+		}
+		return v.Compare(lower) >= 0 && v.Compare(upper) < 0
+	default:
+		if t.precision < 3 {
+			return v.Compare(lower) >= 0 && v.Compare(t.next()) < 0
+		}
+		return v.Compare(lower) == 0
+	}
+}
+
+// next returns the lowest version after every version that a partial version matches, e.g. 1.3.0 for 1.2
+func (t constraintTerm) next() *Version {
+	if t.precision < 2 {
+		return &Version{Major: t.version.Major + 1}
+	}
+	return &Version{Major: t.version.Major, Minor: t.version.Minor + 1}
+}
@@ -0,0 +1,58 @@
+package plugin_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/velocity-ci/velocity/backend/pkg/velocity/plugin"
+)
+
+func TestConstraintCheck(t *testing.T) {
+	versions := []string{"0.1.0", "0.1.1", "0.2.0", "1.0.0-rc.1", "1.0.0", "1.2.3", "1.3.0", "2.0.0"}
+	tests := map[string][]string{
+		"":                   {"0.1.0", "0.1.1", "0.2.0", "1.0.0", "1.2.3", "1.3.0", "2.0.0"},
+		"1.2.3":              {"1.2.3"},
+		"v1":                 {"1.0.0", "1.2.3", "1.3.0"},
+		"1.x":                {"1.0.0", "1.2.3", "1.3.0"},
+		"^1.2":               {"1.2.3", "1.3.0"},
+		"^0.1":               {"0.1.0", "0.1.1"},
+		"~1.2.0":             {"1.2.3"},
+		"~0":                 {"0.1.0", "0.1.1", "0.2.0"},
+		">=1, <2":            {"1.0.0", "1.2.3", "1.3.0"},
+		">0.1":               {"0.2.0", "1.0.0", "1.2.3", "1.3.0", "2.0.0"},
+		"<=1.2":              {"0.1.0", "0.1.1", "0.2.0", "1.0.0", "1.2.3"},
+		"1.0.0-rc.1":         {"1.0.0-rc.1"},
+		">=1.0.0-rc.1, <1.1": {"1.0.0-rc.1", "1.0.0"},
+	}
+
+	for constraint, expected := range tests {
+		c, err := plugin.ParseConstraint(constraint)
+		assert.Nil(t, err, constraint)
+		matched := []string{}
+		for _, raw := range versions {
+			v, err := plugin.ParseVersion(raw)
+			assert.Nil(t, err)
+			if c.Check(v) {
+				matched = append(matched, raw)
+			}
+		}
+		assert.Equal(t, expected, matched, constraint)
+	}
+}
+
+func TestParseConstraintInvalid(t *testing.T) {
+	for _, constraint := range []string{"^", "1.2.3.4", "1.x.2", ">=a", "1.2-rc.1"} {
+		_, err := plugin.ParseConstraint(constraint)
+		assert.Error(t, err, constraint)
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0", "1.0.1", "1.10.0"}
+	for i := 1; i < len(ordered); i++ {
+		a, _ := plugin.ParseVersion(ordered[i-1])
+		b, _ := plugin.ParseVersion(ordered[i])
+		assert.Equal(t, -1, a.Compare(b), "%s < %s", a, b)
+		assert.Equal(t, 1, b.Compare(a), "%s > %s", b, a)
+	}
+}